@@ -0,0 +1,456 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Token is a term produced by an Analyzer, with the byte offsets of the
+// text it was produced from.
+type Token struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// Analyzer turns text into the terms that are indexed and searched.
+// The Searcher uses the same Analyzer for documents and queries.
+type Analyzer interface {
+	// Analyze returns the tokens of the text in order.
+	Analyze(text string) []Token
+	// Normalize applies the character level normalization of the analyzer,
+	// e.g. lowercasing, to a term that is not tokenized, such as a wildcard pattern.
+	Normalize(term string) string
+}
+
+// CharFilter changes text before it is tokenized. Token offsets refer to
+// the filtered text, so filters should keep the offsets of the text they keep.
+type CharFilter interface {
+	FilterText(text string) string
+}
+
+// Tokenizer splits text into tokens.
+type Tokenizer interface {
+	Tokenize(text string) []Token
+}
+
+// TokenFilter changes, removes or adds tokens after tokenization.
+type TokenFilter interface {
+	FilterTokens(tokens []Token) []Token
+}
+
+// Normalizer is implemented by token filters that change the characters of
+// each term independently, which are also applied by Analyzer.Normalize.
+type Normalizer interface {
+	NormalizeTerm(term string) string
+}
+
+// PipelineAnalyzer runs the char filters, the tokenizer and then the token filters.
+type PipelineAnalyzer struct {
+	CharFilters  []CharFilter
+	Tokenizer    Tokenizer
+	TokenFilters []TokenFilter
+}
+
+// NewAnalyzer returns an analyzer without char filters.
+func NewAnalyzer(tokenizer Tokenizer, filters ...TokenFilter) *PipelineAnalyzer {
+	return &PipelineAnalyzer{Tokenizer: tokenizer, TokenFilters: filters}
+}
+
+func (a *PipelineAnalyzer) Analyze(text string) []Token {
+	for _, filter := range a.CharFilters {
+		text = filter.FilterText(text)
+	}
+	tokens := a.Tokenizer.Tokenize(text)
+	for _, filter := range a.TokenFilters {
+		tokens = filter.FilterTokens(tokens)
+	}
+	return tokens
+}
+
+// String describes the components of the analyzer, which identifies
+// the analyzer that the indices of an index file were built with.
+func (a *PipelineAnalyzer) String() string {
+	var names []string
+	for _, filter := range a.CharFilters {
+		names = append(names, componentName(filter))
+	}
+	names = append(names, componentName(a.Tokenizer))
+	for _, filter := range a.TokenFilters {
+		names = append(names, componentName(filter))
+	}
+	return strings.Join(names, " > ")
+}
+
+// componentName returns the description of a component of an analyzer,
+// or the name of its type if it does not describe itself.
+func componentName(component interface{}) string {
+	if stringer, ok := component.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%T", component)
+}
+
+func (a *PipelineAnalyzer) Normalize(term string) string {
+	for _, filter := range a.TokenFilters {
+		if normalizer, ok := filter.(Normalizer); ok {
+			term = normalizer.NormalizeTerm(term)
+		}
+	}
+	return term
+}
+
+// SimpleAnalyzer splits text on non-alphanumeric characters and lowercases it.
+var SimpleAnalyzer Analyzer = NewAnalyzer(StandardTokenizer, LowercaseFilter{})
+
+// DefaultAnalyzer splits text into words with the UnicodeTokenizer,
+// normalizes them with NFKC, lowercases them and stems English words,
+// replacing the EnglishLemmas that stemming does not conflate first.
+var DefaultAnalyzer Analyzer = NewAnalyzer(UnicodeTokenizer{}, NFKCFilter{}, LowercaseFilter{},
+	LemmaFilter{EnglishLemmas}, StemFilter{Porter2Stemmer{}})
+
+// analyzeTerms returns the terms of the tokens of the text.
+func analyzeTerms(analyzer Analyzer, text string) (terms []string) {
+	for _, token := range analyzer.Analyze(text) {
+		terms = append(terms, token.Text)
+	}
+	return
+}
+
+// Tokenizers
+
+// PatternTokenizer produces a token for each match of a regular expression.
+type PatternTokenizer struct {
+	pattern *regexp.Regexp
+}
+
+// NewPatternTokenizer returns a tokenizer for the pattern, which must compile.
+func NewPatternTokenizer(pattern string) *PatternTokenizer {
+	return &PatternTokenizer{regexp.MustCompile(pattern)}
+}
+
+func (t *PatternTokenizer) Tokenize(text string) (tokens []Token) {
+	for _, span := range t.pattern.FindAllStringIndex(text, -1) {
+		tokens = append(tokens, Token{Text: text[span[0]:span[1]], Start: span[0], End: span[1]})
+	}
+	return
+}
+
+func (t *PatternTokenizer) String() string {
+	return fmt.Sprintf("PatternTokenizer(%s)", t.pattern)
+}
+
+// StandardTokenizer produces tokens of ASCII letters and digits.
+var StandardTokenizer = NewPatternTokenizer(`[a-zA-Z0-9]+`)
+
+// LetterDigitTokenizer produces tokens of Unicode letters and digits.
+var LetterDigitTokenizer = NewPatternTokenizer(`[\pL\pN]+`)
+
+// wildcardTokenizer produces tokens of Unicode letters, marks and digits,
+// and the wildcard characters '*' and '?'.
+var wildcardTokenizer = NewPatternTokenizer(`[\pL\pM\pN*?]+`)
+
+// UnicodeTokenizer splits text into words of Unicode letters, marks and digits.
+// It follows the word boundaries of Unicode Standard Annex #29 in a simplified
+// form: numbers keep their separators ("3.14" and "1,000" are single tokens),
+// while apostrophes and hyphens always end a word, so that "Cohen's" becomes
+// "Cohen" and "s" as in the StandardTokenizer.
+// Text in CJK scripts, which is written without spaces between words,
+// becomes overlapping bigrams, e.g. "東京都" becomes "東京" and "京都".
+type UnicodeTokenizer struct{}
+
+// isCJK checks if the rune is in a script that is tokenized into bigrams.
+func isCJK(r rune) bool {
+	// The prolonged sound mark is part of Katakana words but is not in the Katakana table.
+	return r == 'ー' || unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+func (UnicodeTokenizer) Tokenize(text string) (tokens []Token) {
+	// start is the start of the current word, and cjk are the starts
+	// of the runes in the current run of CJK text.
+	start := -1
+	var cjk []int
+	endWord := func(end int) {
+		if start != -1 {
+			tokens = append(tokens, Token{Text: text[start:end], Start: start, End: end})
+			start = -1
+		}
+	}
+	endCJK := func(end int) {
+		if len(cjk) == 1 {
+			tokens = append(tokens, Token{Text: text[cjk[0]:end], Start: cjk[0], End: end})
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			bigramEnd := end
+			if i+2 < len(cjk) {
+				bigramEnd = cjk[i+2]
+			}
+			tokens = append(tokens, Token{Text: text[cjk[i]:bigramEnd], Start: cjk[i], End: bigramEnd})
+		}
+		cjk = cjk[:0]
+	}
+
+	var prev rune
+	for i, r := range text {
+		switch {
+		case isCJK(r):
+			endWord(i)
+			cjk = append(cjk, i)
+		case unicode.IsMark(r) && (start != -1 || len(cjk) > 0):
+			// Combining marks belong to the preceding rune.
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r):
+			endCJK(i)
+			if start == -1 {
+				start = i
+			}
+		case (r == '.' || r == ',') && start != -1 && unicode.IsDigit(prev) && nextIsDigit(text[i+1:]):
+			// Separator within a number.
+		default:
+			endWord(i)
+			endCJK(i)
+		}
+		prev = r
+	}
+	endWord(len(text))
+	endCJK(len(text))
+	return
+}
+
+// nextIsDigit checks if the text starts with a digit.
+func nextIsDigit(text string) bool {
+	r, _ := utf8.DecodeRuneInString(text)
+	return unicode.IsDigit(r)
+}
+
+// Char filters
+
+// HTMLStripCharFilter replaces HTML tags with spaces, keeping the offsets of the text.
+type HTMLStripCharFilter struct{}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+func (HTMLStripCharFilter) FilterText(text string) string {
+	return htmlTagPattern.ReplaceAllStringFunc(text, func(tag string) string {
+		return strings.Repeat(" ", len(tag))
+	})
+}
+
+// Token filters
+
+// mapTerms replaces the text of each token with fn(text).
+func mapTerms(tokens []Token, fn func(string) string) []Token {
+	for i := range tokens {
+		tokens[i].Text = fn(tokens[i].Text)
+	}
+	return tokens
+}
+
+// LowercaseFilter lowercases tokens.
+type LowercaseFilter struct{}
+
+func (LowercaseFilter) FilterTokens(tokens []Token) []Token {
+	return mapTerms(tokens, strings.ToLower)
+}
+
+func (LowercaseFilter) NormalizeTerm(term string) string {
+	return strings.ToLower(term)
+}
+
+// StopWordFilter removes tokens that are stop words. Removed tokens do not
+// leave a gap, so phrases still match across stop words.
+type StopWordFilter struct {
+	words map[string]bool
+}
+
+// NewStopWordFilter returns a filter that removes the given words.
+// The filter should come after filters that change the case of tokens.
+func NewStopWordFilter(words []string) *StopWordFilter {
+	filter := &StopWordFilter{words: make(map[string]bool)}
+	for _, word := range words {
+		filter.words[word] = true
+	}
+	return filter
+}
+
+// EnglishStopWords are common English words that are rarely useful to search for.
+var EnglishStopWords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "but", "by", "for", "if", "in",
+	"into", "is", "it", "no", "not", "of", "on", "or", "such", "that", "the",
+	"their", "then", "there", "these", "they", "this", "to", "was", "will", "with",
+}
+
+func (f *StopWordFilter) FilterTokens(tokens []Token) []Token {
+	filtered := tokens[:0]
+	for _, token := range tokens {
+		if !f.words[token.Text] {
+			filtered = append(filtered, token)
+		}
+	}
+	return filtered
+}
+
+func (f *StopWordFilter) String() string {
+	words := make([]string, 0, len(f.words))
+	for word := range f.words {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return fmt.Sprintf("StopWordFilter(%s)", strings.Join(words, " "))
+}
+
+// Stemmer reduces a word to its stem.
+type Stemmer interface {
+	Stem(word string) string
+}
+
+// StemFilter replaces tokens with their stems.
+type StemFilter struct {
+	Stemmer Stemmer
+}
+
+func (f StemFilter) FilterTokens(tokens []Token) []Token {
+	return mapTerms(tokens, f.Stemmer.Stem)
+}
+
+func (f StemFilter) String() string {
+	return fmt.Sprintf("StemFilter(%s)", componentName(f.Stemmer))
+}
+
+// LemmaFilter replaces the tokens that are in the table with their lemmas.
+// It comes before a StemFilter to conflate related words that the stemmer
+// does not, such as "analysis" and "analyzing", in the way of the
+// exceptions of the Snowball stemmers.
+type LemmaFilter struct {
+	Lemmas map[string]string
+}
+
+func (f LemmaFilter) FilterTokens(tokens []Token) []Token {
+	return mapTerms(tokens, func(term string) string {
+		if lemma, ok := f.Lemmas[term]; ok {
+			return lemma
+		}
+		return term
+	})
+}
+
+func (f LemmaFilter) String() string {
+	words := make([]string, 0, len(f.Lemmas))
+	for word, lemma := range f.Lemmas {
+		words = append(words, word+":"+lemma)
+	}
+	sort.Strings(words)
+	return fmt.Sprintf("LemmaFilter(%s)", strings.Join(words, " "))
+}
+
+// SStemmer is the "S" stemmer, which only removes plural endings of lowercase words.
+// (Reference) Harman, D. (1991). How effective is suffixing?
+type SStemmer struct{}
+
+func (SStemmer) Stem(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "ies") && !strings.HasSuffix(word, "eies") && !strings.HasSuffix(word, "aies"):
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "es") && !strings.HasSuffix(word, "aes") && !strings.HasSuffix(word, "ees") && !strings.HasSuffix(word, "oes"):
+		return word[:len(word)-1]
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "ss"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// NFKCFilter applies the Unicode NFKC normalization to tokens, which
+// replaces compatibility characters such as full-width letters ("ｋａｐｐａ")
+// and ligatures ("ﬁ") with their usual forms.
+type NFKCFilter struct{}
+
+func (NFKCFilter) FilterTokens(tokens []Token) []Token {
+	return mapTerms(tokens, norm.NFKC.String)
+}
+
+func (NFKCFilter) NormalizeTerm(term string) string {
+	return norm.NFKC.String(term)
+}
+
+// DiacriticFoldingFilter removes diacritics from tokens, e.g. "Schütze"
+// becomes "Schutze" and "Ελλάδα" becomes "Ελλαδα". Letters that are not
+// composed with a diacritic, such as "ø" or "ß", are kept; use the
+// ASCIIFoldingFilter to also replace those.
+type DiacriticFoldingFilter struct{}
+
+func (DiacriticFoldingFilter) FilterTokens(tokens []Token) []Token {
+	return mapTerms(tokens, foldDiacritics)
+}
+
+func (DiacriticFoldingFilter) NormalizeTerm(term string) string {
+	return foldDiacritics(term)
+}
+
+// foldDiacritics decomposes the string and removes the nonspacing marks.
+func foldDiacritics(str string) string {
+	// Transformers keep state, so a new one is needed for each call.
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, str)
+	if err != nil {
+		return str
+	}
+	return folded
+}
+
+// ASCIIFoldingFilter replaces accented Latin letters with their ASCII
+// equivalents, e.g. "Schütze" becomes "Schutze".
+type ASCIIFoldingFilter struct{}
+
+func (ASCIIFoldingFilter) FilterTokens(tokens []Token) []Token {
+	return mapTerms(tokens, foldASCII)
+}
+
+func (ASCIIFoldingFilter) NormalizeTerm(term string) string {
+	return foldASCII(term)
+}
+
+// asciiFolding maps letters to their ASCII equivalents.
+var asciiFolding = func() map[rune]string {
+	table := map[string]string{
+		"ÀÁÂÃÄÅĀĂĄ": "A", "àáâãäåāăą": "a", "Æ": "AE", "æ": "ae",
+		"ÇĆĈĊČ": "C", "çćĉċč": "c", "ĎĐÐ": "D", "ďđð": "d",
+		"ÈÉÊËĒĔĖĘĚ": "E", "èéêëēĕėęě": "e", "ĜĞĠĢ": "G", "ĝğġģ": "g",
+		"ĤĦ": "H", "ĥħ": "h", "ÌÍÎÏĨĪĬĮİ": "I", "ìíîïĩīĭįı": "i",
+		"Ĵ": "J", "ĵ": "j", "Ķ": "K", "ķ": "k", "ĹĻĽĿŁ": "L", "ĺļľŀł": "l",
+		"ÑŃŅŇ": "N", "ñńņň": "n", "ÒÓÔÕÖØŌŎŐ": "O", "òóôõöøōŏő": "o",
+		"Œ": "OE", "œ": "oe", "ŔŖŘ": "R", "ŕŗř": "r", "ŚŜŞŠ": "S", "śŝşš": "s",
+		"ß": "ss", "ŢŤŦ": "T", "ţťŧ": "t", "Þ": "TH", "þ": "th",
+		"ÙÚÛÜŨŪŬŮŰŲ": "U", "ùúûüũūŭůűų": "u", "Ŵ": "W", "ŵ": "w",
+		"ÝŸŶ": "Y", "ýÿŷ": "y", "ŹŻŽ": "Z", "źżž": "z",
+	}
+	folding := make(map[rune]string)
+	for letters, ascii := range table {
+		for _, r := range letters {
+			folding[r] = ascii
+		}
+	}
+	return folding
+}()
+
+// foldASCII replaces the letters of the string that are in asciiFolding.
+func foldASCII(str string) string {
+	var b strings.Builder
+	for _, r := range str {
+		if ascii, ok := asciiFolding[r]; ok {
+			b.WriteString(ascii)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
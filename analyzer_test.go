@@ -0,0 +1,199 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPipelineAnalyzer_Analyze(t *testing.T) {
+	analyzer := &PipelineAnalyzer{
+		CharFilters:  []CharFilter{HTMLStripCharFilter{}},
+		Tokenizer:    LetterDigitTokenizer,
+		TokenFilters: []TokenFilter{LowercaseFilter{}, ASCIIFoldingFilter{}, NewStopWordFilter(EnglishStopWords), StemFilter{SStemmer{}}},
+	}
+	pairs := []struct {
+		text   string
+		tokens []Token
+	}{
+		{"The Kappa statistics", []Token{{"kappa", 4, 9}, {"statistic", 10, 20}}},
+		{"<b>Schütze</b> and Manning", []Token{{"schutze", 3, 11}, {"manning", 20, 27}}},
+		{"Æther-Ölfeld", []Token{{"aether", 0, 6}, {"olfeld", 7, 14}}},
+		{"<p></p>", nil},
+	}
+	for _, pair := range pairs {
+		if tokens := analyzer.Analyze(pair.text); !reflect.DeepEqual(tokens, pair.tokens) {
+			t.Errorf("Wrong tokens for %q: Got %v, Wanted %v.", pair.text, tokens, pair.tokens)
+		}
+	}
+	if got := analyzer.Normalize("SCHÜT*"); got != "schut*" {
+		t.Errorf("Wrong normalized term: Got %q, Wanted %q.", got, "schut*")
+	}
+}
+
+func TestSimpleAnalyzer_Analyze(t *testing.T) {
+	pairs := []struct {
+		str   string
+		token []string
+	}{
+		{"Test string.", []string{"test", "string"}},
+		{"I'm 23 years old.", []string{"i", "m", "23", "years", "old"}},
+		{"3d!e-fg.", []string{"3d", "e", "fg"}},
+	}
+	for _, pair := range pairs {
+		tok := analyzeTerms(SimpleAnalyzer, pair.str)
+		if len(tok) == len(pair.token) {
+			for i := range tok {
+				if tok[i] != pair.token[i] {
+					t.Errorf("Wrong token: Got %s, Wanted %s.", tok[i], pair.token[i])
+				}
+			}
+		} else {
+			t.Errorf("Different number of token: Got %d, Wanted %d.", len(tok), len(pair.token))
+		}
+	}
+}
+
+func TestWildcardTokenizer_Tokenize(t *testing.T) {
+	pairs := []struct {
+		str string
+		token []string
+	}{
+		{"Test string.", []string{"test", "string"}},
+		{"W?ld*rd.", []string{"w?ld*rd"}},
+		{"*me ?? *.", []string{"*me", "??", "*"}},
+	}
+	for _, pair := range pairs {
+		tok := analyzeTerms(NewAnalyzer(wildcardTokenizer, LowercaseFilter{}), pair.str)
+		if len(tok) == len(pair.token) {
+			for i := range tok {
+				if tok[i] != pair.token[i] {
+					t.Errorf("Wrong token: Got %s, Wanted %s.", tok[i], pair.token[i])
+				}
+			}
+		} else {
+			t.Errorf("Different number of token: Got %d, Wanted %d.", len(tok), len(pair.token))
+		}
+	}
+}
+
+func TestUnicodeTokenizer_Tokenize(t *testing.T) {
+	pairs := []struct {
+		text  string
+		terms []string
+	}{
+		{"Cohen's kappa (κ)", []string{"Cohen", "s", "kappa", "κ"}},
+		{"Schütze, Hinrich", []string{"Schütze", "Hinrich"}},
+		{"Code-division multiple access", []string{"Code", "division", "multiple", "access"}},
+		{"π is 3.14, not 3.", []string{"π", "is", "3.14", "not", "3"}},
+		{"1,000 raters", []string{"1,000", "raters"}},
+		{"東京都", []string{"東京", "京都"}},
+		{"日本語のWikipedia", []string{"日本", "本語", "語の", "Wikipedia"}},
+		{"コーヒー", []string{"コー", "ーヒ", "ヒー"}},
+		{"雨 降る", []string{"雨", "降る"}},
+		{"été", []string{"été"}},
+	}
+	for _, pair := range pairs {
+		tokens := UnicodeTokenizer{}.Tokenize(pair.text)
+		var terms []string
+		for _, token := range tokens {
+			if pair.text[token.Start:token.End] != token.Text {
+				t.Errorf("Wrong offsets of %q: %d-%d.", token.Text, token.Start, token.End)
+			}
+			terms = append(terms, token.Text)
+		}
+		if !reflect.DeepEqual(terms, pair.terms) {
+			t.Errorf("Wrong tokens for %q: Got %q, Wanted %q.", pair.text, terms, pair.terms)
+		}
+	}
+}
+
+func TestDefaultAnalyzer_Unicode(t *testing.T) {
+	pairs := []struct {
+		text     string
+		analyzer Analyzer
+		terms    []string
+	}{
+		{"ＫＡＰＰＡ ﬁnds", DefaultAnalyzer, []string{"kappa", "find"}},
+		{"Schütze", DefaultAnalyzer, []string{"schütze"}},
+		{"Schütze Ελλάδα", NewAnalyzer(UnicodeTokenizer{}, LowercaseFilter{}, DiacriticFoldingFilter{}), []string{"schutze", "ελλαδα"}},
+	}
+	for _, pair := range pairs {
+		if terms := analyzeTerms(pair.analyzer, pair.text); !reflect.DeepEqual(terms, pair.terms) {
+			t.Errorf("Wrong terms for %q: Got %q, Wanted %q.", pair.text, terms, pair.terms)
+		}
+	}
+}
+
+func TestSStemmer_Stem(t *testing.T) {
+	pairs := []struct {
+		word string
+		stem string
+	}{
+		{"queries", "query"},
+		{"statistics", "statistic"},
+		{"indices", "indice"},
+		{"shoes", "shoe"},
+		{"analysis", "analysi"},
+		{"corpus", "corpus"},
+		{"class", "class"},
+		{"has", "has"},
+	}
+	for _, pair := range pairs {
+		if stem := (SStemmer{}).Stem(pair.word); stem != pair.stem {
+			t.Errorf("Wrong stem for %q: Got %q, Wanted %q.", pair.word, stem, pair.stem)
+		}
+	}
+}
+
+func TestDefaultAnalyzer_Lemmas(t *testing.T) {
+	pairs := []struct {
+		text  string
+		terms []string
+	}{
+		{"analysis analyses analysing", []string{"analyz", "analyz", "analyz"}},
+		{"Analyzing analyzed", []string{"analyz", "analyz"}},
+		{"paralysis paralyzed", []string{"paralyz", "paralyz"}},
+		{"analyser analyzers", []string{"analyz", "analyz"}},
+		{"Catalysis catalysed catalyzes", []string{"catalyz", "catalyz", "catalyz"}},
+		{"dialyses dialyzing", []string{"dialyz", "dialyz"}},
+		{"electrolyse hydrolysing hydrolyzed", []string{"electrolyz", "hydrolyz", "hydrolyz"}},
+		// Words out of the scope of EnglishLemmas are only stemmed.
+		{"synopsis", []string{"synopsi"}},
+		{"hypothesis hypothesize", []string{"hypothesi", "hypothes"}},
+		{"analyst analytic", []string{"analyst", "analyt"}},
+	}
+	for _, pair := range pairs {
+		if terms := analyzeTerms(DefaultAnalyzer, pair.text); !reflect.DeepEqual(terms, pair.terms) {
+			t.Errorf("Wrong terms for %q: Got %v, Wanted %v.", pair.text, terms, pair.terms)
+		}
+	}
+}
+
+func TestSearcher_SetAnalyzer(t *testing.T) {
+	s := NewSearcher(3, &memoryStorage{docs: []Document{
+		{id: 1, Title: "Kappa statistics", Body: "The statistic of the raters."},
+		{id: 2, Title: "Schütze", Body: "Foundations of statistical natural language processing."},
+	}})
+	s.SetAnalyzer(NewAnalyzer(LetterDigitTokenizer, LowercaseFilter{}, ASCIIFoldingFilter{}, NewStopWordFilter(EnglishStopWords), StemFilter{SStemmer{}}))
+	s.BuildIndices()
+	pairs := []struct {
+		query   string
+		fn      func(string) []int
+		results []int
+	}{
+		{"statistics", s.TermsQuery, []int{1}},
+		{`"statistic of the raters"`, s.TermsQuery, []int{1}},
+		{"the", s.TermsQuery, nil},
+		{"schutze", s.TermsQuery, []int{2}},
+		{"SCHÜTZE && language", s.BooleanQuery, []int{2}},
+		{"stat*", s.WildcardQuery, []int{1, 2}},
+	}
+	for _, pair := range pairs {
+		if res := pair.fn(pair.query); !reflect.DeepEqual(res, pair.results) {
+			t.Errorf("Wrong results for %q: Got %v, Wanted %v.", pair.query, res, pair.results)
+		}
+	}
+	if length := s.docLen.docLength(1); length != 2 {
+		t.Errorf("Wrong document length without stop words: Got %d, Wanted 2.", length)
+	}
+}
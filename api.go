@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MaxResultsPerPage is the largest page size accepted by the JSON API.
+const MaxResultsPerPage = 100
+
+// APIResponse is the response of /api/search.
+type APIResponse struct {
+	Query     string `json:"query"`
+	Algorithm string `json:"algorithm"`
+	TotalHits int    `json:"total_hits"`
+	// TookMillis is the time that the query took in milliseconds.
+	TookMillis float64     `json:"took_ms"`
+	Page       int         `json:"page"`
+	PageSize   int         `json:"page_size"`
+	Results    []APIResult `json:"results"`
+	// Next and Prev are the URLs of the next and previous pages,
+	// which are empty on the last and first page.
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// APIResult is a single document in an APIResponse.
+type APIResult struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	// Score is only set for ranked search algorithms.
+	Score *float64 `json:"score,omitempty"`
+	// MatchedTerms are the terms of the query that the document contains.
+	MatchedTerms []string `json:"matched_terms"`
+	Snippet      Snippet  `json:"snippet"`
+	// Explanation is only set if explain is true.
+	Explanation *Explanation `json:"explanation,omitempty"`
+}
+
+// APIError is the response of /api/search when the request is invalid.
+type APIError struct {
+	Error string `json:"error"`
+	// Position is the byte offset of a syntax error in the query.
+	Position *int `json:"position,omitempty"`
+}
+
+// apiSearchHandler serves the results of a query as JSON. It takes the same
+// q, alg, page, explain and ranking parameters as queryHandler, and the page size as size.
+func (s *Searcher) apiSearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{Error: "method not allowed"})
+		return
+	}
+	params := r.URL.Query()
+	queryString := params.Get("q")
+	if queryString == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Error: "missing query parameter q"})
+		return
+	}
+	searchAlgorithm := params.Get("alg")
+	if searchAlgorithm == "" {
+		searchAlgorithm = "BM25"
+	}
+	fn, ok := s.queryFuncs()[searchAlgorithm]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, APIError{Error: fmt.Sprintf("unknown algorithm %q", searchAlgorithm)})
+		return
+	}
+	analyzer := s.currentAnalyzer()
+	page, err := positiveParam(params.Get("page"), 1)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Error: "invalid page: " + err.Error()})
+		return
+	}
+	size, err := positiveParam(params.Get("size"), ResultsPerPage)
+	if err == nil && size > MaxResultsPerPage {
+		err = fmt.Errorf("must be at most %d", MaxResultsPerPage)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Error: "invalid size: " + err.Error()})
+		return
+	}
+	if err := s.queryError(queryString, searchAlgorithm, analyzer); err != nil {
+		apiErr := APIError{Error: err.Error()}
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			apiErr.Position = &parseErr.Pos
+		}
+		writeJSON(w, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	explain, err := s.parseExplain(searchAlgorithm, params.Get("explain"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Error: err.Error()})
+		return
+	}
+
+	scorer, explainer := s.mapNameToScoringFunc(searchAlgorithm), s.mapNameToExplainFunc(searchAlgorithm)
+	requested, requestedScorer, requestedExplainer, err := s.mapRequestToFuncs(searchAlgorithm, params)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Error: err.Error()})
+		return
+	}
+	if requested != nil {
+		fn, scorer, explainer = requested, requestedScorer, requestedExplainer
+	}
+
+	res := s.Search(queryString, fn, scorer, s.mapNameToTermsFunc(searchAlgorithm))
+	resp := APIResponse{
+		Query:      queryString,
+		Algorithm:  searchAlgorithm,
+		TotalHits:  res.TotalHits,
+		TookMillis: float64(res.Took) / float64(time.Millisecond),
+		Page:       page,
+		PageSize:   size,
+		Results:    []APIResult{},
+	}
+	start, end := pageRange(page, size, len(res.Hits))
+	hits := res.Hits[start:end]
+	terms := s.highlightTerms(queryString, searchAlgorithm, analyzer)
+	for i, doc := range s.storage.Get(hitIDs(hits)) {
+		result := APIResult{
+			ID: hits[i].ID, Title: doc.Title, URL: doc.URL, Score: hits[i].Score,
+			MatchedTerms: hits[i].MatchedTerms, Snippet: MakeSnippet(doc.Body, terms, analyzer),
+		}
+		if result.MatchedTerms == nil {
+			result.MatchedTerms = []string{}
+		}
+		if explain {
+			result.Explanation = s.Explain(queryString, hits[i].ID, explainer)
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	if len(res.Hits) > end {
+		resp.Next = changePageURL(r.URL, page+1)
+	}
+	if page > 1 && start < len(res.Hits) {
+		resp.Prev = changePageURL(r.URL, page-1)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// DefaultCompletions and MaxCompletions are the default and
+// largest number of completions returned by /suggest.
+const (
+	DefaultCompletions = 8
+	MaxCompletions     = 20
+)
+
+// SuggestResponse is the response of /suggest.
+type SuggestResponse struct {
+	Query       string       `json:"query"`
+	Completions []Completion `json:"completions"`
+}
+
+// suggestHandler serves the completions of a query that is being typed as JSON.
+// It takes the prefix as q, the number of completions as size, and enables
+// typo tolerant completions if fuzzy is true.
+func (s *Searcher) suggestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{Error: "method not allowed"})
+		return
+	}
+	params := r.URL.Query()
+	prefix := params.Get("q")
+	if prefix == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Error: "missing query parameter q"})
+		return
+	}
+	size, err := positiveParam(params.Get("size"), DefaultCompletions)
+	if err == nil && size > MaxCompletions {
+		err = fmt.Errorf("must be at most %d", MaxCompletions)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Error: "invalid size: " + err.Error()})
+		return
+	}
+	fuzzy := false
+	if value := params.Get("fuzzy"); value != "" {
+		if fuzzy, err = strconv.ParseBool(value); err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{Error: fmt.Sprintf("invalid fuzzy: %q is not a boolean", value)})
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, SuggestResponse{Query: prefix, Completions: s.Complete(prefix, size, fuzzy)})
+}
+
+// positiveParam parses a positive integer parameter,
+// returning the default value if the parameter is empty.
+func positiveParam(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an integer", value)
+	}
+	if n < 1 {
+		return 0, errors.New("must be positive")
+	}
+	return n, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("Cannot write response:", err)
+	}
+}
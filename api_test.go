@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// getAPISearch requests /api/search with the given query string
+// and decodes the response into v.
+func getAPISearch(t *testing.T, s *Searcher, rawQuery string, v interface{}) int {
+	w := httptest.NewRecorder()
+	s.apiSearchHandler(w, httptest.NewRequest(http.MethodGet, "/api/search?"+rawQuery, nil))
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatalf("%s: cannot decode response: %v", rawQuery, err)
+	}
+	return w.Code
+}
+
+func TestSearcher_APISearch(t *testing.T) {
+	s := SetUpSearcher()
+	var resp APIResponse
+	if code := getAPISearch(t, s, "q=matrix+communication+channel&size=1", &resp); code != http.StatusOK {
+		t.Fatalf("Wrong status: Got %d, Wanted %d.", code, http.StatusOK)
+	}
+	if resp.TotalHits != 2 || len(resp.Results) != 1 || resp.Results[0].ID != 3 {
+		t.Errorf("Wrong results: Got %+v.", resp)
+	}
+	if resp.Results[0].Title != "Code-division multiple access" || resp.Results[0].Score == nil {
+		t.Errorf("Wrong result: Got %+v.", resp.Results[0])
+	}
+	if len(resp.Results[0].Snippet.Fragments) == 0 {
+		t.Errorf("Missing snippet.")
+	}
+	if !reflect.DeepEqual(resp.Results[0].MatchedTerms, []string{"communic", "channel"}) || resp.TookMillis <= 0 {
+		t.Errorf("Wrong matched terms and time: Got %v and %v.", resp.Results[0].MatchedTerms, resp.TookMillis)
+	}
+	if resp.Next != "/api/search?page=2&q=matrix+communication+channel&size=1" || resp.Prev != "" {
+		t.Errorf("Wrong cursors: Got next %q and prev %q.", resp.Next, resp.Prev)
+	}
+
+	resp = APIResponse{}
+	getAPISearch(t, s, "q=matrix+communication+channel&size=1&page=2", &resp)
+	if len(resp.Results) != 1 || resp.Results[0].ID != 2 || resp.Next != "" || resp.Prev == "" {
+		t.Errorf("Wrong second page: Got %+v.", resp)
+	}
+
+	resp = APIResponse{}
+	getAPISearch(t, s, "q=cohen+||+latent&alg=Boolean", &resp)
+	if resp.TotalHits != 2 || resp.Results[0].Score != nil {
+		t.Errorf("Wrong unranked results: Got %+v.", resp)
+	}
+}
+
+func TestSearcher_APISearchWeightParams(t *testing.T) {
+	s := SetUpSearcher()
+	var defaults, tuned APIResponse
+	getAPISearch(t, s, "q=matrix+communication+channel&alg=BM25L", &defaults)
+	if code := getAPISearch(t, s, "q=matrix+communication+channel&alg=BM25L&k1=2&b=1&delta=0", &tuned); code != http.StatusOK {
+		t.Fatalf("Wrong status: Got %d, Wanted %d.", code, http.StatusOK)
+	}
+	if len(tuned.Results) != len(defaults.Results) || len(tuned.Results) == 0 {
+		t.Fatalf("Wrong results: Got %+v, Wanted %+v.", tuned, defaults)
+	}
+	want := s.weightedScores("matrix communication channel", BM25LWeighter{K1: 2, B: 1})
+	for _, result := range tuned.Results {
+		if score := want.scores[want.index[result.ID]]; *result.Score != score {
+			t.Errorf("Wrong score of %d: Got %v, Wanted %v.", result.ID, *result.Score, score)
+		}
+	}
+	if *tuned.Results[0].Score == *defaults.Results[0].Score {
+		t.Errorf("Parameters did not change the score: Got %v.", *tuned.Results[0].Score)
+	}
+
+	var smart APIResponse
+	getAPISearch(t, s, "q=matrix+communication+channel&alg=Classic+TF-IDF&smart=bnn.bnn", &smart)
+	// Binary weights count the query terms in each document.
+	if len(smart.Results) != 2 || *smart.Results[0].Score != 2 || *smart.Results[1].Score != 1 {
+		t.Errorf("Wrong SMART results: Got %+v.", smart)
+	}
+}
+
+func TestSearcher_APISearchErrors(t *testing.T) {
+	pairs := []struct {
+		rawQuery string
+		position int
+	}{
+		{"alg=BM25", -1},
+		{"q=kappa&alg=Unknown", -1},
+		{"q=kappa&page=0", -1},
+		{"q=kappa&page=two", -1},
+		{"q=kappa&size=1000", -1},
+		{"q=kappa+AND+(latent&alg=Boolean", 10},
+		{"q=kappa+/coh(en/&alg=Regex", 6},
+		{"q=kappa&b=2", -1},
+		{"q=kappa&k1=one", -1},
+		{"q=kappa&alg=DPH&c=1", -1},
+		{"q=kappa&alg=Boolean&k1=1", -1},
+		{"q=kappa&alg=Classic+TF-IDF&smart=ltc.ltc", -1},
+		{"q=kappa&alg=Classic+TF-IDF&smart=lnc.ltc&k1=1", -1},
+		{"q=kappa&alg=BM25&smart=lnc.ltc", -1},
+	}
+	s := SetUpSearcher()
+	for _, pair := range pairs {
+		var apiErr APIError
+		if code := getAPISearch(t, s, pair.rawQuery, &apiErr); code != http.StatusBadRequest {
+			t.Errorf("%s: Wrong status: Got %d, Wanted %d.", pair.rawQuery, code, http.StatusBadRequest)
+		}
+		if apiErr.Error == "" {
+			t.Errorf("%s: Missing error message.", pair.rawQuery)
+		}
+		if pair.position != -1 && (apiErr.Position == nil || *apiErr.Position != pair.position) {
+			t.Errorf("%s: Wrong error position: Got %v, Wanted %d.", pair.rawQuery, apiErr.Position, pair.position)
+		}
+	}
+}
+
+func TestSearcher_SuggestHandler(t *testing.T) {
+	s := SetUpSearcher()
+	pairs := []struct {
+		rawQuery string
+		status   int
+		texts    []string
+	}{
+		{"q=latent+sem", http.StatusOK, []string{"Latent semantic analysis", "latent semantic", "latent semantics"}},
+		{"q=kapa&fuzzy=true", http.StatusOK, []string{"kappa"}},
+		{"q=co&size=2", http.StatusOK, []string{"Code-division multiple access", "code"}},
+		{"q=zzz", http.StatusOK, []string{}},
+		{"size=2", http.StatusBadRequest, nil},
+		{"q=co&size=100", http.StatusBadRequest, nil},
+		{"q=co&fuzzy=maybe", http.StatusBadRequest, nil},
+	}
+	for _, pair := range pairs {
+		w := httptest.NewRecorder()
+		s.suggestHandler(w, httptest.NewRequest(http.MethodGet, "/suggest?"+pair.rawQuery, nil))
+		if w.Code != pair.status {
+			t.Errorf("%s: Wrong status: Got %d, Wanted %d.", pair.rawQuery, w.Code, pair.status)
+			continue
+		}
+		if pair.status != http.StatusOK {
+			continue
+		}
+		var resp SuggestResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: cannot decode response: %v", pair.rawQuery, err)
+		}
+		texts := []string{}
+		for _, completion := range resp.Completions {
+			texts = append(texts, completion.Text)
+		}
+		if !reflect.DeepEqual(texts, pair.texts) {
+			t.Errorf("%s: Wrong completions: Got %v, Wanted %v.", pair.rawQuery, texts, pair.texts)
+		}
+	}
+}
+
+func TestSearcher_APISearchMissingDocument(t *testing.T) {
+	s, store := SetUpMemorySearcher()
+	// The storage has lost a document that is still indexed.
+	store.docs = store.docs[:2]
+	var resp APIResponse
+	getAPISearch(t, s, "q=cdma&explain=true", &resp)
+	if len(resp.Results) != 1 || resp.Results[0].ID != 3 {
+		t.Fatalf("Wrong results: Got %+v.", resp.Results)
+	}
+	if e := resp.Results[0].Explanation; e == nil || e.Value != *resp.Results[0].Score {
+		t.Errorf("Wrong explanation: Got %+v, Wanted the score %v.", e, *resp.Results[0].Score)
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CompletionTrie is a trie of the words and document titles that queries
+// are completed with as they are typed. Keys are normalized by the analyzer,
+// so completions match regardless of case and diacritics.
+type CompletionTrie struct {
+	root *completionNode
+	// titles maps the IDs of documents to their titles as they are written.
+	titles map[int]string
+}
+
+type completionNode struct {
+	children map[rune]*completionNode
+	// term is the term of the inverted index that the surface form
+	// ending at the node was produced from, or empty if there is none.
+	term string
+	// title is the title ending at the node as it is written, and
+	// titles is the number of documents with that title.
+	title  string
+	titles int
+}
+
+func NewCompletionTrie() *CompletionTrie {
+	return &CompletionTrie{root: &completionNode{}, titles: make(map[int]string)}
+}
+
+// isEmpty checks if a node can be removed from the trie.
+func (n *completionNode) isEmpty() bool {
+	return len(n.children) == 0 && n.term == "" && n.titles == 0
+}
+
+// find returns the node of the key, or nil if the key is not a prefix of any entry.
+func (t *CompletionTrie) find(key string) *completionNode {
+	node := t.root
+	for _, r := range key {
+		if node = node.children[r]; node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// insert returns the node of the key, adding the nodes that are missing.
+func (t *CompletionTrie) insert(key string) *completionNode {
+	node := t.root
+	for _, r := range key {
+		child := node.children[r]
+		if child == nil {
+			if node.children == nil {
+				node.children = make(map[rune]*completionNode)
+			}
+			child = &completionNode{}
+			node.children[r] = child
+		}
+		node = child
+	}
+	return node
+}
+
+// update applies fn to the node of the key if it exists, then removes the
+// nodes on the path to the key that no longer lead to any entry.
+func (t *CompletionTrie) update(key string, fn func(*completionNode)) {
+	path := []*completionNode{t.root}
+	runes := []rune(key)
+	for _, r := range runes {
+		node := path[len(path)-1].children[r]
+		if node == nil {
+			return
+		}
+		path = append(path, node)
+	}
+	fn(path[len(path)-1])
+	for i := len(runes); i > 0 && path[i].isEmpty(); i-- {
+		delete(path[i-1].children, runes[i-1])
+	}
+}
+
+// addTerm adds a surface form of a term of the inverted index.
+func (t *CompletionTrie) addTerm(surface string, term string) {
+	t.insert(surface).term = term
+}
+
+// removeTerm removes a surface form that was added with addTerm.
+func (t *CompletionTrie) removeTerm(surface string) {
+	t.update(surface, func(node *completionNode) { node.term = "" })
+}
+
+// addTitle adds the title of a document, where key is the normalized title.
+func (t *CompletionTrie) addTitle(docID int, key string, title string) {
+	if key == "" {
+		return
+	}
+	node := t.insert(key)
+	if node.titles == 0 {
+		node.title = title
+	}
+	node.titles++
+	t.titles[docID] = title
+}
+
+// removeTitle removes the title of a document, where key is the normalized title.
+func (t *CompletionTrie) removeTitle(docID int, key string) {
+	if _, ok := t.titles[docID]; !ok {
+		return
+	}
+	delete(t.titles, docID)
+	t.update(key, func(node *completionNode) {
+		if node.titles--; node.titles == 0 {
+			node.title = ""
+		}
+	})
+}
+
+// walk calls fn with the key of each node that starts with the prefix.
+func (t *CompletionTrie) walk(prefix string, fn func(key string, node *completionNode)) {
+	node := t.find(prefix)
+	if node == nil {
+		return
+	}
+	var visit func(key string, node *completionNode)
+	visit = func(key string, node *completionNode) {
+		fn(key, node)
+		for r, child := range node.children {
+			visit(key+string(r), child)
+		}
+	}
+	visit(prefix, node)
+}
+
+// buildCompletionTrie returns the completions of the surface forms and the
+// titles of documents, which are normalized with the analyzer.
+func buildCompletionTrie(surfaceForms map[string][]string, titles map[int]string, analyzer Analyzer) *CompletionTrie {
+	t := NewCompletionTrie()
+	for term, surfaces := range surfaceForms {
+		for _, surface := range surfaces {
+			t.addTerm(surface, term)
+		}
+	}
+	for docID, title := range titles {
+		t.addTitle(docID, analyzer.Normalize(title), title)
+	}
+	return t
+}
+
+// Completion is a completion of a query that is being typed.
+type Completion struct {
+	Text string `json:"text"`
+	// Kind is "title" for the title of a document and "term" for
+	// a query where the last word is completed with an indexed term.
+	Kind string `json:"kind"`
+	// Weight is the number of documents with the title or term.
+	Weight int `json:"weight"`
+	// Distance is the edit distance between the last word and the
+	// start of the term, which is only above 0 for typo tolerant completions.
+	Distance int `json:"distance,omitempty"`
+}
+
+// Complete returns at most n completions of a query that is being typed.
+// Titles that start with the query come first, taking up to half of the
+// completions, and are followed by the query with its last word completed
+// by the terms that start with it. Both are ranked by document frequency.
+// If fuzzy is true, terms that start within the edit distance of getFuzziness
+// from the last word are also included after the ones that start with it.
+func (s *Searcher) Complete(query string, n int, fuzzy bool) []Completion {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	completions := []Completion{}
+	key := s.analyzer.Normalize(strings.TrimLeftFunc(query, unicode.IsSpace))
+	if key == "" || n < 1 {
+		return completions
+	}
+	// seen are the normalized texts of the completions, so that a word
+	// is not repeated when it is also a title.
+	seen := make(map[string]bool)
+	var titles []Completion
+	s.completions.walk(key, func(key string, node *completionNode) {
+		if node.titles > 0 {
+			titles = append(titles, Completion{Text: node.title, Kind: "title", Weight: node.titles})
+			seen[key] = true
+		}
+	})
+	sortCompletions(titles)
+
+	var terms []Completion
+	// The last word is only completed while it is being typed.
+	if last, _ := utf8.DecodeLastRuneInString(query); !unicode.IsSpace(last) {
+		words := strings.Fields(query)
+		word := words[len(words)-1]
+		before := query[:len(query)-len(word)]
+		surface := s.analyzer.Normalize(word)
+		normalizedBefore := s.analyzer.Normalize(before)
+		addTerm := func(surface string, term string, distance int) {
+			if !seen[normalizedBefore+surface] {
+				seen[normalizedBefore+surface] = true
+				terms = append(terms, Completion{Text: before + surface, Kind: "term", Weight: s.ii.DocumentFrequency(term), Distance: distance})
+			}
+		}
+		s.completions.walk(surface, func(key string, node *completionNode) {
+			if node.term != "" {
+				addTerm(key, node.term, 0)
+			}
+		})
+		if fuzzy {
+			for _, candidate := range s.prefixCloseTerms(surface, getFuzziness(surface)) {
+				if node := s.completions.find(candidate.term); node != nil && node.term != "" {
+					addTerm(candidate.term, node.term, candidate.distance)
+				}
+			}
+		}
+		sortCompletions(terms)
+	}
+
+	titleCount := min(len(titles), max(n-len(terms), (n+1)/2))
+	completions = append(completions, titles[:titleCount]...)
+	completions = append(completions, terms[:min(len(terms), n-titleCount)]...)
+	return completions
+}
+
+// closeTerm is a term of the k-gram index and its distance from a query term.
+type closeTerm struct {
+	term     string
+	distance int
+}
+
+// prefixCloseTerms returns the terms of the k-gram index that start within
+// the edit distance of the prefix, excluding the ones that start with it.
+func (s *Searcher) prefixCloseTerms(prefix string, maxEditDistance int) (terms []closeTerm) {
+	if maxEditDistance == 0 {
+		return
+	}
+	// The k-grams of the prefix that are not padded at the end also appear in
+	// the terms that start with it, and each edit changes at most k of them.
+	minOverlap := utf8.RuneCountInString(prefix) - maxEditDistance*s.ki.k
+	for term, overlap := range s.ki.KGramOverlap(prefix) {
+		if overlap < minOverlap || strings.HasPrefix(term, prefix) {
+			continue
+		}
+		if distance := prefixEditDistance(prefix, term); distance <= maxEditDistance {
+			terms = append(terms, closeTerm{term, distance})
+		}
+	}
+	return
+}
+
+// sortCompletions sorts completions by their distance, then from the
+// most frequent, and alphabetically on ties.
+func sortCompletions(completions []Completion) {
+	sort.Slice(completions, func(i, j int) bool {
+		a, b := completions[i], completions[j]
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		return a.Text < b.Text
+	})
+}
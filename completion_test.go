@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// completionKeys returns the keys of the entries in the trie that start with the prefix.
+func completionKeys(trie *CompletionTrie, prefix string) (keys []string) {
+	trie.walk(prefix, func(key string, node *completionNode) {
+		if node.term != "" || node.titles > 0 {
+			keys = append(keys, key)
+		}
+	})
+	sort.Strings(keys)
+	return
+}
+
+func TestCompletionTrie(t *testing.T) {
+	trie := NewCompletionTrie()
+	for _, surface := range []string{"stat", "statistic", "statistics", "state", "kappa"} {
+		trie.addTerm(surface, surface)
+	}
+	trie.addTitle(1, "statistics", "Statistics")
+	trie.addTitle(2, "statistics", "statistics")
+	pairs := []struct {
+		prefix string
+		keys   []string
+	}{
+		{"stat", []string{"stat", "state", "statistic", "statistics"}},
+		{"statis", []string{"statistic", "statistics"}},
+		{"k", []string{"kappa"}},
+		{"x", nil},
+	}
+	for _, pair := range pairs {
+		if keys := completionKeys(trie, pair.prefix); !reflect.DeepEqual(keys, pair.keys) {
+			t.Errorf("Wrong completions of %q: Got %v, Wanted %v.", pair.prefix, keys, pair.keys)
+		}
+	}
+	if node := trie.find("statistics"); node.title != "Statistics" || node.titles != 2 {
+		t.Errorf("Wrong title: Got %q (%d), Wanted %q (2).", node.title, node.titles, "Statistics")
+	}
+
+	trie.removeTerm("statistics")
+	trie.removeTitle(1, "statistics")
+	if keys := completionKeys(trie, "statis"); !reflect.DeepEqual(keys, []string{"statistic", "statistics"}) {
+		t.Errorf("Wrong completions with a remaining title: Got %v.", keys)
+	}
+	trie.removeTitle(2, "statistics")
+	trie.removeTerm("statistic")
+	if node := trie.find("statis"); node != nil {
+		t.Errorf("Removed entries were not pruned: Got %+v.", node)
+	}
+	if keys := completionKeys(trie, "stat"); !reflect.DeepEqual(keys, []string{"stat", "state"}) {
+		t.Errorf("Wrong completions after removal: Got %v.", keys)
+	}
+}
+
+func TestSearcher_Complete(t *testing.T) {
+	s := SetUpSearcher()
+	pairs := []struct {
+		query string
+		n     int
+		fuzzy bool
+		texts []string
+	}{
+		{"co", 4, false, []string{"Code-division multiple access", "Cohen's kappa", "code", "coding"}},
+		{"co", 1, false, []string{"Code-division multiple access"}},
+		{"LATENT sem", 5, false, []string{"Latent semantic analysis", "LATENT semantic", "LATENT semantics"}},
+		{"latent ", 5, false, []string{"Latent semantic analysis"}},
+		{"kapa", 5, false, []string{}},
+		{"kapa", 5, true, []string{"kappa"}},
+		{"statsi", 5, true, []string{"statistic"}},
+		{"   ", 5, true, []string{}},
+	}
+	for _, pair := range pairs {
+		texts := []string{}
+		for _, completion := range s.Complete(pair.query, pair.n, pair.fuzzy) {
+			texts = append(texts, completion.Text)
+		}
+		if !reflect.DeepEqual(texts, pair.texts) {
+			t.Errorf("Wrong completions of %q: Got %v, Wanted %v.", pair.query, texts, pair.texts)
+		}
+	}
+
+	// Terms are ranked by their document frequency.
+	completions := s.Complete("se", 20, false)
+	for i := 1; i < len(completions); i++ {
+		if completions[i].Weight > completions[i-1].Weight {
+			t.Errorf("Wrong order: Got %v before %v.", completions[i-1], completions[i])
+		}
+	}
+	if completions[0].Text != "see" || completions[0].Weight != 2 {
+		t.Errorf("Wrong first completion: Got %+v.", completions[0])
+	}
+
+	// Completions follow the documents that are removed.
+	if err := s.DeleteDocument(1); err != nil {
+		t.Fatal(err)
+	}
+	if completions := s.Complete("coh", 5, false); len(completions) != 0 {
+		t.Errorf("Wrong completions after deletion: Got %v.", completions)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/csv"
 	"io"
 	"log"
+	"net/url"
 	"os"
 	"sort"
 	"strconv"
@@ -18,24 +19,93 @@ type Document struct {
 	URL   string
 }
 
+// documentFields are the names of the fields of a Document that are
+// indexed separately, so that queries can be restricted to a field.
+var documentFields = []string{"title", "body", "url"}
+
+// isDocumentField checks if the name is one of documentFields.
+func isDocumentField(name string) bool {
+	for _, field := range documentFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// field returns the text of the field with the given name.
+func (doc Document) field(name string) string {
+	switch name {
+	case "title":
+		return doc.Title
+	case "body":
+		return doc.Body
+	case "url":
+		// Escaped characters such as %27 would otherwise become tokens.
+		if u, err := url.PathUnescape(doc.URL); err == nil {
+			return u
+		}
+		return doc.URL
+	}
+	return ""
+}
+
 // DocumentLengths stores the lengths of document and total length
 // of the documents.
 type DocumentLengths struct {
-	lengths []int
+	lengths map[int]int
 	totalLength int
 }
 
-// addDocumentLength stores the length of the document.
-func (docLen *DocumentLengths) addDocumentLength(document string) {
-	docLength := wordCount(document)
-	docLen.lengths = append(docLen.lengths, docLength)
+// addDocumentLength stores the length of the document with the given ID,
+// replacing the previous length if the document was already added.
+func (docLen *DocumentLengths) addDocumentLength(docID int, document string) {
+	docLen.setDocumentLength(docID, wordCount(document))
+}
+
+// setDocumentLength stores the given length for the document with the given ID,
+// replacing the previous length if the document was already added.
+func (docLen *DocumentLengths) setDocumentLength(docID int, docLength int) {
+	if docLen.lengths == nil {
+		docLen.lengths = make(map[int]int)
+	}
+	docLen.removeDocumentLength(docID)
+	docLen.lengths[docID] = docLength
 	docLen.totalLength += docLength
+}
+
+// removeDocumentLength removes the length of the document with the given ID.
+func (docLen *DocumentLengths) removeDocumentLength(docID int) {
+	if length, ok := docLen.lengths[docID]; ok {
+		docLen.totalLength -= length
+		delete(docLen.lengths, docID)
+	}
+}
+
+// hasDocument checks if the length of the document with the given ID is stored.
+func (docLen *DocumentLengths) hasDocument(docID int) bool {
+	_, ok := docLen.lengths[docID]
+	return ok
+}
+
+// documentIDs returns the IDs of all stored documents in increasing order.
+func (docLen *DocumentLengths) documentIDs() (ids []int) {
+	ids = make([]int, 0, len(docLen.lengths))
+	for id := range docLen.lengths {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
 	return
 }
 
 // docLength returns the length (word count) of a document.
 func (docLen *DocumentLengths) docLength(docID int) int {
-	return docLen.lengths[docID-1]  // -1 since documents are indexed from 1.
+	return docLen.lengths[docID]
+}
+
+// documentCount returns the number of stored documents.
+func (docLen *DocumentLengths) documentCount() int {
+	return len(docLen.lengths)
 }
 
 // averageDocumentLength returns the average length of stored documents.
@@ -48,6 +118,98 @@ func wordCount(document string) int {
 	return len(strings.Fields(document))
 }
 
+// DocumentTerms stores the unique terms of each field of the documents and
+// their surface forms, so that a removed document is only removed from the
+// postings lists of its own terms.
+type DocumentTerms struct {
+	docs map[int]documentTerms
+	// surfaceFreqs is the number of documents that contain each surface form.
+	surfaceFreqs map[string]int
+}
+
+type documentTerms struct {
+	// fields are the sorted unique terms of each of the documentFields.
+	fields map[string][]string
+	// surfaces are the unique surface forms of the Title and Body, sorted by surface.
+	surfaces []surfaceForm
+}
+
+// surfaceForm is a term of the k-gram index and the term
+// of the inverted index that it is analyzed into.
+type surfaceForm struct {
+	surface string
+	term    string
+}
+
+// setDocumentTerms stores the terms of the document with the given ID, which
+// must not be stored yet. Returns the surface forms that no other document contains.
+func (dt *DocumentTerms) setDocumentTerms(docID int, terms documentTerms) (added []surfaceForm) {
+	if dt.docs == nil {
+		dt.docs = make(map[int]documentTerms)
+		dt.surfaceFreqs = make(map[string]int)
+	}
+	dt.docs[docID] = terms
+	for _, form := range terms.surfaces {
+		dt.surfaceFreqs[form.surface]++
+		if dt.surfaceFreqs[form.surface] == 1 {
+			added = append(added, form)
+		}
+	}
+	return
+}
+
+// removeDocumentTerms removes the terms of the document with the given ID.
+// Returns its terms and the surface forms that no other document contains.
+func (dt *DocumentTerms) removeDocumentTerms(docID int) (terms documentTerms, removed []surfaceForm) {
+	terms, ok := dt.docs[docID]
+	if !ok {
+		return
+	}
+	delete(dt.docs, docID)
+	for _, form := range terms.surfaces {
+		dt.surfaceFreqs[form.surface]--
+		if dt.surfaceFreqs[form.surface] == 0 {
+			delete(dt.surfaceFreqs, form.surface)
+			removed = append(removed, form)
+		}
+	}
+	return
+}
+
+// newDocumentTerms returns the documentTerms of the tokens of each field of a
+// document, where the surface forms of the Title and Body are normalized by normalize.
+func newDocumentTerms(tokens map[string][]Token, doc Document, normalize func(string) string) (terms documentTerms) {
+	terms.fields = make(map[string][]string)
+	for field, fieldTokens := range tokens {
+		var fieldTerms []string
+		for _, token := range fieldTokens {
+			fieldTerms = append(fieldTerms, token.Text)
+		}
+		fieldTerms = uniqueStrings(fieldTerms)
+		sort.Strings(fieldTerms)
+		terms.fields[field] = fieldTerms
+	}
+	seen := make(map[string]bool)
+	for _, field := range []string{"title", "body"} {
+		text := doc.field(field)
+		for _, token := range tokens[field] {
+			surface := normalize(text[token.Start:token.End])
+			if !seen[surface] {
+				seen[surface] = true
+				terms.surfaces = append(terms.surfaces, surfaceForm{surface, token.Text})
+			}
+		}
+	}
+	sort.Slice(terms.surfaces, func(i, j int) bool { return terms.surfaces[i].surface < terms.surfaces[j].surface })
+	return
+}
+
+// indexTerms returns the unique terms of the Title and Body,
+// which are the terms of the document in the inverted index.
+func (terms documentTerms) indexTerms() []string {
+	return uniqueStrings(append(append([]string{}, terms.fields["title"]...), terms.fields["body"]...))
+}
+
 // Functions that reads documents from external sources.
 
 // documentFn defines functions that consumes documents.
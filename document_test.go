@@ -26,9 +26,9 @@ func TestWordCount(t *testing.T) {
 
 func setUpDocumentLengths() (docList *DocumentLengths) {
 	docList = &DocumentLengths{}
-	docList.addDocumentLength("My name is John.")
-	docList.addDocumentLength("  to be  or not    to be")
-	docList.addDocumentLength("Document A: This is a hat. This is a cat.")
+	docList.addDocumentLength(1, "My name is John.")
+	docList.addDocumentLength(2, "  to be  or not    to be")
+	docList.addDocumentLength(3, "Document A: This is a hat. This is a cat.")
 	return
 }
 
@@ -58,6 +58,22 @@ func TestDocumentList_AverageDocumentLength(t *testing.T) {
 	}
 }
 
+func TestDocumentList_RemoveDocumentLength(t *testing.T) {
+	docLen := setUpDocumentLengths()
+	docLen.removeDocumentLength(2)
+	docLen.addDocumentLength(3, "A hat.")
+	if docLen.hasDocument(2) {
+		t.Errorf("Document 2 was not removed.")
+	}
+	if docLen.docLength(3) != 2 {
+		t.Errorf("Wrong length for index 3: Got %d, Wanted 2.", docLen.docLength(3))
+	}
+	avgLen := (4 + 2) / 2.0
+	if docLen.averageDocumentLength() != avgLen {
+		t.Errorf("Wrong average length: Got %f, Wanted %f.", docLen.averageDocumentLength(), avgLen)
+	}
+}
+
 func TestCSVStorage_Apply(t *testing.T) {
 	wanted := []string{"Cohen's kappa", "Latent semantic analysis", "Code-division multiple access"}
 	csvStore := NewCSVStorage("example.csv")
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ExpansionParams are the parameters of the ranking of FuzzyQuery and
+// WildcardQuery, which expand each query term to the terms of the index
+// that are close to it or that match it.
+type ExpansionParams struct {
+	// Weighter weighs the terms that a query term is expanded to in each document.
+	Weighter TermWeighter
+	// MaxExpansions is the largest number of terms that a query term is
+	// expanded to, keeping the terms that are in the most documents.
+	MaxExpansions int
+}
+
+// DefaultExpansionParams weighs the expansions with BM25.
+var DefaultExpansionParams = ExpansionParams{Weighter: DefaultBM25Weighter, MaxExpansions: 50}
+
+// SetExpansionParams sets the parameters used by FuzzyQuery and WildcardQuery.
+func (s *Searcher) SetExpansionParams(params ExpansionParams) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.expansion = params
+}
+
+// termExpansion is a term of the inverted index that a query term is expanded to.
+type termExpansion struct {
+	term string
+	// closeness is how close the term is to the query term, between 0 and 1
+	// for the query term itself, which is the average of the similarity of
+	// their edit distance and the similarity of their k-grams.
+	closeness       float64
+	editSimilarity  float64
+	kgramSimilarity float64
+}
+
+func newTermExpansion(term string, editSimilarity float64, kgramSimilarity float64) termExpansion {
+	return termExpansion{
+		term:            term,
+		closeness:       (editSimilarity + kgramSimilarity) / 2,
+		editSimilarity:  editSimilarity,
+		kgramSimilarity: kgramSimilarity,
+	}
+}
+
+// queryExpansion is a query term and the terms that it is expanded to.
+type queryExpansion struct {
+	queryTerm string
+	terms     []termExpansion
+}
+
+// kgramSimilarity returns the Jaccard coefficient of the k-grams of two strings.
+// The k-grams of wildcard patterns that contain a wildcard are ignored.
+func kgramSimilarity(str1 string, str2 string, k int) float64 {
+	grams := make(map[string]int)
+	for i, str := range []string{str1, str2} {
+		for _, gram := range buildKGrams(str, k) {
+			if !strings.ContainsAny(gram, "*?") {
+				grams[gram] |= 1 << uint(i)
+			}
+		}
+	}
+	if len(grams) == 0 {
+		return 0
+	}
+	shared := 0
+	for _, in := range grams {
+		if in == 3 {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(grams))
+}
+
+// fuzzyExpansions returns the expansions of each query term of FuzzyQuery,
+// which are the terms within the edit distance of getFuzziness.
+func (s *Searcher) fuzzyExpansions(query string) (expansions []queryExpansion) {
+	for _, queryTerm := range s.surfaceTerms(query) {
+		var surfaces []termExpansion
+		for _, surface := range s.ki.GetCloseTerms(queryTerm, getFuzziness(queryTerm)) {
+			length := max(utf8.RuneCountInString(queryTerm), utf8.RuneCountInString(surface))
+			editSimilarity := 1 - float64(editDistance(queryTerm, surface))/float64(length)
+			surfaces = append(surfaces, newTermExpansion(surface, editSimilarity, kgramSimilarity(queryTerm, surface, s.ki.k)))
+		}
+		expansions = append(expansions, queryExpansion{queryTerm, s.expandSurfaces(surfaces)})
+	}
+	return
+}
+
+// wildcardExpansions returns the expansions of each pattern of WildcardQuery,
+// which are the terms that match the pattern. The edit similarity of a term
+// is the fraction of its characters that are not matched by a wildcard.
+func (s *Searcher) wildcardExpansions(query string) (expansions []queryExpansion) {
+	for _, pattern := range s.wildcardPatterns(query) {
+		literals := utf8.RuneCountInString(pattern) - strings.Count(pattern, "*") - strings.Count(pattern, "?")
+		var surfaces []termExpansion
+		for _, surface := range s.wildcardTerms(pattern) {
+			editSimilarity := float64(literals) / float64(utf8.RuneCountInString(surface))
+			surfaces = append(surfaces, newTermExpansion(surface, editSimilarity, kgramSimilarity(pattern, surface, s.ki.k)))
+		}
+		expansions = append(expansions, queryExpansion{pattern, s.expandSurfaces(surfaces)})
+	}
+	return
+}
+
+// wildcardTerms returns the terms of the k-gram index that match the
+// pattern, which are looked up in the selected TermDictionary.
+func (s *Searcher) wildcardTerms(pattern string) []string {
+	if s.permuterm != nil {
+		return s.permuterm.WildcardTerms(pattern)
+	}
+	return s.ki.WildcardTerms(pattern)
+}
+
+// expandSurfaces returns the terms of the inverted index that the terms of the
+// k-gram index were produced from, each as close as its closest surface form.
+// Only the MaxExpansions terms in the most documents are kept.
+func (s *Searcher) expandSurfaces(surfaces []termExpansion) (terms []termExpansion) {
+	closest := make(map[string]termExpansion)
+	for _, surface := range surfaces {
+		for _, term := range s.terms(surface.term) {
+			if e, ok := closest[term]; !ok || surface.closeness > e.closeness {
+				e = surface
+				e.term = term
+				closest[term] = e
+			}
+		}
+	}
+	for _, e := range closest {
+		terms = append(terms, e)
+	}
+	docFreqs := make(map[string]int, len(terms))
+	for _, e := range terms {
+		docFreqs[e.term] = s.ii.DocumentFrequency(e.term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if docFreqs[terms[i].term] != docFreqs[terms[j].term] {
+			return docFreqs[terms[i].term] > docFreqs[terms[j].term]
+		}
+		if terms[i].closeness != terms[j].closeness {
+			return terms[i].closeness > terms[j].closeness
+		}
+		return terms[i].term < terms[j].term
+	})
+	if len(terms) > s.expansion.MaxExpansions {
+		terms = terms[:s.expansion.MaxExpansions]
+	}
+	return
+}
+
+// expansionTerms returns the terms of the inverted index of the expansions.
+func expansionTerms(expansions []queryExpansion) (terms []string) {
+	for _, expansion := range expansions {
+		for _, e := range expansion.terms {
+			terms = append(terms, e.term)
+		}
+	}
+	return
+}
+
+// expansionPostings returns the postings of the terms that a query term is
+// expanded to, and the statistics that they are weighted with. The statistics
+// are those of the term in the most documents, so that rare misspellings,
+// which have a high idf, do not weigh more than the term that was meant.
+func (s *Searcher) expansionPostings(expansion queryExpansion) (docIDs [][]int, freqs [][]int, stats TermStats) {
+	docIDs, freqs = make([][]int, len(expansion.terms)), make([][]int, len(expansion.terms))
+	for i, e := range expansion.terms {
+		docIDs[i], freqs[i] = s.ii.PhrasePostings([]string{e.term})
+		if i == 0 || len(docIDs[i]) > stats.DocFreq {
+			stats = s.termStats("", docIDs[i], freqs[i])
+		}
+	}
+	return
+}
+
+// expandedScores returns the unsorted scores of the documents that contain
+// an expansion of every query term. A query term scores the highest weight
+// of its expansions in the document, where the weight of an expansion is
+// its closeness times its weight by the Weighter of the ExpansionParams.
+func (s *Searcher) expandedScores(expansions []queryExpansion) (resList *ScoringList) {
+	resList = &ScoringList{}
+	if len(expansions) == 0 {
+		return
+	}
+	// Queries with terms return an empty rather than a nil list when
+	// nothing matches, like the other queries that match every term.
+	resList.ids, resList.scores = []int{}, []float64{}
+	scores := make(map[int]float64)
+	// matches is the number of query terms that the document matched so far.
+	matches := make(map[int]int)
+	for i, expansion := range expansions {
+		best := make(map[int]float64)
+		docIDs, freqs, stats := s.expansionPostings(expansion)
+		for k, e := range expansion.terms {
+			for j, docID := range docIDs[k] {
+				stats.Freq, stats.DocLength = float64(freqs[k][j]), float64(s.docLen.docLength(docID))
+				weight := e.closeness * s.expansion.Weighter.Weight(stats)
+				if w, ok := best[docID]; !ok || weight > w {
+					best[docID] = weight
+				}
+			}
+		}
+		for docID, weight := range best {
+			if matches[docID] == i {
+				scores[docID] += weight
+				matches[docID]++
+			}
+		}
+	}
+	docIDs := make([]int, 0, len(scores))
+	for docID := range scores {
+		if matches[docID] == len(expansions) {
+			docIDs = append(docIDs, docID)
+		}
+	}
+	sort.Ints(docIDs)
+	for _, docID := range docIDs {
+		resList.add(docID, scores[docID])
+	}
+	return
+}
+
+// explainExpanded explains the score of a document in FuzzyQuery or WildcardQuery
+// as the sum of the weights of the best expansion of each query term.
+func (s *Searcher) explainExpanded(expansions []queryExpansion, docID int) *Explanation {
+	var details []Explanation
+	for _, expansion := range expansions {
+		var best *Explanation
+		docIDs, freqs, stats := s.expansionPostings(expansion)
+		for k, e := range expansion.terms {
+			i := sort.SearchInts(docIDs[k], docID)
+			if i == len(docIDs[k]) || docIDs[k][i] != docID {
+				continue
+			}
+			stats.Freq, stats.DocLength = float64(freqs[k][i]), float64(s.docLen.docLength(docID))
+			weight := explainWeight(s.expansion.Weighter, stats, "weight of "+e.term)
+			if best != nil && e.closeness*weight.Value <= best.Value {
+				continue
+			}
+			best = &Explanation{
+				Value:       e.closeness * weight.Value,
+				Description: fmt.Sprintf("weight of %q expanded to %q, computed as closeness * weight from:", expansion.queryTerm, e.term),
+				Details: []Explanation{
+					{
+						Value:       e.closeness,
+						Description: "closeness, average of the edit and k-gram similarities",
+						Details: []Explanation{
+							explainValue(e.editSimilarity, "edit similarity"),
+							explainValue(e.kgramSimilarity, "k-gram similarity, Jaccard coefficient of the k-grams"),
+						},
+					},
+					weight,
+				},
+			}
+		}
+		if best == nil {
+			// The document must match every query term.
+			return nil
+		}
+		details = append(details, *best)
+	}
+	return explainSum(details)
+}
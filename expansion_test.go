@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestKGramSimilarity(t *testing.T) {
+	pairs := []struct {
+		str1, str2 string
+		similarity float64
+	}{
+		{"kappa", "kappa", 1},
+		{"kapa", "kappa", 5.0 / 8},
+		{"kappa", "kapa", 5.0 / 8},
+		{"stat*", "statistic", 4.0 / 11},
+		{"abc", "xyz", 0},
+	}
+	for _, pair := range pairs {
+		if similarity := kgramSimilarity(pair.str1, pair.str2, 3); math.Abs(similarity-pair.similarity) > 1e-12 {
+			t.Errorf("Wrong similarity of %s and %s: Got %v, Wanted %v.", pair.str1, pair.str2, similarity, pair.similarity)
+		}
+	}
+}
+
+func TestSearcher_ExpandedQueryRanking(t *testing.T) {
+	s := NewSearcher(3, &memoryStorage{docs: []Document{
+		{id: 1, Title: "Kapustinskiy", Body: "kapustinskiy equation"},
+		{id: 2, Title: "Kappa", Body: "kappa statistic"},
+		{id: 3, Title: "Misspelling", Body: "kapa statistic"},
+		{id: 4, Title: "Fleiss", Body: "kappa of fleiss"},
+	}})
+	s.BuildIndices()
+	pairs := []struct {
+		query   string
+		fn      func(string) []int
+		results []int
+	}{
+		// The exact term ranks above the typo, and the shorter document above the longer.
+		{"kappa", s.FuzzyQuery, []int{2, 4, 3}},
+		{"kapa", s.FuzzyQuery, []int{3, 2, 4}},
+		{"kappa statistic", s.FuzzyQuery, []int{2, 3}},
+		// The term with the most characters matched by '*' ranks last.
+		{"kap*", s.WildcardQuery, []int{2, 3, 4, 1}},
+		{"kap* equation", s.WildcardQuery, []int{1}},
+		{"zzz*", s.WildcardQuery, []int{}},
+	}
+	for _, pair := range pairs {
+		if res := pair.fn(pair.query); !reflect.DeepEqual(res, pair.results) {
+			t.Errorf("Wrong results for %q: Got %v, Wanted %v.", pair.query, res, pair.results)
+		}
+	}
+
+	// Only the expansion in the most documents is kept.
+	s.SetExpansionParams(ExpansionParams{Weighter: DefaultBM25Weighter, MaxExpansions: 1})
+	if res := s.WildcardQuery("kap*"); !reflect.DeepEqual(res, []int{2, 4}) {
+		t.Errorf("Wrong capped results: Got %v, Wanted [2 4].", res)
+	}
+	if terms := s.highlightTerms("kap*", "Wildcard", s.analyzer); !reflect.DeepEqual(terms, []string{"kappa"}) {
+		t.Errorf("Wrong capped terms: Got %v, Wanted [kappa].", terms)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Explanation explains how a score was computed, as a tree where the Value of
+// each node is computed from the Values of its Details as its Description says.
+type Explanation struct {
+	Value       float64       `json:"value"`
+	Description string        `json:"description"`
+	Details     []Explanation `json:"details,omitempty"`
+}
+
+// explainFunc defines methods that take in a query string and a document ID
+// and explain the score of the document, or return nil if it does not match.
+type explainFunc func(string, int) *Explanation
+
+// Explain explains the score of a document for a query with the explainFunc
+// of a ranked search algorithm. Returns nil if the document does not match.
+func (s *Searcher) Explain(query string, docID int, fn explainFunc) *Explanation {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return fn(query, docID)
+}
+
+// explainSum returns the explanation of the sum of the details, which are
+// added in order like the scores of a ScoringList. Returns nil if there are no details.
+func explainSum(details []Explanation) *Explanation {
+	if len(details) == 0 {
+		return nil
+	}
+	sum := 0.0
+	for _, detail := range details {
+		sum += detail.Value
+	}
+	return &Explanation{Value: sum, Description: "sum of:", Details: details}
+}
+
+// explainValue returns the explanation of a value that is not computed from others.
+func explainValue(value float64, description string) Explanation {
+	return Explanation{Value: value, Description: description}
+}
+
+// clauseString returns the clause as it is written in a query,
+// with the tokens of a phrase quoted.
+func clauseString(clause queryClause) string {
+	str := strings.Join(clause.tokens, " ")
+	if len(clause.tokens) != 1 {
+		str = fmt.Sprintf("%q", str)
+	}
+	if clause.field != "" {
+		str = clause.field + ":" + str
+	}
+	return str
+}
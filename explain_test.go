@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// checkSums checks that the value of each sum in the explanation is the sum of its details.
+func checkSums(t *testing.T, e Explanation) {
+	if e.Description == "sum of:" {
+		sum := 0.0
+		for _, detail := range e.Details {
+			sum += detail.Value
+		}
+		if math.Abs(sum-e.Value) > 1e-9 {
+			t.Errorf("Wrong sum: Got %v, Wanted %v.", e.Value, sum)
+		}
+	}
+	for _, detail := range e.Details {
+		checkSums(t, detail)
+	}
+}
+
+func TestSearcher_Explain(t *testing.T) {
+	s := SetUpSearcher()
+	queries := []string{"matrix communication channel", `"kappa statistic" agreement`, "title:kappa is"}
+	for funcName := range s.queryFuncs() {
+		scorer, explainer := s.mapNameToScoringFunc(funcName), s.mapNameToExplainFunc(funcName)
+		if (scorer == nil) != (explainer == nil) {
+			t.Errorf("Wrong explainFunc of %s: Got %v, Wanted a scoringFunc.", funcName, explainer != nil)
+		}
+		if scorer == nil {
+			continue
+		}
+		for _, query := range queries {
+			resList := scorer(query)
+			for _, docID := range []int{1, 2, 3} {
+				e := s.Explain(query, docID, explainer)
+				i, ok := resList.index[docID]
+				if !ok {
+					if e != nil {
+						t.Errorf("%s: Wrong explanation of %d for %q: Got %v, Wanted nil.", funcName, docID, query, e.Value)
+					}
+					continue
+				}
+				if e == nil {
+					t.Errorf("%s: Missing explanation of %d for %q.", funcName, docID, query)
+					continue
+				}
+				// Explanations are computed like the scores, which they equal to the last bit.
+				if e.Value != resList.scores[i] {
+					t.Errorf("%s: Wrong explanation of %d for %q: Got %v, Wanted %v.", funcName, docID, query, e.Value, resList.scores[i])
+				}
+				checkSums(t, *e)
+			}
+		}
+	}
+}
+
+func TestSearcher_APISearchExplain(t *testing.T) {
+	s := SetUpSearcher()
+	pairs := []struct {
+		rawQuery string
+		terms    int
+	}{
+		{"q=matrix+communication+channel&explain=true", 2},
+		{"q=matrix+communication+channel&alg=Classic+TF-IDF&smart=bnn.bnn&explain=1", 2},
+		{"q=matrix+communication+channel&alg=DPH&explain=true", 2},
+	}
+	for _, pair := range pairs {
+		var resp APIResponse
+		if code := getAPISearch(t, s, pair.rawQuery, &resp); code != http.StatusOK {
+			t.Fatalf("Wrong status of %q: Got %d, Wanted %d.", pair.rawQuery, code, http.StatusOK)
+		}
+		result := resp.Results[0]
+		if result.Explanation == nil || len(result.Explanation.Details) != pair.terms {
+			t.Fatalf("Wrong explanation of %q: Got %+v, Wanted %d terms.", pair.rawQuery, result.Explanation, pair.terms)
+		}
+		if result.Explanation.Value != *result.Score {
+			t.Errorf("Wrong explanation of %q: Got %v, Wanted %v.", pair.rawQuery, result.Explanation.Value, *result.Score)
+		}
+	}
+
+	var resp APIResponse
+	getAPISearch(t, s, "q=matrix", &resp)
+	if resp.Results[0].Explanation != nil {
+		t.Errorf("Wrong explanation without explain: Got %+v, Wanted nil.", resp.Results[0].Explanation)
+	}
+	for _, rawQuery := range []string{"q=matrix&alg=Boolean&explain=true", "q=matrix&explain=maybe"} {
+		var apiErr APIError
+		if code := getAPISearch(t, s, rawQuery, &apiErr); code != http.StatusBadRequest || apiErr.Error == "" {
+			t.Errorf("Wrong response to %q: Got %d %+v, Wanted %d.", rawQuery, code, apiErr, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSearcher_QueryHandlerExplain(t *testing.T) {
+	s := SetUpSearcher()
+	pairs := []struct {
+		rawQuery string
+		want     string
+	}{
+		{"q=kappa&explain=true", "length normalization"},
+		{"q=kappa&alg=Query+Likelihood+(Dirichlet)&explain=true", "document length"},
+		{"q=kappa&alg=Phrase&explain=true", "does not score its results"},
+	}
+	for _, pair := range pairs {
+		w := httptest.NewRecorder()
+		s.queryHandler(w, httptest.NewRequest(http.MethodGet, "/?"+pair.rawQuery, nil))
+		if body := w.Body.String(); !strings.Contains(body, pair.want) {
+			t.Errorf("Wrong page for %q: Wanted %q.", pair.rawQuery, pair.want)
+		}
+	}
+}
@@ -2,4 +2,7 @@ module github.com/muraokamasaki/search_engine
 
 go 1.13
 
-require github.com/mattn/go-sqlite3 v2.0.3+incompatible
+require (
+	github.com/mattn/go-sqlite3 v2.0.3+incompatible
+	golang.org/x/text v0.3.8
+)
@@ -1,36 +1,22 @@
 package main
 
-import (
-	"regexp"
-	"strings"
-)
-
-// tokenize strips all non-alphanumeric characters from the text,
-// lowercase it and split into tokens.
-func tokenize(text string) (tokens []string) {
-	re := regexp.MustCompile(`[^a-zA-Z0-9]+`)
-	for _, t := range re.Split(text, -1) {
-		if t != "" {
-			tokens = append(tokens, strings.ToLower(t))
+// uniqueStrings returns the strings in the order of their first occurrence,
+// without duplicates.
+func uniqueStrings(strs []string) (unique []string) {
+	seen := make(map[string]bool)
+	for _, str := range strs {
+		if !seen[str] {
+			seen[str] = true
+			unique = append(unique, str)
 		}
 	}
 	return
 }
 
-// tokenizeWildcard strips all non-alphanumeric characters, excluding
-// the wildcard token '*', from the text. Lowercase and splits into tokens.
-func tokenizeWildcard(text string) (tokens []string) {
-	re := regexp.MustCompile(`[^a-zA-Z0-9*?]+`)
-	for _, t := range re.Split(text, -1) {
-		if t != "" {
-			tokens = append(tokens, strings.ToLower(t))
-		}
-	}
-	return
-}
-
-// editDistance returns the edit (levenshtein) distance between two strings.
-func editDistance(s1 string, s2 string) int {
+// editDistance returns the edit (levenshtein) distance between two strings,
+// counting edits of runes rather than bytes.
+func editDistance(str1 string, str2 string) int {
+	s1, s2 := []rune(str1), []rune(str2)
 	// Initialize empty 2-d slice
 	m := make([][]int, len(s1)+1)
 	_m := make([]int, (len(s1)+1) * (len(s2)+1))
@@ -56,8 +42,34 @@ func editDistance(s1 string, s2 string) int {
 	return m[len(s1)][len(s2)]
 }
 
+// prefixEditDistance returns the smallest edit distance between
+// the prefix and any prefix of the string, counting edits of runes.
+func prefixEditDistance(prefix string, str string) int {
+	p, s := []rune(prefix), []rune(str)
+	// Only the previous row of the matrix of editDistance is kept.
+	prev, cur := make([]int, len(s)+1), make([]int, len(s)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(p); i++ {
+		cur[0] = i
+		for j := 1; j <= len(s); j++ {
+			c := min(prev[j], cur[j-1]) + 1
+			if p[i-1] == s[j-1] {
+				cur[j] = min(prev[j-1], c)
+			} else {
+				cur[j] = min(prev[j-1] + 1, c)
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return min(prev...)
+}
+
 // wildcardMatch checks if the input string matches the wildcard pattern.
-func wildcardMatch(pattern string, str string) bool {
+// Wildcards match runes rather than bytes.
+func wildcardMatch(patternStr string, s string) bool {
+	pattern, str := []rune(patternStr), []rune(s)
 	// Initialize empty 2-d slice
 	m := make([][]bool, len(pattern)+1)
 	_m := make([]bool, (len(pattern)+1) * (len(str)+1))
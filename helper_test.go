@@ -2,52 +2,6 @@ package main
 
 import "testing"
 
-func TestTokenize(t *testing.T) {
-	pairs := []struct {
-		str   string
-		token []string
-	}{
-		{"Test string.", []string{"test", "string"}},
-		{"I'm 23 years old.", []string{"i", "m", "23", "years", "old"}},
-		{"3d!e-fg.", []string{"3d", "e", "fg"}},
-	}
-	for _, pair := range pairs {
-		tok := tokenize(pair.str)
-		if len(tok) == len(pair.token) {
-			for i := range tok {
-				if tok[i] != pair.token[i] {
-					t.Errorf("Wrong token: Got %s, Wanted %s.", tok[i], pair.token[i])
-				}
-			}
-		} else {
-			t.Errorf("Different number of token: Got %d, Wanted %d.", len(tok), len(pair.token))
-		}
-	}
-}
-
-func TestTokenizeWildcard(t *testing.T) {
-	pairs := []struct {
-		str string
-		token []string
-	}{
-		{"Test string.", []string{"test", "string"}},
-		{"W?ld*rd.", []string{"w?ld*rd"}},
-		{"*me ?? *.", []string{"*me", "??", "*"}},
-	}
-	for _, pair := range pairs {
-		tok := tokenizeWildcard(pair.str)
-		if len(tok) == len(pair.token) {
-			for i := range tok {
-				if tok[i] != pair.token[i] {
-					t.Errorf("Wrong token: Got %s, Wanted %s.", tok[i], pair.token[i])
-				}
-			}
-		} else {
-			t.Errorf("Different number of token: Got %d, Wanted %d.", len(tok), len(pair.token))
-		}
-	}
-}
-
 func TestMin(t *testing.T) {
 	pairs := []struct{
 		nums []int
@@ -93,6 +47,8 @@ func TestEditDistance(t *testing.T) {
 		{[]string{"gopher", "python"}, 5},
 		{[]string{"hello", ""}, 5},
 		{[]string{"", "world"}, 5},
+		{[]string{"schütze", "schutze"}, 1},
+		{[]string{"κάππα", "καππα"}, 1},
 	}
 	for _, pair := range pairs {
 		ans := editDistance(pair.str[0], pair.str[1])
@@ -102,6 +58,27 @@ func TestEditDistance(t *testing.T) {
 	}
 }
 
+func TestPrefixEditDistance(t *testing.T) {
+	pairs := []struct{
+		str []string
+		answer int
+	}{
+		{[]string{"stat", "statistic"}, 0},
+		{[]string{"statsi", "statistic"}, 1},
+		{[]string{"sattis", "statistic"}, 2},
+		{[]string{"kapa", "kappa"}, 1},
+		{[]string{"hello", ""}, 5},
+		{[]string{"", "world"}, 0},
+		{[]string{"κάπ", "καππα"}, 1},
+	}
+	for _, pair := range pairs {
+		ans := prefixEditDistance(pair.str[0], pair.str[1])
+		if pair.answer != ans {
+			t.Errorf("Wrong answer for %v: Got %d, Wanted %d.", pair.str, ans, pair.answer)
+		}
+	}
+}
+
 func TestWildcardMatch(t *testing.T) {
 	pairs := []struct{
 		str []string
@@ -118,6 +95,9 @@ func TestWildcardMatch(t *testing.T) {
 		{[]string{"t*er", "time"}, false},
 		{[]string{"*m*", "time"}, true},
 		{[]string{"*m?", "time"}, true},
+		{[]string{"sch?tze", "schütze"}, true},
+		{[]string{"東?", "東京"}, true},
+		{[]string{"?", "東京"}, false},
 	}
 	for _, pair := range pairs {
 		ans := wildcardMatch(pair.str[0], pair.str[1])
@@ -0,0 +1,675 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Index file format.
+//
+// The indices of a Searcher are stored in a single binary file so that
+// they do not have to be rebuilt from the DocumentStorage on every start.
+// All integers are little endian; "uvarint" is the unsigned varint encoding
+// of encoding/binary and strings are a uvarint length followed by bytes.
+//
+//	Header (36 bytes)
+//	  magic     [4]byte  "SEIX"
+//	  version   uint32   indexFormatVersion
+//	  k         uint32   k of the k-gram index
+//	  sections  uint32   number of sections that follow
+//	  documents uint32   number of documents in the DocumentStorage
+//	  storage   uint64   FNV-1a hash of the documents in the DocumentStorage
+//	  analyzer  uint64   FNV-1a hash of the description of the Analyzer
+//	Section (repeated)
+//	  id       uint8    one of the section* constants
+//	  length   uvarint  length of the payload in bytes
+//	  payload  [length]byte
+//	  crc      uint32   CRC-32 (IEEE) of the payload
+//
+// Section payloads:
+//
+//	sectionDocumentLengths  count, then (docID gap, length) per document
+//	sectionInvertedIndex    term count, then per term: term, posting count,
+//	                        then (docID gap, term frequency, position gaps)
+//	                        per posting
+//	sectionFieldIndices     field count, then per field: field name and
+//	                        the field index in the sectionInvertedIndex layout
+//	sectionKGramIndex       term count, then the terms before stemming; the
+//	                        k-grams are rebuilt from the terms when loading
+//	sectionFieldLengths     field count, then per field: field name and
+//	                        the lengths in the sectionDocumentLengths layout
+//	sectionTitles           count, then (docID gap, title) per document; the
+//	                        completions are rebuilt from the titles and the
+//	                        terms of the sectionKGramIndex when loading
+//	sectionDocumentVectors  count, then per document: docID gap, unique terms,
+//	                        largest and total term frequency, then the norms
+//	                        of smartTFs as little endian float64
+//	sectionDocumentTerms    count, then per document: docID gap, then per
+//	                        documentField a term count and the terms, then
+//	                        a surface form count and (surface, term) pairs
+//
+// indexFormatVersion must be incremented whenever the layout of a section
+// changes, files with a different version are rejected. Files whose
+// documents, storage or analyzer differ from those of the Searcher are
+// rejected as stale.
+const indexFormatVersion = 11
+
+const indexHeaderSize = 36
+
+var indexFileMagic = [4]byte{'S', 'E', 'I', 'X'}
+
+const (
+	sectionDocumentLengths uint8 = iota + 1
+	sectionInvertedIndex
+	sectionKGramIndex
+	sectionFieldIndices
+	sectionFieldLengths
+	sectionTitles
+	sectionDocumentVectors
+	sectionDocumentTerms
+)
+
+var (
+	// ErrIndexFormat is returned when a file is not an index file or is corrupted.
+	ErrIndexFormat = errors.New("invalid index file")
+	// ErrIndexVersion is returned when an index file was written by an
+	// incompatible version or with a different k-gram size.
+	ErrIndexVersion = errors.New("incompatible index file")
+	// ErrIndexStale is returned when an index file was built from other
+	// documents or with another analyzer than those of the Searcher.
+	ErrIndexStale = errors.New("stale index file")
+)
+
+// indexFingerprint identifies the documents and the analyzer that indices are built from.
+type indexFingerprint struct {
+	documents uint32
+	storage   uint64
+	analyzer  uint64
+}
+
+// fingerprint returns the indexFingerprint of the storage and the analyzer
+// of the Searcher, which reads every document of the storage.
+func (s *Searcher) fingerprint() (fp indexFingerprint) {
+	if s.storage != nil {
+		h := fnv.New64a()
+		var buf [binary.MaxVarintLen64]byte
+		writeString := func(str string) {
+			h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(str)))])
+			h.Write([]byte(str))
+		}
+		s.storage.Apply(func(doc Document) {
+			fp.documents++
+			h.Write(buf[:binary.PutVarint(buf[:], int64(doc.id))])
+			writeString(doc.Title)
+			writeString(doc.Body)
+			writeString(doc.URL)
+		})
+		fp.storage = h.Sum64()
+	}
+	h := fnv.New64a()
+	h.Write([]byte(componentName(s.analyzer)))
+	fp.analyzer = h.Sum64()
+	return
+}
+
+// SaveIndices writes the indices of the Searcher to the file at path.
+// The file is written to a temporary file first and renamed, so an
+// existing index file is never left half written.
+func (s *Searcher) SaveIndices(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	if err = s.writeIndices(w); err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadIndices replaces the indices of the Searcher with those stored in
+// the file at path. The indices are left unchanged if an error is returned.
+// Errors wrap ErrIndexFormat, ErrIndexVersion or ErrIndexStale when the file
+// cannot be used, in which case the indices should be rebuilt with BuildIndices.
+func (s *Searcher) LoadIndices(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return s.readIndices(bufio.NewReader(f), info.Size())
+}
+
+func (s *Searcher) writeIndices(w io.Writer) error {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	sections := []struct {
+		id     uint8
+		encode func(*indexEncoder)
+	}{
+		{sectionDocumentLengths, s.docLen.encode},
+		{sectionInvertedIndex, s.ii.encode},
+		{sectionKGramIndex, s.ki.encode},
+		{sectionFieldIndices, func(enc *indexEncoder) { encodeFieldIndices(enc, s.fields) }},
+		{sectionFieldLengths, func(enc *indexEncoder) { encodeFieldLengths(enc, s.fieldLen) }},
+		{sectionTitles, func(enc *indexEncoder) { encodeTitles(enc, s.completions.titles) }},
+		{sectionDocumentVectors, s.docVectors.encode},
+		{sectionDocumentTerms, s.docTerms.encode},
+	}
+
+	fp := s.fingerprint()
+	header := make([]byte, indexHeaderSize)
+	copy(header, indexFileMagic[:])
+	binary.LittleEndian.PutUint32(header[4:], indexFormatVersion)
+	binary.LittleEndian.PutUint32(header[8:], uint32(s.ki.k))
+	binary.LittleEndian.PutUint32(header[12:], uint32(len(sections)))
+	binary.LittleEndian.PutUint32(header[16:], fp.documents)
+	binary.LittleEndian.PutUint64(header[20:], fp.storage)
+	binary.LittleEndian.PutUint64(header[28:], fp.analyzer)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	for _, section := range sections {
+		enc := &indexEncoder{}
+		section.encode(enc)
+		enc.writeSection(w, section.id)
+		if enc.err != nil {
+			return enc.err
+		}
+	}
+	return nil
+}
+
+// readIndices reads the indices from the size bytes of r.
+func (s *Searcher) readIndices(r *bufio.Reader, size int64) error {
+	header := make([]byte, indexHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("%w: reading header: %v", ErrIndexFormat, err)
+	}
+	if !bytes.Equal(header[:4], indexFileMagic[:]) {
+		return fmt.Errorf("%w: bad magic number", ErrIndexFormat)
+	}
+	if version := binary.LittleEndian.Uint32(header[4:]); version != indexFormatVersion {
+		return fmt.Errorf("%w: format version %d, want %d", ErrIndexVersion, version, indexFormatVersion)
+	}
+	if k := int(binary.LittleEndian.Uint32(header[8:])); k != s.ki.k {
+		return fmt.Errorf("%w: k-gram size %d, want %d", ErrIndexVersion, k, s.ki.k)
+	}
+	s.mux.RLock()
+	fp := s.fingerprint()
+	s.mux.RUnlock()
+	if documents := binary.LittleEndian.Uint32(header[16:]); documents != fp.documents {
+		return fmt.Errorf("%w: %d documents, want %d", ErrIndexStale, documents, fp.documents)
+	}
+	if binary.LittleEndian.Uint64(header[20:]) != fp.storage {
+		return fmt.Errorf("%w: the documents have changed", ErrIndexStale)
+	}
+	if binary.LittleEndian.Uint64(header[28:]) != fp.analyzer {
+		return fmt.Errorf("%w: built with another analyzer", ErrIndexStale)
+	}
+
+	docLen := &DocumentLengths{}
+	ii := NewInvertedIndexWithCodec(s.ii.codec)
+	ki := NewKGramIndex(s.ki.k)
+	fields := newFieldIndices(s.ii.codec)
+	fieldLen := newFieldLengths()
+	titles := make(map[int]string)
+	docVectors := &DocumentVectors{}
+	docTerms := &DocumentTerms{}
+	decoders := map[uint8]func(*indexDecoder){
+		sectionDocumentLengths: docLen.decode,
+		sectionInvertedIndex:   ii.decode,
+		sectionKGramIndex:      ki.decode,
+		sectionFieldIndices:    func(dec *indexDecoder) { decodeFieldIndices(dec, fields) },
+		sectionFieldLengths:    func(dec *indexDecoder) { decodeFieldLengths(dec, fieldLen) },
+		sectionTitles:          func(dec *indexDecoder) { decodeTitles(dec, titles) },
+		sectionDocumentVectors: docVectors.decode,
+		sectionDocumentTerms:   docTerms.decode,
+	}
+
+	sections := binary.LittleEndian.Uint32(header[12:])
+	left := size - indexHeaderSize
+	for i := uint32(0); i < sections; i++ {
+		id, payload, err := readSection(r, &left)
+		if err != nil {
+			return err
+		}
+		decode, ok := decoders[id]
+		if !ok {
+			return fmt.Errorf("%w: unknown section %d", ErrIndexFormat, id)
+		}
+		dec := &indexDecoder{buf: payload}
+		decode(dec)
+		if dec.err == nil && len(dec.buf) != 0 {
+			dec.err = errors.New("trailing data")
+		}
+		if dec.err != nil {
+			return fmt.Errorf("%w: section %d: %v", ErrIndexFormat, id, dec.err)
+		}
+		delete(decoders, id)
+	}
+	if len(decoders) != 0 {
+		return fmt.Errorf("%w: missing sections", ErrIndexFormat)
+	}
+
+	s.mux.Lock()
+	s.docLen, s.ii, s.ki, s.fields, s.fieldLen = *docLen, *ii, *ki, fields, fieldLen
+	s.docVectors, s.docTerms = *docVectors, *docTerms
+	s.surfaceForms = buildSurfaceForms(ki, s.analyzer)
+	if s.permuterm != nil {
+		s.permuterm = buildPermutermIndex(ki.Terms())
+	}
+	s.completions = *buildCompletionTrie(s.surfaceForms, titles, s.analyzer)
+	s.mux.Unlock()
+	return nil
+}
+
+// readSection reads a single section and verifies its checksum. left is the
+// number of bytes left in the file, which the section must fit in, so that a
+// corrupted length is not allocated.
+func readSection(r *bufio.Reader, left *int64) (id uint8, payload []byte, err error) {
+	if id, err = r.ReadByte(); err != nil {
+		return 0, nil, fmt.Errorf("%w: reading section: %v", ErrIndexFormat, err)
+	}
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: reading section %d: %v", ErrIndexFormat, id, err)
+	}
+	var tmp [binary.MaxVarintLen64]byte
+	*left -= int64(1 + binary.PutUvarint(tmp[:], length))
+	if length > uint64(maxInt-4) || int64(length)+4 > *left {
+		return 0, nil, fmt.Errorf("%w: section %d of %d bytes is longer than the file", ErrIndexFormat, id, length)
+	}
+	*left -= int64(length) + 4
+	payload = make([]byte, length+4)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return 0, nil, fmt.Errorf("%w: reading section %d: %v", ErrIndexFormat, id, err)
+	}
+	payload, crc := payload[:length], binary.LittleEndian.Uint32(payload[length:])
+	if crc32.ChecksumIEEE(payload) != crc {
+		return 0, nil, fmt.Errorf("%w: checksum mismatch in section %d", ErrIndexFormat, id)
+	}
+	return id, payload, nil
+}
+
+// indexEncoder builds the payload of a section.
+type indexEncoder struct {
+	buf bytes.Buffer
+	tmp [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (enc *indexEncoder) writeUvarint(v int) {
+	if v < 0 {
+		enc.err = fmt.Errorf("cannot encode negative value %d", v)
+		return
+	}
+	n := binary.PutUvarint(enc.tmp[:], uint64(v))
+	enc.buf.Write(enc.tmp[:n])
+}
+
+func (enc *indexEncoder) writeString(str string) {
+	enc.writeUvarint(len(str))
+	enc.buf.WriteString(str)
+}
+
+func (enc *indexEncoder) writeFloat64(v float64) {
+	binary.LittleEndian.PutUint64(enc.tmp[:8], math.Float64bits(v))
+	enc.buf.Write(enc.tmp[:8])
+}
+
+// writeSection writes the payload as a section with the given id.
+func (enc *indexEncoder) writeSection(w io.Writer, id uint8) {
+	if enc.err != nil {
+		return
+	}
+	payload := enc.buf.Bytes()
+	header := append([]byte{id}, enc.tmp[:binary.PutUvarint(enc.tmp[:], uint64(len(payload)))]...)
+	crc := make([]byte, 4)
+	binary.LittleEndian.PutUint32(crc, crc32.ChecksumIEEE(payload))
+	for _, b := range [][]byte{header, payload, crc} {
+		if _, err := w.Write(b); err != nil {
+			enc.err = err
+			return
+		}
+	}
+}
+
+// indexDecoder reads values from the payload of a section.
+// The first error is kept and all later reads return zero values.
+type indexDecoder struct {
+	buf []byte
+	err error
+}
+
+func (dec *indexDecoder) readUvarint() int {
+	if dec.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(dec.buf)
+	if n <= 0 || v > uint64(maxInt) {
+		dec.err = errors.New("malformed integer")
+		return 0
+	}
+	dec.buf = dec.buf[n:]
+	return int(v)
+}
+
+func (dec *indexDecoder) readString() string {
+	length := dec.readUvarint()
+	if dec.err != nil {
+		return ""
+	}
+	if length > len(dec.buf) {
+		dec.err = errors.New("string out of range")
+		return ""
+	}
+	str := string(dec.buf[:length])
+	dec.buf = dec.buf[length:]
+	return str
+}
+
+func (dec *indexDecoder) readFloat64() float64 {
+	if dec.err != nil {
+		return 0
+	}
+	if len(dec.buf) < 8 {
+		dec.err = errors.New("float out of range")
+		return 0
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(dec.buf))
+	dec.buf = dec.buf[8:]
+	return v
+}
+
+const maxInt = int(^uint(0) >> 1)
+
+func (docLen *DocumentLengths) encode(enc *indexEncoder) {
+	ids := docLen.documentIDs()
+	enc.writeUvarint(len(ids))
+	prevID := 0
+	for _, docID := range ids {
+		enc.writeUvarint(docID - prevID)
+		enc.writeUvarint(docLen.lengths[docID])
+		prevID = docID
+	}
+}
+
+func (docLen *DocumentLengths) decode(dec *indexDecoder) {
+	docLen.lengths = make(map[int]int)
+	count := dec.readUvarint()
+	prevID := 0
+	for i := 0; i < count && dec.err == nil; i++ {
+		docID := prevID + dec.readUvarint()
+		length := dec.readUvarint()
+		docLen.lengths[docID] = length
+		docLen.totalLength += length
+		prevID = docID
+	}
+}
+
+func (dv *DocumentVectors) encode(enc *indexEncoder) {
+	ids := make([]int, 0, len(dv.vectors))
+	for docID := range dv.vectors {
+		ids = append(ids, docID)
+	}
+	sort.Ints(ids)
+	enc.writeUvarint(len(ids))
+	prevID := 0
+	for _, docID := range ids {
+		v := dv.vectors[docID]
+		enc.writeUvarint(docID - prevID)
+		enc.writeUvarint(v.uniqueTerms)
+		enc.writeUvarint(v.maxFreq)
+		enc.writeUvarint(v.length)
+		for _, norm := range v.norms {
+			enc.writeFloat64(norm)
+		}
+		prevID = docID
+	}
+}
+
+func (dv *DocumentVectors) decode(dec *indexDecoder) {
+	dv.vectors = make(map[int]documentVector)
+	count := dec.readUvarint()
+	prevID := 0
+	for i := 0; i < count && dec.err == nil; i++ {
+		docID := prevID + dec.readUvarint()
+		var v documentVector
+		v.uniqueTerms = dec.readUvarint()
+		v.maxFreq = dec.readUvarint()
+		v.length = dec.readUvarint()
+		for j := range v.norms {
+			v.norms[j] = dec.readFloat64()
+		}
+		dv.vectors[docID] = v
+		dv.totalUniqueTerms += v.uniqueTerms
+		prevID = docID
+	}
+}
+
+func (dt *DocumentTerms) encode(enc *indexEncoder) {
+	ids := make([]int, 0, len(dt.docs))
+	for docID := range dt.docs {
+		ids = append(ids, docID)
+	}
+	sort.Ints(ids)
+	enc.writeUvarint(len(ids))
+	prevID := 0
+	for _, docID := range ids {
+		terms := dt.docs[docID]
+		enc.writeUvarint(docID - prevID)
+		for _, field := range documentFields {
+			enc.writeUvarint(len(terms.fields[field]))
+			for _, term := range terms.fields[field] {
+				enc.writeString(term)
+			}
+		}
+		enc.writeUvarint(len(terms.surfaces))
+		for _, form := range terms.surfaces {
+			enc.writeString(form.surface)
+			enc.writeString(form.term)
+		}
+		prevID = docID
+	}
+}
+
+func (dt *DocumentTerms) decode(dec *indexDecoder) {
+	count := dec.readUvarint()
+	prevID := 0
+	for i := 0; i < count && dec.err == nil; i++ {
+		docID := prevID + dec.readUvarint()
+		terms := documentTerms{fields: make(map[string][]string)}
+		for _, field := range documentFields {
+			var fieldTerms []string
+			n := dec.readUvarint()
+			for j := 0; j < n && dec.err == nil; j++ {
+				fieldTerms = append(fieldTerms, dec.readString())
+			}
+			terms.fields[field] = fieldTerms
+		}
+		n := dec.readUvarint()
+		for j := 0; j < n && dec.err == nil; j++ {
+			surface := dec.readString()
+			terms.surfaces = append(terms.surfaces, surfaceForm{surface, dec.readString()})
+		}
+		dt.setDocumentTerms(docID, terms)
+		prevID = docID
+	}
+}
+
+func (ii *InvertedIndex) encode(enc *indexEncoder) {
+	// Terms are sorted so the same index always produces the same file.
+	terms := make([]string, 0, len(ii.postingsLists))
+	for term := range ii.postingsLists {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+
+	enc.writeUvarint(len(terms))
+	for _, term := range terms {
+		p := ii.postingsLists[term]
+		enc.writeString(term)
+		enc.writeUvarint(p.Len())
+		prevID := 0
+		for it := p.Iterator(); it.Next(); {
+			positions := it.Positions()
+			enc.writeUvarint(it.DocID() - prevID)
+			enc.writeUvarint(len(positions))
+			prevPos := 0
+			for _, pos := range positions {
+				enc.writeUvarint(pos - prevPos)
+				prevPos = pos
+			}
+			prevID = it.DocID()
+		}
+	}
+}
+
+func (ii *InvertedIndex) decode(dec *indexDecoder) {
+	count := dec.readUvarint()
+	for i := 0; i < count && dec.err == nil; i++ {
+		term := dec.readString()
+		postings := dec.readUvarint()
+		if postings > len(dec.buf) {
+			dec.err = errors.New("postings list out of range")
+			return
+		}
+		prevID := 0
+		for j := 0; j < postings && dec.err == nil; j++ {
+			docID := prevID + dec.readUvarint()
+			freq := dec.readUvarint()
+			if freq > len(dec.buf) {
+				dec.err = errors.New("positions out of range")
+				return
+			}
+			prevPos := 0
+			for k := 0; k < freq; k++ {
+				prevPos += dec.readUvarint()
+				ii.addIDToPostingsList(term, docID, prevPos)
+			}
+			prevID = docID
+		}
+	}
+	ii.flush()
+}
+
+func encodeFieldIndices(enc *indexEncoder, fields map[string]*InvertedIndex) {
+	enc.writeUvarint(len(documentFields))
+	for _, field := range documentFields {
+		enc.writeString(field)
+		fields[field].encode(enc)
+	}
+}
+
+// decodeFieldIndices decodes the field indices into the given indices,
+// which must contain an empty index for each of the documentFields.
+func decodeFieldIndices(dec *indexDecoder, fields map[string]*InvertedIndex) {
+	count := dec.readUvarint()
+	if dec.err == nil && count != len(documentFields) {
+		dec.err = fmt.Errorf("got %d fields, want %d", count, len(documentFields))
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < count && dec.err == nil; i++ {
+		field := dec.readString()
+		index, ok := fields[field]
+		if !ok || seen[field] {
+			dec.err = fmt.Errorf("unknown or repeated field %q", field)
+			return
+		}
+		seen[field] = true
+		index.decode(dec)
+	}
+}
+
+func encodeFieldLengths(enc *indexEncoder, fieldLen map[string]*DocumentLengths) {
+	enc.writeUvarint(len(documentFields))
+	for _, field := range documentFields {
+		enc.writeString(field)
+		fieldLen[field].encode(enc)
+	}
+}
+
+// decodeFieldLengths decodes the field lengths into the given lengths,
+// which must contain empty lengths for each of the documentFields.
+func decodeFieldLengths(dec *indexDecoder, fieldLen map[string]*DocumentLengths) {
+	count := dec.readUvarint()
+	if dec.err == nil && count != len(documentFields) {
+		dec.err = fmt.Errorf("got %d fields, want %d", count, len(documentFields))
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < count && dec.err == nil; i++ {
+		field := dec.readString()
+		lengths, ok := fieldLen[field]
+		if !ok || seen[field] {
+			dec.err = fmt.Errorf("unknown or repeated field %q", field)
+			return
+		}
+		seen[field] = true
+		lengths.decode(dec)
+	}
+}
+
+func encodeTitles(enc *indexEncoder, titles map[int]string) {
+	ids := make([]int, 0, len(titles))
+	for docID := range titles {
+		ids = append(ids, docID)
+	}
+	sort.Ints(ids)
+	enc.writeUvarint(len(ids))
+	prevID := 0
+	for _, docID := range ids {
+		enc.writeUvarint(docID - prevID)
+		enc.writeString(titles[docID])
+		prevID = docID
+	}
+}
+
+func decodeTitles(dec *indexDecoder, titles map[int]string) {
+	count := dec.readUvarint()
+	prevID := 0
+	for i := 0; i < count && dec.err == nil; i++ {
+		docID := prevID + dec.readUvarint()
+		titles[docID] = dec.readString()
+		prevID = docID
+	}
+}
+
+func (ki *KGramIndex) encode(enc *indexEncoder) {
+	terms := ki.Terms()
+	enc.writeUvarint(len(terms))
+	for _, term := range terms {
+		enc.writeString(term)
+	}
+}
+
+func (ki *KGramIndex) decode(dec *indexDecoder) {
+	count := dec.readUvarint()
+	for i := 0; i < count && dec.err == nil; i++ {
+		ki.addWordToPostingsList(dec.readString())
+	}
+}
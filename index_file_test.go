@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// saveTestIndices saves the indices into a new temporary directory,
+// which should be removed by the caller.
+func saveTestIndices(t *testing.T, s *Searcher) (dir string, path string) {
+	dir, err := ioutil.TempDir("", "index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(dir, "example.idx")
+	if err := s.SaveIndices(path); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestSearcher_SaveLoadIndices(t *testing.T) {
+	built := SetUpSearcher()
+	dir, path := saveTestIndices(t, built)
+	defer os.RemoveAll(dir)
+
+	loaded := NewSearcher(3, NewCSVStorage("example.csv"))
+	if err := loaded.LoadIndices(path); err != nil {
+		t.Fatal(err)
+	}
+	queries := []string{"cohen", "latent semantic", "statistic that", "matrix communication channel"}
+	for _, query := range queries {
+		want, got := built.BM25Query(query), loaded.BM25Query(query)
+		if len(want) == len(got) {
+			for i := range got {
+				if got[i] != want[i] {
+					t.Errorf("Wrong id for %q: Got %v, Wanted %v.", query, got, want)
+				}
+			}
+		} else {
+			t.Errorf("Different number of results for %q: Got %v, Wanted %v.", query, got, want)
+		}
+	}
+	if !reflect.DeepEqual(loaded.fields, built.fields) {
+		t.Errorf("Field indices differ after loading.")
+	}
+	if !reflect.DeepEqual(loaded.fieldLen, built.fieldLen) {
+		t.Errorf("Field lengths differ after loading.")
+	}
+	if !reflect.DeepEqual(loaded.docVectors, built.docVectors) {
+		t.Errorf("Document vectors differ after loading.")
+	}
+	if !reflect.DeepEqual(loaded.docTerms, built.docTerms) {
+		t.Errorf("Document terms differ after loading.")
+	}
+	if !reflect.DeepEqual(loaded.completions, built.completions) {
+		t.Errorf("Completions differ after loading.")
+	}
+	if got, want := loaded.FuzzyQuery("cohdn"), built.FuzzyQuery("cohdn"); len(got) != 1 || got[0] != want[0] {
+		t.Errorf("Wrong fuzzy results: Got %v, Wanted %v.", got, want)
+	}
+	if loaded.docLen.averageDocumentLength() != built.docLen.averageDocumentLength() {
+		t.Errorf("Wrong average length: Got %f, Wanted %f.",
+			loaded.docLen.averageDocumentLength(), built.docLen.averageDocumentLength())
+	}
+}
+
+func TestSearcher_LoadIndicesErrors(t *testing.T) {
+	dir, path := saveTestIndices(t, SetUpSearcher())
+	defer os.RemoveAll(dir)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := func(name string, fn func(b []byte) []byte) string {
+		b := fn(append([]byte{}, data...))
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	pairs := []struct {
+		name string
+		path string
+		k    int
+		err  error
+	}{
+		{"wrong k", path, 2, ErrIndexVersion},
+		{"wrong version", corrupt("version", func(b []byte) []byte { b[4]++; return b }), 3, ErrIndexVersion},
+		{"wrong magic", corrupt("magic", func(b []byte) []byte { b[0] = 'X'; return b }), 3, ErrIndexFormat},
+		{"wrong checksum", corrupt("checksum", func(b []byte) []byte { b[len(b)-1]++; return b }), 3, ErrIndexFormat},
+		{"truncated", corrupt("truncated", func(b []byte) []byte { return b[:len(b)/2] }), 3, ErrIndexFormat},
+		// The length of the first section follows its id after the header.
+		{"huge section length", corrupt("huge", func(b []byte) []byte {
+			binary.PutUvarint(b[indexHeaderSize+1:], math.MaxUint64)
+			return b
+		}), 3, ErrIndexFormat},
+		{"long section length", corrupt("long", func(b []byte) []byte {
+			binary.PutUvarint(b[indexHeaderSize+1:], uint64(len(b)))
+			return b
+		}), 3, ErrIndexFormat},
+		{"missing file", filepath.Join(dir, "missing"), 3, os.ErrNotExist},
+	}
+	for _, pair := range pairs {
+		s := NewSearcher(pair.k, NewCSVStorage("example.csv"))
+		err := s.LoadIndices(pair.path)
+		if !errors.Is(err, pair.err) {
+			t.Errorf("%s: Got error %v, Wanted %v.", pair.name, err, pair.err)
+		}
+		if s.ii.termCount() != 0 {
+			t.Errorf("%s: Indices were modified after a failed load.", pair.name)
+		}
+	}
+}
+
+func TestSearcher_LoadIndicesStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data, err := ioutil.ReadFile("example.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	csvPath, path := filepath.Join(dir, "example.csv"), filepath.Join(dir, "example.idx")
+	if err := ioutil.WriteFile(csvPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	built := NewSearcher(3, NewCSVStorage(csvPath))
+	built.BuildIndices()
+	if err := built.SaveIndices(path); err != nil {
+		t.Fatal(err)
+	}
+
+	analyzed := NewSearcher(3, NewCSVStorage(csvPath))
+	analyzed.SetAnalyzer(SimpleAnalyzer)
+	if err := analyzed.LoadIndices(path); !errors.Is(err, ErrIndexStale) {
+		t.Errorf("another analyzer: Got error %v, Wanted %v.", err, ErrIndexStale)
+	}
+
+	pairs := []struct {
+		name string
+		data []byte
+	}{
+		{"changed document", bytes.Replace(data, []byte("Cohen"), []byte("Kohen"), 1)},
+		{"added document", append(append([]byte{}, data...), "4,Fleiss' kappa,Kappa for many raters.,https://en.wikipedia.org/wiki/Fleiss%27_kappa\n"...)},
+	}
+	for _, pair := range pairs {
+		if err := ioutil.WriteFile(csvPath, pair.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		s := NewSearcher(3, NewCSVStorage(csvPath))
+		if err := s.LoadIndices(path); !errors.Is(err, ErrIndexStale) {
+			t.Errorf("%s: Got error %v, Wanted %v.", pair.name, err, ErrIndexStale)
+		}
+		if s.ii.termCount() != 0 {
+			t.Errorf("%s: Indices were modified after a failed load.", pair.name)
+		}
+	}
+}
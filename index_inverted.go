@@ -1,135 +1,331 @@
 package main
 
 import (
-    "math"
     "sort"
 )
 
 // Implementation of a inverted index.
 type InvertedIndex struct {
-    // postingsLists maps a term to a list of IDs of documents that contain
-    // that term.
-    postingsLists map[string][]int
-    // docTermFrequency tracks the frequency of terms for a particular document.
-    // The index of the term frequency equal to the index of the docID in postingsList.
-    docTermFrequency map[string][]int
+    // postingsLists maps a term to the compressed list of IDs of documents
+    // that contain that term, together with the positions of the term in
+    // each document. The number of positions is the frequency of the term
+    // in that document.
+    postingsLists map[string]*Postings
+    // codec compresses the postings lists.
+    codec PostingsCodec
+    // unflushed are the terms whose postings lists have postings
+    // that are not compressed yet.
+    unflushed map[string]bool
+    // collectionLength is the number of positions in all postings lists,
+    // which is the total length of the indexed documents.
+    collectionLength int
 }
 
 func NewInvertedIndex() *InvertedIndex {
-    return &InvertedIndex{postingsLists: make(map[string][]int), docTermFrequency: make(map[string][]int)}
+    return NewInvertedIndexWithCodec(VByteCodec)
 }
 
-// addIDToPostingsList adds the given document ID to the postings list
-// of a term in the inverted index.
-// (Important) Assumes that terms are added in increasing order of docID.
-func (ii *InvertedIndex) addIDToPostingsList(term string, docID int) {
+// NewInvertedIndexWithCodec returns an empty inverted index that
+// compresses postings lists with the given codec.
+func NewInvertedIndexWithCodec(codec PostingsCodec) *InvertedIndex {
+    return &InvertedIndex{postingsLists: make(map[string]*Postings), codec: codec, unflushed: make(map[string]bool)}
+}
+
+// addIDToPostingsList adds the given document ID and the position of
+// the term in that document to the postings list of a term in the inverted index.
+// Adding terms in increasing order of docID only appends to the postings
+// list, other documents are inserted to keep the list sorted.
+// The appended postings are compressed when flush is called.
+func (ii *InvertedIndex) addIDToPostingsList(term string, docID int, position int) {
     if len(term) > 0 {
-        pList := ii.PostingsList(term)
-        if len(pList) == 0 || pList[len(pList) - 1] != docID {
-            ii.postingsLists[term] = append(pList, docID)
-            ii.docTermFrequency[term] = append(ii.docTermFrequency[term], 1)
-        } else if pList[len(pList) - 1] == docID {
-            ii.docTermFrequency[term][len(pList) - 1]++
+        p, ok := ii.postingsLists[term]
+        if !ok {
+            p = newPostings(ii.codec)
+            ii.postingsLists[term] = p
         }
+        totalFreq := p.TotalFreq()
+        p.add(docID, position)
+        ii.collectionLength += p.TotalFreq() - totalFreq
+        ii.unflushed[term] = true
     }
 }
 
+// flush compresses the postings that were appended to the postings lists.
+func (ii *InvertedIndex) flush() {
+    for term := range ii.unflushed {
+        if p, ok := ii.postingsLists[term]; ok {
+            p.flush()
+        }
+    }
+    ii.unflushed = make(map[string]bool)
+}
+
+// setCodec recompresses all postings lists with the codec.
+func (ii *InvertedIndex) setCodec(codec PostingsCodec) {
+    ii.codec = codec
+    for term, p := range ii.postingsLists {
+        recoded := newPostings(codec)
+        for i := range p.blocks {
+            recoded.tail = append(recoded.tail, p.decodeBlock(i)...)
+        }
+        recoded.tail = append(recoded.tail, p.tail...)
+        recoded.length = p.length
+        recoded.totalFreq = p.totalFreq
+        recoded.flush()
+        ii.postingsLists[term] = recoded
+    }
+}
+
+// removeID removes the given document ID from the postings lists of the
+// given terms, which are the terms of the document.
+// Returns the terms that no longer appear in any document.
+func (ii *InvertedIndex) removeID(docID int, terms []string) (removed []string) {
+    for _, term := range terms {
+        p, ok := ii.postingsLists[term]
+        if !ok {
+            continue
+        }
+        totalFreq := p.TotalFreq()
+        removedDoc := p.remove(docID)
+        ii.collectionLength -= totalFreq - p.TotalFreq()
+        if removedDoc && p.Len() == 0 {
+            delete(ii.postingsLists, term)
+            delete(ii.unflushed, term)
+            removed = append(removed, term)
+        }
+    }
+    return
+}
+
+// insertAt inserts the value into the slice at the given index.
+func insertAt(arr []int, idx int, value int) []int {
+    arr = append(arr, 0)
+    copy(arr[idx + 1:], arr[idx:])
+    arr[idx] = value
+    return arr
+}
+
+// PostingsList returns the decoded IDs of documents that contain the term.
 func (ii *InvertedIndex) PostingsList(term string) (plist []int) {
-    return ii.postingsLists[term]
+    if p, ok := ii.postingsLists[term]; ok {
+        plist = p.docIDs()
+    }
+    return
+}
+
+// Iterator returns an iterator over the postings list of the term,
+// which is empty if the term is not in the index.
+func (ii *InvertedIndex) Iterator(term string) *PostingsIterator {
+    if p, ok := ii.postingsLists[term]; ok {
+        return p.Iterator()
+    }
+    return newPostings(ii.codec).Iterator()
+}
+
+// DocumentFrequency returns the number of documents the term appears in.
+func (ii *InvertedIndex) DocumentFrequency(term string) int {
+    if p, ok := ii.postingsLists[term]; ok {
+        return p.Len()
+    }
+    return 0
+}
+
+// CollectionFrequency returns the number of times the term appears in all documents.
+func (ii *InvertedIndex) CollectionFrequency(term string) int {
+    if p, ok := ii.postingsLists[term]; ok {
+        return p.TotalFreq()
+    }
+    return 0
+}
+
+// termCount returns the number of terms in the index.
+func (ii *InvertedIndex) termCount() int {
+    return len(ii.postingsLists)
 }
 
 // Intersect returns the IDs of documents that contain all the terms,
 // i.e., the intersection of the postings lists of the given terms.
 func (ii *InvertedIndex) Intersect(terms []string) (result []int) {
-    sort.Slice(terms, func(i, j int) bool { return len(ii.postingsLists[terms[i]]) < len(ii.postingsLists[terms[j]]) })
-    result = ii.PostingsList(terms[0])
-    pointer := 1
-    for pointer < len(terms) && len(result) != 0 {
-        result = IntersectPosting(result, ii.PostingsList(terms[pointer]))
-        pointer++
+    if len(terms) == 0 {
+        return
     }
-    return
+    return collectDocs(ii.conjunction(terms))
+}
+
+// conjunction returns an iterator over the documents that contain all the terms.
+func (ii *InvertedIndex) conjunction(terms []string) *conjunctionIterator {
+    // The shortest postings lists lead so that the longer ones skip the most.
+    terms = append([]string{}, terms...)
+    sort.Slice(terms, func(i, j int) bool { return ii.DocumentFrequency(terms[i]) < ii.DocumentFrequency(terms[j]) })
+    its := make([]DocIterator, len(terms))
+    for i, term := range terms {
+        its[i] = ii.Iterator(term)
+    }
+    return newConjunctionIterator(its)
 }
 
 // IntersectPosting returns the intersection of two postings lists.
 func IntersectPosting(plist1 []int, plist2 []int) (result []int) {
     // plist1 and plist 2 are assumed to be sorted.
-    result = []int{}
-    pointer1, pointer2 := 0, 0
-    for pointer1 < len(plist1) && pointer2 < len(plist2) {
-        docID1 := plist1[pointer1]
-        docID2 := plist2[pointer2]
-        if docID1 == docID2 {
-            result = append(result, docID1)
-            pointer1++
-            pointer2++
-        } else if docID1 < docID2 {
-            pointer1++
-        } else {
-            pointer2++
-        }
+    if len(plist2) < len(plist1) {
+        plist1, plist2 = plist2, plist1
     }
-    return
+    return collectDocs(newConjunctionIterator([]DocIterator{newSliceIterator(plist1), newSliceIterator(plist2)}))
 }
 
 // Union returns the IDs of documents that contains at least one term,
 // i.e., the union of the postings lists of the given terms.
 func (ii *InvertedIndex) Union(terms []string) (result []int) {
-    set := make(map[int]bool)
-    for _, term := range terms {
-        for _, id := range ii.PostingsList(term) {
-            set[id] = true
-        }
-    }
-    result = make([]int, len(set))
-    i := 0
-    for k := range set {
-        result[i] = k
-        i++
+    its := make([]DocIterator, len(terms))
+    for i, term := range terms {
+        its[i] = ii.Iterator(term)
     }
-    sort.Ints(result)
-    return
+    return collectDocs(newDisjunctionIterator(its))
 }
 
 // UnionPosting returns the union of two postings lists.
 func UnionPosting(plist1 []int, plist2 []int) (result []int) {
     // plist1 and plist2 are assumed to be sorted.
-    set := make(map[int]bool)
-    for _, id := range plist1 {
-        set[id] = true
+    return collectDocs(newDisjunctionIterator([]DocIterator{newSliceIterator(plist1), newSliceIterator(plist2)}))
+}
+
+// TermFrequency returns the number of times the given term appears
+// in the document.
+func (ii *InvertedIndex) TermFrequency(term string, docID int) int {
+    return len(ii.Positions(term, docID))
+}
+
+// Positions returns the positions of the given term in the document.
+func (ii *InvertedIndex) Positions(term string, docID int) []int {
+    if p, ok := ii.postingsLists[term]; ok {
+        return p.find(docID)
     }
-    for _, id := range plist2 {
-        set[id] = true
+    return nil
+}
+
+// PhrasePostings returns the IDs of documents that contain the terms
+// as an exact sequence, together with the number of times the sequence
+// appears in each document.
+func (ii *InvertedIndex) PhrasePostings(terms []string) (docIDs []int, freqs []int) {
+    if len(terms) == 0 {
+        return
     }
-    result = make([]int, len(set))
-    i := 0
-    for k := range set {
-        result[i] = k
-        i++
+    it := ii.PhraseIterator(terms)
+    for it.Next() {
+        docIDs = append(docIDs, it.DocID())
+        freqs = append(freqs, it.Freq())
     }
-    sort.Ints(result)
     return
 }
 
-// TermFrequency returns the number of times the given term appears
-// in the document.
-func (ii *InvertedIndex) TermFrequency(term string, docID int) int {
-    for idx, id := range ii.PostingsList(term) {
-        if id == docID {
-            return ii.docTermFrequency[term][idx]
+// PhraseIterator returns an iterator over the documents that contain
+// the terms as an exact sequence.
+func (ii *InvertedIndex) PhraseIterator(terms []string) *phraseIterator {
+    postings := make([]*PostingsIterator, len(terms))
+    its := make([]DocIterator, len(terms))
+    for i, term := range terms {
+        postings[i] = ii.Iterator(term)
+        its[i] = postings[i]
+    }
+    return &phraseIterator{conjunctionIterator: *newConjunctionIterator(its), postings: postings}
+}
+
+// phraseIterator iterates over the documents of the conjunction of
+// the postings of a phrase where the terms are also in sequence.
+type phraseIterator struct {
+    conjunctionIterator
+    postings []*PostingsIterator
+    freq int
+}
+
+func (it *phraseIterator) Next() bool {
+    return it.conjunctionIterator.Next() && it.skipMismatches()
+}
+
+func (it *phraseIterator) Advance(target int) bool {
+    return it.conjunctionIterator.Advance(target) && it.skipMismatches()
+}
+
+// skipMismatches moves to the next document where the phrase appears.
+func (it *phraseIterator) skipMismatches() bool {
+    for {
+        it.freq = 0
+        for _, start := range it.postings[0].Positions() {
+            match := true
+            for offset := 1; offset < len(it.postings) && match; offset++ {
+                match = containsSorted(it.postings[offset].Positions(), start + offset)
+            }
+            if match {
+                it.freq++
+            }
+        }
+        if it.freq > 0 {
+            return true
+        }
+        if !it.conjunctionIterator.Next() {
+            return false
         }
     }
-    return 0
 }
 
-// InverseDocumentFrequency returns the inverse document frequency for
-// a given term. Document frequency of a term is the number of documents
-// the given term appears in.
-func (ii *InvertedIndex) InverseDocumentFrequency(term string) float64 {
-    docFreq := len(ii.PostingsList(term))
-    N := len(ii.postingsLists)
-    if N == 0 || docFreq == 0 {
-        return 0
+// Freq returns the number of times the phrase appears in the current document.
+func (it *phraseIterator) Freq() int {
+    return it.freq
+}
+
+// ProximityPostings returns the IDs of documents where the two terms
+// appear within k positions of each other. If ordered, term2 must
+// also appear after term1.
+func (ii *InvertedIndex) ProximityPostings(term1 string, term2 string, k int, ordered bool) (docIDs []int) {
+    it1, it2 := ii.Iterator(term1), ii.Iterator(term2)
+    for it := newConjunctionIterator([]DocIterator{it1, it2}); it.Next(); {
+        if withinDistance(it1.Positions(), it2.Positions(), k, ordered) {
+            docIDs = append(docIDs, it.DocID())
+        }
+    }
+    return
+}
+
+// withinDistance checks if there are a pair of positions from the two sorted lists
+// that are at most k apart. If ordered, the position from pos2 must be larger.
+func withinDistance(pos1 []int, pos2 []int, k int, ordered bool) bool {
+    pointer1, pointer2 := 0, 0
+    for pointer1 < len(pos1) && pointer2 < len(pos2) {
+        distance := pos2[pointer2] - pos1[pointer1]
+        if distance > 0 && distance <= k {
+            return true
+        } else if !ordered && distance < 0 && distance >= -k {
+            return true
+        }
+        if distance > 0 {
+            pointer1++
+        } else {
+            pointer2++
+        }
+    }
+    return false
+}
+
+// proximityAccumulator sums 1/d^2 over all pairs of positions from the two
+// sorted lists that are at most window positions apart, where d is their distance.
+func proximityAccumulator(pos1 []int, pos2 []int, window int) (acc float64) {
+    start := 0
+    for _, p1 := range pos1 {
+        for start < len(pos2) && pos2[start] < p1 - window {
+            start++
+        }
+        for i := start; i < len(pos2) && pos2[i] <= p1 + window; i++ {
+            if d := pos2[i] - p1; d != 0 {
+                acc += 1 / float64(d * d)
+            }
+        }
     }
-    return math.Log10(float64(N) / float64(docFreq))
-}
\ No newline at end of file
+    return
+}
+
+// containsSorted checks if the value exists in the sorted slice.
+func containsSorted(arr []int, value int) bool {
+    idx := sort.SearchInts(arr, value)
+    return idx < len(arr) && arr[idx] == value
+}
+
@@ -1,21 +1,29 @@
 package main
 
 import (
+	"sort"
 	"strings"
+	"unicode/utf8"
 )
 
 // Implementation of a k-gram index.
 type KGramIndex struct {
 	k             int
 	postingsLists map[string][]string
+	// terms is the set of terms in the index.
+	terms map[string]bool
 }
 
 func NewKGramIndex(k int) *KGramIndex {
-	return &KGramIndex{k: k, postingsLists: make(map[string][]string)}
+	return &KGramIndex{k: k, postingsLists: make(map[string][]string), terms: make(map[string]bool)}
 }
 
 // addWordToPostingsList builds the k-gram index for the given term.
 func (ki *KGramIndex) addWordToPostingsList(term string) {
+	if ki.terms[term] {
+		return
+	}
+	ki.terms[term] = true
 	m := buildKGrams(term, ki.k)
 	for _, gram := range m {
 		pList := ki.postingsLists[gram]
@@ -25,6 +33,33 @@ func (ki *KGramIndex) addWordToPostingsList(term string) {
 	}
 }
 
+// hasTerm checks if the term is in the index.
+func (ki *KGramIndex) hasTerm(term string) bool {
+	return ki.terms[term]
+}
+
+// removeWordFromPostingsList removes the given term from the k-gram index.
+func (ki *KGramIndex) removeWordFromPostingsList(term string) {
+	if !ki.terms[term] {
+		return
+	}
+	delete(ki.terms, term)
+	for _, gram := range buildKGrams(term, ki.k) {
+		pList := ki.postingsLists[gram]
+		for i, t := range pList {
+			if t == term {
+				pList = append(pList[:i], pList[i+1:]...)
+				break
+			}
+		}
+		if len(pList) == 0 {
+			delete(ki.postingsLists, gram)
+		} else {
+			ki.postingsLists[gram] = pList
+		}
+	}
+}
+
 // matchInArray checks if a string exists in the slice.
 func matchInArray(arr []string, value string) bool {
 	for _, v := range arr {
@@ -35,20 +70,21 @@ func matchInArray(arr []string, value string) bool {
 	return false
 }
 
-// buildKGrams generate k-grams (padded with '$') from a given string.
-func buildKGrams(str string, k int) (grams []string) {
+// buildKGrams generate k-grams (padded with '$') of runes from a given string.
+func buildKGrams(s string, k int) (grams []string) {
+	str := []rune(s)
 	if len(str) < k - 1{
-		grams = []string{str}
+		grams = []string{s}
 		return
 	}
 	grams = make([]string, len(str) + k - 1)
 	for i := 0; i < len(str) - k + 1; i++ {
-		grams[i] = str[i : i+k]
+		grams[i] = string(str[i : i+k])
 	}
 	for i := 0; i < k - 1; i++ {
 		padding := strings.Repeat("$", i + 1)
-		grams[i + len(str)] = padding + str[: k - i - 1]
-		grams[len(str) - i - 1] = str[len(str) - k + i + 1:] + padding
+		grams[i + len(str)] = padding + string(str[: k - i - 1])
+		grams[len(str) - i - 1] = string(str[len(str) - k + i + 1:]) + padding
 	}
 	return
 }
@@ -89,10 +125,63 @@ func (ki *KGramIndex) KGramMatch(str string) (terms []string) {
 	return
 }
 
+// WildcardTerms returns the terms that match the wildcard pattern,
+// which are the terms of KGramMatch that are checked against the pattern.
+func (ki *KGramIndex) WildcardTerms(pattern string) (terms []string) {
+	for _, term := range ki.KGramMatch(pattern) {
+		if wildcardMatch(pattern, term) {
+			terms = append(terms, term)
+		}
+	}
+	return
+}
+
+// termsWithKGrams returns the terms that contain all of the k-grams,
+// intersecting the postings lists from the shortest.
+func (ki *KGramIndex) termsWithKGrams(grams []string) (terms []string) {
+	if len(grams) == 0 {
+		return
+	}
+	lists := make([][]string, len(grams))
+	for i, g := range grams {
+		lists[i] = ki.postingsLists[g]
+	}
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+	terms = append(terms, lists[0]...)
+	for _, pList := range lists[1:] {
+		if len(terms) == 0 {
+			break
+		}
+		inList := make(map[string]bool, len(pList))
+		for _, t := range pList {
+			inList[t] = true
+		}
+		matched := terms[:0]
+		for _, t := range terms {
+			if inList[t] {
+				matched = append(matched, t)
+			}
+		}
+		terms = matched
+	}
+	return
+}
+
+// shortTerms returns the terms that are too short to have k-grams
+// other than the term itself, which are shorter than k - 1 runes.
+func (ki *KGramIndex) shortTerms() (terms []string) {
+	for term := range ki.terms {
+		if utf8.RuneCountInString(term) < ki.k - 1 {
+			terms = append(terms, term)
+		}
+	}
+	return
+}
+
 // lowerBoundKGramOverlap finds the lower bound of matching k-gram terms
 // between strings such that they are within the given edit distance.
 func lowerBoundKGramOverlap(s1 string, s2 string, maxEditDistance int, k int) int {
-	return max(len(s1), len(s2)) - 1 - (maxEditDistance - 1) * k
+	return max(utf8.RuneCountInString(s1), utf8.RuneCountInString(s2)) - 1 - (maxEditDistance - 1) * k
 }
 
 // GetCloseTerms returns terms that are within a given edit distance from the input string.
@@ -106,3 +195,12 @@ func (ki *KGramIndex) GetCloseTerms(str string, maxEditDistance int) (terms []st
 	}
 	return
 }
+
+// Terms returns all terms in the k-gram index in sorted order.
+func (ki *KGramIndex) Terms() (terms []string) {
+	for term := range ki.terms {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	return
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// TermDictionary selects the index of terms that the patterns of
+// WildcardQuery are looked up in.
+type TermDictionary int
+
+const (
+	// KGramDictionary looks up the terms that contain every k-gram of the
+	// pattern in the k-gram index, and checks each of them against the pattern.
+	KGramDictionary TermDictionary = iota
+	// PermutermDictionary looks up the rotations of the pattern in a
+	// PermutermIndex, which needs no check for patterns with a single '*'
+	// or infix patterns such as *tat*.
+	PermutermDictionary
+)
+
+func (d TermDictionary) String() string {
+	if d == PermutermDictionary {
+		return "Permuterm"
+	}
+	return "k-gram"
+}
+
+// permutermEnd marks the end of a term in its rotations.
+const permutermEnd = "$"
+
+// A PermutermIndex is a term dictionary of every rotation of each term
+// followed by permutermEnd, e.g. hello$, ello$h, ..., $hello, in sorted order.
+// A pattern with a single '*' is rotated so that the '*' is at its end, e.g.
+// h*o to o$h*, and the terms that match it are the range of rotations with
+// that prefix, which is found by binary search.
+// (Reference) Manning, C. D., Raghavan, P., & Schütze, H. (2008). Introduction to Information Retrieval, Section 3.2.1.
+type PermutermIndex struct {
+	// rotations are the rotations of the flushed terms in sorted order.
+	rotations []string
+	// unflushed are the rotations of the terms added since the last flush,
+	// which are merged into rotations by flush.
+	unflushed []string
+	// terms is the set of terms in the index.
+	terms map[string]bool
+	// removed are the terms removed since the last flush, whose
+	// rotations are compacted out of rotations and unflushed by flush.
+	removed map[string]bool
+}
+
+func NewPermutermIndex() *PermutermIndex {
+	return &PermutermIndex{terms: make(map[string]bool), removed: make(map[string]bool)}
+}
+
+// buildPermutermIndex returns a flushed PermutermIndex of the terms.
+func buildPermutermIndex(terms []string) *PermutermIndex {
+	pi := NewPermutermIndex()
+	for _, term := range terms {
+		pi.addTerm(term)
+	}
+	pi.flush()
+	return pi
+}
+
+// permutermRotations returns the rotations of the term followed by
+// permutermEnd, which are rotated at runes rather than bytes.
+func permutermRotations(term string) []string {
+	str := term + permutermEnd
+	rotations := make([]string, 0, utf8.RuneCountInString(str))
+	for i := range str {
+		rotations = append(rotations, str[i:]+str[:i])
+	}
+	return rotations
+}
+
+// permutermTerm returns the term that the rotation was produced from.
+func permutermTerm(rotation string) string {
+	end := strings.Index(rotation, permutermEnd)
+	return rotation[end+len(permutermEnd):] + rotation[:end]
+}
+
+// addTerm adds the term to the index. It is not looked up until flush is called.
+func (pi *PermutermIndex) addTerm(term string) {
+	if pi.terms[term] {
+		return
+	}
+	pi.terms[term] = true
+	if pi.removed[term] {
+		// The rotations of the term have not been compacted yet.
+		delete(pi.removed, term)
+		return
+	}
+	pi.unflushed = append(pi.unflushed, permutermRotations(term)...)
+}
+
+// hasTerm checks if the term is in the index.
+func (pi *PermutermIndex) hasTerm(term string) bool {
+	return pi.terms[term]
+}
+
+// removeTerm removes the term from the index. It is no longer looked up,
+// but its rotations are only removed when flush is called.
+func (pi *PermutermIndex) removeTerm(term string) {
+	if !pi.terms[term] {
+		return
+	}
+	delete(pi.terms, term)
+	pi.removed[term] = true
+}
+
+// flush removes the rotations of the removed terms and merges
+// the rotations of the added terms into the sorted rotations.
+func (pi *PermutermIndex) flush() {
+	if len(pi.removed) != 0 {
+		pi.rotations = pi.compact(pi.rotations)
+		pi.unflushed = pi.compact(pi.unflushed)
+		pi.removed = make(map[string]bool)
+	}
+	if len(pi.unflushed) == 0 {
+		return
+	}
+	sort.Strings(pi.unflushed)
+	merged := make([]string, 0, len(pi.rotations)+len(pi.unflushed))
+	i, j := 0, 0
+	for i < len(pi.rotations) && j < len(pi.unflushed) {
+		if pi.rotations[i] < pi.unflushed[j] {
+			merged = append(merged, pi.rotations[i])
+			i++
+		} else {
+			merged = append(merged, pi.unflushed[j])
+			j++
+		}
+	}
+	merged = append(merged, pi.rotations[i:]...)
+	pi.rotations = append(merged, pi.unflushed[j:]...)
+	pi.unflushed = nil
+}
+
+// compact returns the rotations without those of the removed terms, in place.
+func (pi *PermutermIndex) compact(rotations []string) []string {
+	kept := rotations[:0]
+	for _, rotation := range rotations {
+		if !pi.removed[permutermTerm(rotation)] {
+			kept = append(kept, rotation)
+		}
+	}
+	return kept
+}
+
+// prefixRange returns the rotations that start with the prefix.
+func (pi *PermutermIndex) prefixRange(prefix string) []string {
+	lo := sort.SearchStrings(pi.rotations, prefix)
+	n := sort.Search(len(pi.rotations)-lo, func(i int) bool {
+		return !strings.HasPrefix(pi.rotations[lo+i], prefix)
+	})
+	return pi.rotations[lo : lo+n]
+}
+
+// WildcardTerms returns the terms that match the wildcard pattern.
+// The rotations are looked up by the literals after the last and before the
+// first wildcard, e.g. ion$ra for ra?i*ion, or by the longest literal if the
+// pattern starts and ends with a wildcard, e.g. tat for *tat*. Only the terms
+// of patterns with a '?' or more than one '*', other than infix patterns
+// such as *tat*, are checked against the pattern.
+func (pi *PermutermIndex) WildcardTerms(pattern string) (terms []string) {
+	first := strings.IndexAny(pattern, "*?")
+	if first == -1 {
+		if pi.terms[pattern] {
+			terms = append(terms, pattern)
+		}
+		return
+	}
+	last := strings.LastIndexAny(pattern, "*?")
+	exact := first == last && pattern[first] == '*'
+	key := pattern[last+1:] + permutermEnd + pattern[:first]
+	var seen map[string]bool
+	if key == permutermEnd && !exact {
+		if literal := longestLiteral(pattern); literal != "" {
+			// Terms with the literal at several positions have several rotations in the range.
+			key, seen = literal, make(map[string]bool)
+			exact = pattern == "*"+literal+"*"
+		}
+	}
+	for _, rotation := range pi.prefixRange(key) {
+		term := permutermTerm(rotation)
+		if pi.removed[term] {
+			continue
+		}
+		if seen != nil {
+			if seen[term] {
+				continue
+			}
+			seen[term] = true
+		}
+		if exact || wildcardMatch(pattern, term) {
+			terms = append(terms, term)
+		}
+	}
+	return
+}
+
+// longestLiteral returns the longest run of runes of the pattern without wildcards.
+func longestLiteral(pattern string) (literal string) {
+	for _, run := range strings.FieldsFunc(pattern, func(r rune) bool { return r == '*' || r == '?' }) {
+		if len(run) > len(literal) {
+			literal = run
+		}
+	}
+	return
+}
+
+// Terms returns all terms in the permuterm index in sorted order.
+func (pi *PermutermIndex) Terms() (terms []string) {
+	for term := range pi.terms {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	return
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPermutermRotations(t *testing.T) {
+	pairs := []struct {
+		term      string
+		rotations []string
+	}{
+		{"hello", []string{"hello$", "ello$h", "llo$he", "lo$hel", "o$hell", "$hello"}},
+		{"i", []string{"i$", "$i"}},
+		{"κάπ", []string{"κάπ$", "άπ$κ", "π$κά", "$κάπ"}},
+	}
+	for _, pair := range pairs {
+		rotations := permutermRotations(pair.term)
+		if !reflect.DeepEqual(rotations, pair.rotations) {
+			t.Errorf("Wrong rotations of %s: Got %v, Wanted %v.", pair.term, rotations, pair.rotations)
+		}
+		for _, rotation := range rotations {
+			if term := permutermTerm(rotation); term != pair.term {
+				t.Errorf("Wrong term of %s: Got %s, Wanted %s.", rotation, term, pair.term)
+			}
+		}
+	}
+}
+
+func SetUpPermutermIndex() *PermutermIndex {
+	return buildPermutermIndex([]string{"hello", "helicopter", "man", "shell", "hell", "κάππα"})
+}
+
+func TestPermutermIndex_WildcardTerms(t *testing.T) {
+	pi := SetUpPermutermIndex()
+	pairs := []struct {
+		pattern string
+		terms   []string
+	}{
+		{"hello", []string{"hello"}},
+		{"help", nil},
+		{"he*", []string{"hell", "hello", "helicopter"}},
+		{"*ll", []string{"hell", "shell"}},
+		{"h*r", []string{"helicopter"}},
+		{"*ell*", []string{"hell", "hello", "shell"}},
+		{"*l*o*", []string{"hello", "helicopter"}},
+		{"m?n", []string{"man"}},
+		{"?ell", []string{"hell"}},
+		{"h?l*", []string{"hell", "hello", "helicopter"}},
+		{"κ*α", []string{"κάππα"}},
+		{"*", []string{"hell", "hello", "helicopter", "man", "shell", "κάππα"}},
+		{"?", nil},
+	}
+	for _, pair := range pairs {
+		terms := pi.WildcardTerms(pair.pattern)
+		sort.Strings(terms)
+		sort.Strings(pair.terms)
+		if !reflect.DeepEqual(terms, pair.terms) {
+			t.Errorf("Wrong terms for %s: Got %v, Wanted %v.", pair.pattern, terms, pair.terms)
+		}
+	}
+}
+
+func TestPermutermIndex_RemoveTerm(t *testing.T) {
+	pi := SetUpPermutermIndex()
+	pi.removeTerm("hello")
+	pi.addTerm("help")
+	pi.addTerm("helm")
+	pi.removeTerm("helm")
+	pi.removeTerm("hell")
+	pi.addTerm("hell")
+	// Removed terms are not looked up before the flush.
+	if terms := pi.WildcardTerms("hel*"); !reflect.DeepEqual(terms, []string{"helicopter", "hell"}) {
+		t.Errorf("Wrong terms before flush: Got %v, Wanted [helicopter hell].", terms)
+	}
+	if len(pi.removed) != 2 {
+		t.Errorf("Wrong number of removed terms: Got %d, Wanted 2.", len(pi.removed))
+	}
+	pi.flush()
+	if len(pi.removed) != 0 {
+		t.Errorf("Removed terms were not compacted: Got %v.", pi.removed)
+	}
+	if terms := pi.WildcardTerms("hel*"); !reflect.DeepEqual(terms, []string{"helicopter", "hell", "help"}) {
+		t.Errorf("Wrong terms: Got %v, Wanted [helicopter hell help].", terms)
+	}
+	if pi.hasTerm("hello") || pi.hasTerm("helm") {
+		t.Errorf("Removed terms are still in the index.")
+	}
+	if want := 11 + 4 + 6 + 5 + 6 + 5; len(pi.rotations) != want {
+		t.Errorf("Wrong number of rotations: Got %d, Wanted %d.", len(pi.rotations), want)
+	}
+	if !sort.StringsAreSorted(pi.rotations) {
+		t.Errorf("Rotations are not sorted.")
+	}
+}
+
+func TestSearcher_SetTermDictionary(t *testing.T) {
+	s, store := SetUpMemorySearcher()
+	kgram := SetUpSearcher()
+	s.SetTermDictionary(PermutermDictionary)
+	queries := []string{"kap*", "*ysis", "*ant*", "l?tent sem*", "c*a"}
+	for _, query := range queries {
+		if res, want := s.WildcardQuery(query), kgram.WildcardQuery(query); !reflect.DeepEqual(res, want) {
+			t.Errorf("Wrong results for %q: Got %v, Wanted %v.", query, res, want)
+		}
+	}
+
+	// The permuterm index follows the k-gram index as documents change.
+	added := Document{id: 4, Title: "Fleiss' kappa", Body: "Gwet's AC1 is an alternative"}
+	store.put(added)
+	if err := s.AddDocument(added); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteDocument(3); err != nil {
+		t.Fatal(err)
+	}
+	if terms, want := s.permuterm.Terms(), s.ki.Terms(); !reflect.DeepEqual(terms, want) {
+		t.Errorf("Wrong permuterm terms: Got %v, Wanted %v.", terms, want)
+	}
+	if res := s.WildcardQuery("gwe*"); !reflect.DeepEqual(res, []int{4}) {
+		t.Errorf("Wrong results for added document: Got %v, Wanted [4].", res)
+	}
+	if res := s.WildcardQuery("cdm*"); len(res) != 0 {
+		t.Errorf("Wrong results for deleted document: Got %v, Wanted [].", res)
+	}
+
+	s.SetTermDictionary(KGramDictionary)
+	if s.permuterm != nil {
+		t.Errorf("Permuterm index is kept for the k-gram dictionary.")
+	}
+}
+
+// benchmarkVocabulary returns random words of 3 to 12 letters,
+// which are looked up by BenchmarkWildcardTerms.
+func benchmarkVocabulary() []string {
+	r := rand.New(rand.NewSource(1))
+	words := make([]string, 50000)
+	for i := range words {
+		word := make([]byte, 3+r.Intn(10))
+		for j := range word {
+			word[j] = byte('a' + r.Intn(26))
+		}
+		words[i] = string(word)
+	}
+	return words
+}
+
+// BenchmarkWildcardTerms compares looking up prefix, suffix, infix and
+// other wildcard patterns in the k-gram index and the permuterm index.
+// The patterns have a run of k literals, without which KGramMatch finds no terms.
+func BenchmarkWildcardTerms(b *testing.B) {
+	words := benchmarkVocabulary()
+	ki := NewKGramIndex(3)
+	for _, word := range words {
+		ki.addWordToPostingsList(word)
+	}
+	pi := buildPermutermIndex(words)
+	dictionaries := []struct {
+		name  string
+		index interface{ WildcardTerms(string) []string }
+	}{
+		{"KGram", ki}, {"Permuterm", pi},
+	}
+	patterns := []struct {
+		name, pattern string
+	}{
+		{"Prefix", "ab*"}, {"Suffix", "*ing"}, {"Infix", "*ing*"},
+		{"PrefixSuffix", "s*ed"}, {"Question", "?ing*"}, {"Multiple", "a*b*c"},
+	}
+	for _, pattern := range patterns {
+		for _, dict := range dictionaries {
+			b.Run(pattern.name+"/"+dict.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					dict.index.WildcardTerms(pattern.pattern)
+				}
+			})
+		}
+	}
+}
@@ -1,16 +1,17 @@
 package main
 
 import (
+	"reflect"
 	"sort"
 	"testing"
 )
 
 func SetUpInvertedIndex() (ii *InvertedIndex) {
 	ii = NewInvertedIndex()
-	ii.addIDToPostingsList("hello", 1)
-	ii.addIDToPostingsList("hello", 2)
-	ii.addIDToPostingsList("world", 1)
-	ii.addIDToPostingsList("world", 3)
+	ii.addIDToPostingsList("hello", 1, 0)
+	ii.addIDToPostingsList("hello", 2, 0)
+	ii.addIDToPostingsList("world", 1, 1)
+	ii.addIDToPostingsList("world", 3, 0)
 	return
 }
 
@@ -83,6 +84,75 @@ func TestInvertedIndex_Union(t *testing.T) {
 	}
 }
 
+func TestInvertedIndex_PhrasePostings(t *testing.T) {
+	ii := NewInvertedIndex()
+	for docID, text := range []string{"to be or not to be", "not to be", "be to"} {
+		for pos, token := range analyzeTerms(SimpleAnalyzer, text) {
+			ii.addIDToPostingsList(token, docID+1, pos)
+		}
+	}
+	pairs := []struct{
+		terms []string
+		docIDs []int
+		freqs []int
+	}{
+		{[]string{"to", "be"}, []int{1, 2}, []int{2, 1}},
+		{[]string{"not", "to", "be"}, []int{1, 2}, []int{1, 1}},
+		{[]string{"be", "to"}, []int{3}, []int{1}},
+		{[]string{"be"}, []int{1, 2, 3}, []int{2, 1, 1}},
+		{[]string{"or", "to"}, nil, nil},
+		{[]string{}, nil, nil},
+	}
+	for _, pair := range pairs {
+		docIDs, freqs := ii.PhrasePostings(pair.terms)
+		if !reflect.DeepEqual(docIDs, pair.docIDs) || !reflect.DeepEqual(freqs, pair.freqs) {
+			t.Errorf("Wrong postings for %v: Got %v %v, Wanted %v %v.", pair.terms, docIDs, freqs, pair.docIDs, pair.freqs)
+		}
+	}
+}
+
+func TestWithinDistance(t *testing.T) {
+	pairs := []struct{
+		pos1 []int
+		pos2 []int
+		k int
+		ordered bool
+		result bool
+	}{
+		{[]int{1, 10}, []int{4}, 3, false, true},
+		{[]int{1, 10}, []int{4}, 2, false, false},
+		{[]int{5}, []int{1, 3}, 2, false, true},
+		{[]int{5}, []int{1, 3}, 2, true, false},
+		{[]int{1, 5}, []int{3}, 2, true, true},
+		{[]int{2}, []int{2}, 1, false, false},
+		{[]int{}, []int{2}, 1, false, false},
+	}
+	for _, pair := range pairs {
+		if res := withinDistance(pair.pos1, pair.pos2, pair.k, pair.ordered); res != pair.result {
+			t.Errorf("Wrong result for %v %v k=%d ordered=%v: Got %v, Wanted %v.",
+				pair.pos1, pair.pos2, pair.k, pair.ordered, res, pair.result)
+		}
+	}
+}
+
+func TestProximityAccumulator(t *testing.T) {
+	pairs := []struct{
+		pos1 []int
+		pos2 []int
+		acc float64
+	}{
+		{[]int{1}, []int{2}, 1},
+		{[]int{1, 5}, []int{3}, 0.5},
+		{[]int{1}, []int{20}, 0},
+		{[]int{4}, []int{4, 6}, 0.25},
+	}
+	for _, pair := range pairs {
+		if acc := proximityAccumulator(pair.pos1, pair.pos2, 5); acc != pair.acc {
+			t.Errorf("Wrong accumulator for %v %v: Got %f, Wanted %f.", pair.pos1, pair.pos2, acc, pair.acc)
+		}
+	}
+}
+
 func SetUpKGramIndex(k int) (ki *KGramIndex) {
 	ki = NewKGramIndex(k)
 	ki.addWordToPostingsList("hello")
@@ -100,6 +170,7 @@ func TestBuildKGrams(t *testing.T) {
 		{"hello", 3, []string{"$$h", "$he", "hel", "ell", "llo", "lo$", "o$$"}},
 		{"hi", 3, []string{"$$h", "$hi", "hi$", "i$$"}},
 		{"i", 3, []string{"i"}},
+		{"κάπ", 3, []string{"$$κ", "$κά", "κάπ", "άπ$", "π$$"}},
 
 	}
 	for _, pair := range pairs {
@@ -167,4 +238,81 @@ func TestKGramIndex_KGramMatch(t *testing.T) {
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+func TestInvertedIndex_AddOutOfOrder(t *testing.T) {
+	ii := NewInvertedIndex()
+	for pos, docID := range []int{5, 2, 5, 9, 1, 2, 5} {
+		ii.addIDToPostingsList("hello", docID, pos)
+	}
+	pairs := []struct{
+		docID int
+		freq int
+	}{
+		{1, 1}, {2, 2}, {5, 3}, {9, 1}, {3, 0},
+	}
+	if res := ii.PostingsList("hello"); len(res) != 4 || res[0] != 1 || res[1] != 2 || res[2] != 5 || res[3] != 9 {
+		t.Errorf("Wrong postings list: Got %v, Wanted [1 2 5 9].", res)
+	}
+	for _, pair := range pairs {
+		if freq := ii.TermFrequency("hello", pair.docID); freq != pair.freq {
+			t.Errorf("Wrong frequency for %d: Got %d, Wanted %d.", pair.docID, freq, pair.freq)
+		}
+	}
+}
+
+func TestInvertedIndex_RemoveID(t *testing.T) {
+	ii := SetUpInvertedIndex()
+	removed := ii.removeID(3, []string{"hello", "world"})
+	if len(removed) != 0 {
+		t.Errorf("Wrong removed terms: Got %v, Wanted [].", removed)
+	}
+	// Only the postings lists of the given terms are changed.
+	removed = ii.removeID(1, []string{"world", "missing"})
+	if len(removed) != 1 || removed[0] != "world" {
+		t.Errorf("Wrong removed terms: Got %v, Wanted [world].", removed)
+	}
+	if res := ii.PostingsList("hello"); len(res) != 2 {
+		t.Errorf("Wrong postings list: Got %v, Wanted [1 2].", res)
+	}
+	ii.removeID(1, []string{"hello"})
+	if res := ii.PostingsList("hello"); len(res) != 1 || res[0] != 2 {
+		t.Errorf("Wrong postings list: Got %v, Wanted [2].", res)
+	}
+	if ii.TermFrequency("hello", 2) != 1 {
+		t.Errorf("Wrong frequency: Got %d, Wanted 1.", ii.TermFrequency("hello", 2))
+	}
+}
+
+func TestInvertedIndex_CollectionFrequency(t *testing.T) {
+	ii := SetUpInvertedIndex()
+	ii.addIDToPostingsList("hello", 2, 3)
+	ii.addIDToPostingsList("hello", 2, 3)
+	if cf := ii.CollectionFrequency("hello"); cf != 3 {
+		t.Errorf("Wrong collection frequency: Got %d, Wanted 3.", cf)
+	}
+	if ii.collectionLength != 5 {
+		t.Errorf("Wrong collection length: Got %d, Wanted 5.", ii.collectionLength)
+	}
+	ii.removeID(2, []string{"hello"})
+	if cf := ii.CollectionFrequency("hello"); cf != 1 {
+		t.Errorf("Wrong collection frequency after removal: Got %d, Wanted 1.", cf)
+	}
+	if ii.collectionLength != 3 {
+		t.Errorf("Wrong collection length after removal: Got %d, Wanted 3.", ii.collectionLength)
+	}
+	if cf := ii.CollectionFrequency("missing"); cf != 0 {
+		t.Errorf("Wrong collection frequency of a missing term: Got %d, Wanted 0.", cf)
+	}
+}
+
+func TestKGramIndex_RemoveWordFromPostingsList(t *testing.T) {
+	ki := SetUpKGramIndex(3)
+	ki.removeWordFromPostingsList("hello")
+	terms := ki.Terms()
+	if len(terms) != 2 || terms[0] != "helicopter" || terms[1] != "man" {
+		t.Errorf("Wrong terms: Got %v, Wanted [helicopter man].", terms)
+	}
+	if _, ok := ki.postingsLists["llo"]; ok {
+		t.Errorf("Empty k-gram llo was not removed.")
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import "sort"
+
+// DocIterator iterates over docIDs in increasing order.
+type DocIterator interface {
+	// Next moves to the next document and returns false if there are no more documents.
+	Next() bool
+	// Advance moves to the first document that is not before the target,
+	// skipping the documents in between, and returns false if there is no
+	// such document. It does not move if the current document is not before the target.
+	Advance(target int) bool
+	// DocID returns the current document, -1 before the first call to Next
+	// or Advance, and noMoreDocs after the last document.
+	DocID() int
+}
+
+// noMoreDocs is the DocID of an iterator after its last document.
+const noMoreDocs = maxInt
+
+// collectDocs returns all the remaining documents of the iterator.
+func collectDocs(it DocIterator) (docIDs []int) {
+	docIDs = []int{}
+	for it.Next() {
+		docIDs = append(docIDs, it.DocID())
+	}
+	return
+}
+
+// gallop returns the first index in [from, n) for which before is false,
+// or n if there is no such index. before must be true up to that index and
+// false after it. The distance from the start is doubled until the index is
+// passed, so it is found in O(log d) steps where d is its distance from the start.
+func gallop(from int, n int, before func(int) bool) int {
+	lo, step := from, 1
+	for lo+step < n && before(lo+step) {
+		lo += step
+		step *= 2
+	}
+	hi := min(lo+step, n)
+	return lo + sort.Search(hi-lo, func(i int) bool { return !before(lo + i) })
+}
+
+// sliceIterator iterates over a sorted slice of docIDs.
+type sliceIterator struct {
+	docIDs []int
+	idx    int
+}
+
+func newSliceIterator(docIDs []int) *sliceIterator {
+	return &sliceIterator{docIDs: docIDs, idx: -1}
+}
+
+func (it *sliceIterator) Next() bool {
+	if it.idx < len(it.docIDs) {
+		it.idx++
+	}
+	return it.idx < len(it.docIDs)
+}
+
+func (it *sliceIterator) Advance(target int) bool {
+	if it.DocID() < target {
+		it.idx = gallop(max(it.idx, 0), len(it.docIDs), func(i int) bool { return it.docIDs[i] < target })
+	}
+	return it.idx < len(it.docIDs)
+}
+
+func (it *sliceIterator) DocID() int {
+	if it.idx < 0 {
+		return -1
+	}
+	if it.idx >= len(it.docIDs) {
+		return noMoreDocs
+	}
+	return it.docIDs[it.idx]
+}
+
+// conjunctionIterator iterates over the documents that all iterators contain.
+// The iterators leapfrog: each one is advanced to the document of the one
+// before it, so iterators that are sorted from the shortest skip the most.
+type conjunctionIterator struct {
+	its []DocIterator
+	doc int
+}
+
+func newConjunctionIterator(its []DocIterator) *conjunctionIterator {
+	return &conjunctionIterator{its: its, doc: -1}
+}
+
+func (it *conjunctionIterator) Next() bool {
+	if len(it.its) == 0 || !it.its[0].Next() {
+		it.doc = noMoreDocs
+		return false
+	}
+	return it.align()
+}
+
+func (it *conjunctionIterator) Advance(target int) bool {
+	if it.doc >= target {
+		return it.doc != noMoreDocs
+	}
+	if len(it.its) == 0 || !it.its[0].Advance(target) {
+		it.doc = noMoreDocs
+		return false
+	}
+	return it.align()
+}
+
+// align advances the iterators until they are all on the document of the first.
+func (it *conjunctionIterator) align() bool {
+	target := it.its[0].DocID()
+	for i := 1; i < len(it.its); {
+		if !it.its[i].Advance(target) {
+			it.doc = noMoreDocs
+			return false
+		}
+		if docID := it.its[i].DocID(); docID > target {
+			// Start again from the first iterator at the larger document.
+			if !it.its[0].Advance(docID) {
+				it.doc = noMoreDocs
+				return false
+			}
+			target = it.its[0].DocID()
+			i = 1
+			continue
+		}
+		i++
+	}
+	it.doc = target
+	return true
+}
+
+func (it *conjunctionIterator) DocID() int {
+	return it.doc
+}
+
+// disjunctionIterator iterates over the documents that at least one iterator contains.
+type disjunctionIterator struct {
+	its []DocIterator
+	doc int
+}
+
+func newDisjunctionIterator(its []DocIterator) *disjunctionIterator {
+	return &disjunctionIterator{its: its, doc: -1}
+}
+
+func (it *disjunctionIterator) Next() bool {
+	for _, sub := range it.its {
+		if sub.DocID() <= it.doc {
+			sub.Next()
+		}
+	}
+	return it.update()
+}
+
+func (it *disjunctionIterator) Advance(target int) bool {
+	if it.doc >= target {
+		return it.doc != noMoreDocs
+	}
+	for _, sub := range it.its {
+		sub.Advance(target)
+	}
+	return it.update()
+}
+
+// update moves to the smallest document of the iterators.
+func (it *disjunctionIterator) update() bool {
+	it.doc = noMoreDocs
+	for _, sub := range it.its {
+		it.doc = min(it.doc, sub.DocID())
+	}
+	return it.doc != noMoreDocs
+}
+
+func (it *disjunctionIterator) DocID() int {
+	return it.doc
+}
+
+// exclusionIterator iterates over the documents of include that exclude does not contain.
+type exclusionIterator struct {
+	include, exclude DocIterator
+}
+
+func (it *exclusionIterator) Next() bool {
+	return it.include.Next() && it.skipExcluded()
+}
+
+func (it *exclusionIterator) Advance(target int) bool {
+	return it.include.Advance(target) && it.skipExcluded()
+}
+
+// skipExcluded moves include past the documents that are excluded.
+func (it *exclusionIterator) skipExcluded() bool {
+	for it.exclude.Advance(it.include.DocID()) && it.exclude.DocID() == it.include.DocID() {
+		if !it.include.Next() {
+			return false
+		}
+	}
+	return true
+}
+
+func (it *exclusionIterator) DocID() int {
+	return it.include.DocID()
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGallop(t *testing.T) {
+	arr := []int{1, 3, 5, 7, 9, 11, 13, 15, 17}
+	pairs := []struct {
+		from   int
+		target int
+		idx    int
+	}{
+		{0, 0, 0}, {0, 1, 0}, {0, 2, 1}, {0, 9, 4}, {0, 17, 8}, {0, 18, 9},
+		{3, 2, 3}, {3, 8, 4}, {8, 17, 8}, {9, 20, 9},
+	}
+	for _, pair := range pairs {
+		if idx := gallop(pair.from, len(arr), func(i int) bool { return arr[i] < pair.target }); idx != pair.idx {
+			t.Errorf("Wrong index of %d from %d: Got %d, Wanted %d.", pair.target, pair.from, idx, pair.idx)
+		}
+	}
+}
+
+// SetUpPostings returns postings of the multiples of the step up to n,
+// with the ones after the last block not flushed.
+func SetUpPostings(step int, n int) *Postings {
+	p := newPostings(VByteCodec)
+	for docID := step; docID <= n; docID += step {
+		p.add(docID, docID%7)
+	}
+	return p
+}
+
+func TestDocIterator_Advance(t *testing.T) {
+	iterators := map[string]func() DocIterator{
+		"slice": func() DocIterator {
+			var docIDs []int
+			for docID := 3; docID <= 1000; docID += 3 {
+				docIDs = append(docIDs, docID)
+			}
+			return newSliceIterator(docIDs)
+		},
+		"postings": func() DocIterator { return SetUpPostings(3, 1000).Iterator() },
+	}
+	// Each target is advanced to from the document of the previous one.
+	targets := []struct {
+		target int
+		docID  int
+	}{
+		{2, 3}, {3, 3}, {1, 3}, {4, 6}, {500, 501}, {501, 501}, {900, 900}, {998, 999}, {1000, noMoreDocs},
+	}
+	for name, newIterator := range iterators {
+		it := newIterator()
+		for _, pair := range targets {
+			ok := it.Advance(pair.target)
+			if docID := it.DocID(); docID != pair.docID || ok != (pair.docID != noMoreDocs) {
+				t.Errorf("Wrong %s document for %d: Got %d (%t), Wanted %d.", name, pair.target, docID, ok, pair.docID)
+			}
+		}
+		if it.Next() || it.DocID() != noMoreDocs {
+			t.Errorf("Wrong %s document after the last: Got %d.", name, it.DocID())
+		}
+	}
+}
+
+func TestPostingsIterator_AdvancePositions(t *testing.T) {
+	p := SetUpPostings(2, 1000)
+	p.flush()
+	it := p.Iterator()
+	for _, docID := range []int{10, 300, 302, 700, 1000} {
+		if !it.Advance(docID) || it.DocID() != docID {
+			t.Fatalf("Wrong document: Got %d, Wanted %d.", it.DocID(), docID)
+		}
+		if positions := it.Positions(); !reflect.DeepEqual(positions, []int{docID % 7}) {
+			t.Errorf("Wrong positions of %d: Got %v, Wanted %v.", docID, positions, []int{docID % 7})
+		}
+	}
+}
+
+func TestDocIterator_Combinations(t *testing.T) {
+	plist1 := []int{1, 2, 4, 6, 8, 10, 12}
+	plist2 := []int{2, 3, 6, 9, 12, 15}
+	plist3 := []int{6, 12, 18}
+	pairs := []struct {
+		name   string
+		it     DocIterator
+		result []int
+	}{
+		{"conjunction", newConjunctionIterator([]DocIterator{newSliceIterator(plist1), newSliceIterator(plist2), newSliceIterator(plist3)}), []int{6, 12}},
+		{"conjunction", newConjunctionIterator([]DocIterator{newSliceIterator(plist3), newSliceIterator(nil)}), []int{}},
+		{"conjunction", newConjunctionIterator(nil), []int{}},
+		{"disjunction", newDisjunctionIterator([]DocIterator{newSliceIterator(plist2), newSliceIterator(plist3)}), []int{2, 3, 6, 9, 12, 15, 18}},
+		{"disjunction", newDisjunctionIterator(nil), []int{}},
+		{"exclusion", &exclusionIterator{newSliceIterator(plist1), newSliceIterator(plist2)}, []int{1, 4, 8, 10}},
+		{"exclusion", &exclusionIterator{newSliceIterator(plist3), newSliceIterator(plist1)}, []int{18}},
+	}
+	for _, pair := range pairs {
+		if res := collectDocs(pair.it); !reflect.DeepEqual(res, pair.result) {
+			t.Errorf("Wrong %s: Got %v, Wanted %v.", pair.name, res, pair.result)
+		}
+	}
+
+	it := newConjunctionIterator([]DocIterator{newSliceIterator(plist1), newSliceIterator(plist2)})
+	if !it.Advance(3) || it.DocID() != 6 {
+		t.Errorf("Wrong conjunction document: Got %d, Wanted 6.", it.DocID())
+	}
+	union := newDisjunctionIterator([]DocIterator{newSliceIterator(plist1), newSliceIterator(plist3)})
+	if !union.Advance(13) || union.DocID() != 18 || union.Next() {
+		t.Errorf("Wrong disjunction document: Got %d, Wanted 18.", union.DocID())
+	}
+}
+
+// benchmarkIntersections are pairs of terms of the benchmark corpus,
+// from two common terms to a common and a rare term.
+var benchmarkIntersections = []struct {
+	name         string
+	term1, term2 int
+}{
+	{"Common", 0, 10}, {"Frequent", 0, 100}, {"Rare", 0, 400}, {"VeryRare", 5, 1000},
+}
+
+// BenchmarkIntersect compares walking the decoded postings lists with
+// leapfrogging over the compressed blocks with Advance.
+func BenchmarkIntersect(b *testing.B) {
+	index, _ := buildBenchmarkIndex(VByteCodec)
+	ii := index.(*InvertedIndex)
+	for _, pair := range benchmarkIntersections {
+		terms := []string{benchmarkTermName(pair.term1), benchmarkTermName(pair.term2)}
+		b.Run(pair.name+"/Linear", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				linearIntersect(ii.PostingsList(terms[0]), ii.PostingsList(terms[1]))
+			}
+		})
+		b.Run(pair.name+"/Advance", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ii.Intersect(terms)
+			}
+		})
+	}
+}
+
+// BenchmarkTermFrequency looks up the frequency of a common term in
+// the documents of a rarer term.
+func BenchmarkTermFrequency(b *testing.B) {
+	index, _ := buildBenchmarkIndex(VByteCodec)
+	ii := index.(*InvertedIndex)
+	term, docIDs := benchmarkTermName(0), ii.PostingsList(benchmarkTermName(400))
+	b.Run("Linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, docID := range docIDs {
+				for it := ii.Iterator(term); it.Next() && it.DocID() <= docID; {
+					if it.DocID() == docID {
+						it.Freq()
+					}
+				}
+			}
+		}
+	})
+	b.Run("Advance", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, docID := range docIDs {
+				ii.TermFrequency(term, docID)
+			}
+		}
+	})
+}
@@ -1,5 +1,5 @@
 package main
 
 func main() {
-	RunServer(3, NewCSVStorage("example.csv"))
+	RunServer(3, NewCSVStorage("example.csv"), "example.idx")
 }
\ No newline at end of file
@@ -0,0 +1,515 @@
+package main
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// PostingsCodec selects how the integers of a postings list are compressed.
+type PostingsCodec int
+
+const (
+	// VByteCodec stores integers in 7 bits per byte, using the high bit
+	// to mark the last byte of an integer.
+	VByteCodec PostingsCodec = iota
+	// EliasGammaCodec stores an integer n as the Elias gamma code of n+1,
+	// which takes 2*floor(log2(n+1))+1 bits and is smaller for small gaps.
+	EliasGammaCodec
+)
+
+func (c PostingsCodec) String() string {
+	if c == EliasGammaCodec {
+		return "Elias-gamma"
+	}
+	return "VByte"
+}
+
+// appendInts appends the encoding of the non-negative values to dst.
+func (c PostingsCodec) appendInts(dst []byte, values []int) []byte {
+	if c == EliasGammaCodec {
+		return appendGamma(dst, values)
+	}
+	return appendVByte(dst, values)
+}
+
+// readInts decodes n values from src and appends them to dst.
+func (c PostingsCodec) readInts(src []byte, n int, dst []int) []int {
+	if c == EliasGammaCodec {
+		return readGamma(src, n, dst)
+	}
+	return readVByte(src, n, dst)
+}
+
+func appendVByte(dst []byte, values []int) []byte {
+	for _, v := range values {
+		for v >= 0x80 {
+			dst = append(dst, byte(v&0x7f))
+			v >>= 7
+		}
+		dst = append(dst, byte(v)|0x80)
+	}
+	return dst
+}
+
+func readVByte(src []byte, n int, dst []int) []int {
+	pos := 0
+	for i := 0; i < n; i++ {
+		v, shift := 0, uint(0)
+		for {
+			b := src[pos]
+			pos++
+			v |= int(b&0x7f) << shift
+			if b&0x80 != 0 {
+				break
+			}
+			shift += 7
+		}
+		dst = append(dst, v)
+	}
+	return dst
+}
+
+func appendGamma(dst []byte, values []int) []byte {
+	var cur byte
+	used := uint(0)
+	writeBit := func(bit uint64) {
+		cur |= byte(bit) << (7 - used)
+		if used++; used == 8 {
+			dst = append(dst, cur)
+			cur, used = 0, 0
+		}
+	}
+	for _, v := range values {
+		x := uint64(v) + 1
+		n := bits.Len64(x) - 1
+		for i := 0; i < n; i++ {
+			writeBit(0)
+		}
+		for i := n; i >= 0; i-- {
+			writeBit(x >> uint(i) & 1)
+		}
+	}
+	if used > 0 {
+		dst = append(dst, cur)
+	}
+	return dst
+}
+
+func readGamma(src []byte, n int, dst []int) []int {
+	pos := uint(0)
+	readBit := func() uint64 {
+		bit := src[pos>>3] >> (7 - pos&7) & 1
+		pos++
+		return uint64(bit)
+	}
+	for i := 0; i < n; i++ {
+		zeros := 0
+		for readBit() == 0 {
+			zeros++
+		}
+		x := uint64(1)
+		for j := 0; j < zeros; j++ {
+			x = x<<1 | readBit()
+		}
+		dst = append(dst, int(x-1))
+	}
+	return dst
+}
+
+// postingsBlockSize is the number of postings in a full block.
+const postingsBlockSize = 128
+
+// Postings is a postings list that is compressed in blocks of up to
+// postingsBlockSize postings. The docIDs of a block are stored as gaps from
+// its first docID and the positions of each posting as gaps from the previous
+// position, followed by the term frequencies. Postings that are added after
+// the last block are kept uncompressed in a tail until flush is called.
+type Postings struct {
+	codec  PostingsCodec
+	blocks []postingsBlock
+	tail   []posting
+	length int
+	// totalFreq is the sum of the term frequencies of all postings.
+	totalFreq int
+}
+
+type postingsBlock struct {
+	count      int
+	firstDocID int
+	lastDocID  int
+	// maxFreq is the largest term frequency in the block.
+	maxFreq int
+	// docs are the count-1 gaps between docIDs minus one,
+	// followed by the count term frequencies minus one.
+	docs []byte
+	// positions are the first position and the gaps minus one
+	// between the later positions of each posting.
+	positions []byte
+}
+
+// posting is a document and the positions of a term in the document.
+type posting struct {
+	docID     int
+	positions []int
+}
+
+func newPostings(codec PostingsCodec) *Postings {
+	return &Postings{codec: codec}
+}
+
+// Len returns the number of documents in the postings list.
+func (p *Postings) Len() int {
+	return p.length
+}
+
+// TotalFreq returns the number of positions in the postings list,
+// which is the number of times the term appears in all documents.
+func (p *Postings) TotalFreq() int {
+	return p.totalFreq
+}
+
+// lastDocID returns the largest docID in the list, or -1 if it is empty.
+func (p *Postings) lastDocID() int {
+	if len(p.tail) > 0 {
+		return p.tail[len(p.tail)-1].docID
+	}
+	if len(p.blocks) > 0 {
+		return p.blocks[len(p.blocks)-1].lastDocID
+	}
+	return -1
+}
+
+// findBlock returns the index of the first block that may contain the docID,
+// or len(p.blocks) if the docID is after all blocks.
+func (p *Postings) findBlock(docID int) int {
+	return sort.Search(len(p.blocks), func(i int) bool { return p.blocks[i].lastDocID >= docID })
+}
+
+// add adds the position of the term in the document.
+func (p *Postings) add(docID int, position int) {
+	if n := len(p.tail); n > 0 && p.tail[n-1].docID == docID && p.tail[n-1].positions[len(p.tail[n-1].positions)-1] < position {
+		// The common case of adding the next position of the last document.
+		p.tail[n-1].positions = append(p.tail[n-1].positions, position)
+		p.totalFreq++
+		return
+	}
+	if docID > p.lastDocID() {
+		if len(p.tail) == postingsBlockSize {
+			p.flush()
+		}
+		p.tail = append(p.tail, posting{docID, []int{position}})
+		p.length++
+		p.totalFreq++
+		return
+	}
+	var addedDoc, addedPosition bool
+	if i := p.findBlock(docID); i < len(p.blocks) {
+		postings := p.decodeBlock(i)
+		postings, addedDoc, addedPosition = addPosting(postings, docID, position)
+		p.replaceBlocks(i, i+1, postings)
+	} else {
+		p.tail, addedDoc, addedPosition = addPosting(p.tail, docID, position)
+	}
+	if addedDoc {
+		p.length++
+	}
+	if addedPosition {
+		p.totalFreq++
+	}
+}
+
+// addPosting adds the position to the sorted postings. Returns whether
+// the document was not in the postings and whether the position was not.
+func addPosting(postings []posting, docID int, position int) ([]posting, bool, bool) {
+	idx := sort.Search(len(postings), func(i int) bool { return postings[i].docID >= docID })
+	if idx < len(postings) && postings[idx].docID == docID {
+		positions := postings[idx].positions
+		if j := sort.SearchInts(positions, position); j == len(positions) || positions[j] != position {
+			postings[idx].positions = insertAt(positions, j, position)
+			return postings, false, true
+		}
+		return postings, false, false
+	}
+	postings = append(postings, posting{})
+	copy(postings[idx+1:], postings[idx:])
+	postings[idx] = posting{docID, []int{position}}
+	return postings, true, true
+}
+
+// remove removes the document from the postings list.
+// Returns true if the document was in the list.
+func (p *Postings) remove(docID int) bool {
+	i := p.findBlock(docID)
+	postings := p.tail
+	if i < len(p.blocks) {
+		// Only the docIDs are decoded to check if the document is in the block.
+		if docIDs, _ := p.decodeDocs(&p.blocks[i], nil, nil); !containsSorted(docIDs, docID) {
+			return false
+		}
+		postings = p.decodeBlock(i)
+	}
+	idx := sort.Search(len(postings), func(j int) bool { return postings[j].docID >= docID })
+	if idx == len(postings) || postings[idx].docID != docID {
+		return false
+	}
+	p.totalFreq -= len(postings[idx].positions)
+	postings = append(postings[:idx], postings[idx+1:]...)
+	if i < len(p.blocks) {
+		p.replaceBlocks(i, i+1, postings)
+	} else {
+		p.tail = postings
+	}
+	p.length--
+	return true
+}
+
+// flush compresses the tail, filling up the last block if it is not full.
+func (p *Postings) flush() {
+	if len(p.tail) == 0 {
+		return
+	}
+	postings := p.tail
+	i := len(p.blocks)
+	if i > 0 && p.blocks[i-1].count < postingsBlockSize {
+		i--
+		postings = append(p.decodeBlock(i), postings...)
+	}
+	p.tail = nil
+	p.replaceBlocks(i, len(p.blocks), postings)
+}
+
+// replaceBlocks replaces the blocks from index i to j with blocks of the postings.
+func (p *Postings) replaceBlocks(i int, j int, postings []posting) {
+	var blocks []postingsBlock
+	blocks = append(blocks, p.blocks[:i]...)
+	for start := 0; start < len(postings); start += postingsBlockSize {
+		end := min(start+postingsBlockSize, len(postings))
+		blocks = append(blocks, p.encodeBlock(postings[start:end]))
+	}
+	p.blocks = append(blocks, p.blocks[j:]...)
+}
+
+func (p *Postings) encodeBlock(postings []posting) postingsBlock {
+	block := postingsBlock{
+		count:      len(postings),
+		firstDocID: postings[0].docID,
+		lastDocID:  postings[len(postings)-1].docID,
+	}
+	values := make([]int, 0, 2*len(postings))
+	for i := 1; i < len(postings); i++ {
+		values = append(values, postings[i].docID-postings[i-1].docID-1)
+	}
+	for _, pst := range postings {
+		values = append(values, len(pst.positions)-1)
+		block.maxFreq = max(block.maxFreq, len(pst.positions))
+	}
+	block.docs = p.codec.appendInts(nil, values)
+
+	values = values[:0]
+	for _, pst := range postings {
+		prev := -1
+		for _, pos := range pst.positions {
+			values = append(values, pos-prev-1)
+			prev = pos
+		}
+	}
+	block.positions = p.codec.appendInts(nil, values)
+	return block
+}
+
+// decodeDocs decodes the docIDs and term frequencies of the block into the slices.
+func (p *Postings) decodeDocs(block *postingsBlock, docIDs []int, freqs []int) ([]int, []int) {
+	values := p.codec.readInts(block.docs, 2*block.count-1, nil)
+	docIDs = append(docIDs[:0], block.firstDocID)
+	for _, gap := range values[:block.count-1] {
+		docIDs = append(docIDs, docIDs[len(docIDs)-1]+gap+1)
+	}
+	freqs = append(freqs[:0], values[block.count-1:]...)
+	for i := range freqs {
+		freqs[i]++
+	}
+	return docIDs, freqs
+}
+
+// decodePositions decodes the positions of the block, given its term frequencies.
+// The positions of posting i are positions[offsets[i]:offsets[i+1]].
+func (p *Postings) decodePositions(block *postingsBlock, freqs []int, positions []int, offsets []int) ([]int, []int) {
+	total := 0
+	offsets = append(offsets[:0], 0)
+	for _, freq := range freqs {
+		total += freq
+		offsets = append(offsets, total)
+	}
+	positions = p.codec.readInts(block.positions, total, positions[:0])
+	for i := range freqs {
+		prev := -1
+		for j := offsets[i]; j < offsets[i+1]; j++ {
+			positions[j] += prev + 1
+			prev = positions[j]
+		}
+	}
+	return positions, offsets
+}
+
+// decodeBlock decodes all postings of a block.
+func (p *Postings) decodeBlock(i int) []posting {
+	block := &p.blocks[i]
+	docIDs, freqs := p.decodeDocs(block, nil, nil)
+	positions, offsets := p.decodePositions(block, freqs, nil, nil)
+	postings := make([]posting, block.count)
+	for j := range postings {
+		postings[j] = posting{docIDs[j], positions[offsets[j]:offsets[j+1]:offsets[j+1]]}
+	}
+	return postings
+}
+
+// maxFreq returns the largest term frequency in the postings list.
+func (p *Postings) maxFreq() (freq int) {
+	for i := range p.blocks {
+		freq = max(freq, p.blocks[i].maxFreq)
+	}
+	return max(freq, tailMaxFreq(p.tail))
+}
+
+func tailMaxFreq(tail []posting) (freq int) {
+	for _, pst := range tail {
+		freq = max(freq, len(pst.positions))
+	}
+	return
+}
+
+// docIDs returns all docIDs of the postings list.
+func (p *Postings) docIDs() []int {
+	docIDs := make([]int, 0, p.length)
+	it := p.Iterator()
+	for it.Next() {
+		docIDs = append(docIDs, it.DocID())
+	}
+	return docIDs
+}
+
+// find returns the positions of the term in the document,
+// or nil if the document is not in the postings list.
+func (p *Postings) find(docID int) []int {
+	it := &PostingsIterator{p: p, block: p.findBlock(docID)}
+	it.loadBlock()
+	if it.Advance(docID) && it.DocID() == docID {
+		return it.Positions()
+	}
+	return nil
+}
+
+// PostingsIterator decodes a postings list one block at a time.
+type PostingsIterator struct {
+	p     *Postings
+	block int
+	idx   int
+	// docIDs and freqs of the current block, and its positions
+	// which are only decoded when they are needed.
+	docIDs    []int
+	freqs     []int
+	positions []int
+	offsets   []int
+	decoded   bool
+}
+
+// Iterator returns an iterator that is positioned before the first document.
+// The postings list must not be modified while the iterator is used.
+func (p *Postings) Iterator() *PostingsIterator {
+	it := &PostingsIterator{p: p}
+	it.loadBlock()
+	return it
+}
+
+// loadBlock decodes the docIDs of the current block and moves before its first posting.
+// The block after the last compressed block is the tail.
+func (it *PostingsIterator) loadBlock() {
+	it.idx = -1
+	it.decoded = false
+	if it.block < len(it.p.blocks) {
+		it.docIDs, it.freqs = it.p.decodeDocs(&it.p.blocks[it.block], it.docIDs, it.freqs)
+		return
+	}
+	it.docIDs, it.freqs = it.docIDs[:0], it.freqs[:0]
+	if it.block == len(it.p.blocks) {
+		for _, pst := range it.p.tail {
+			it.docIDs = append(it.docIDs, pst.docID)
+			it.freqs = append(it.freqs, len(pst.positions))
+		}
+	}
+}
+
+func (it *PostingsIterator) Next() bool {
+	it.idx++
+	for it.idx >= len(it.docIDs) {
+		if it.block >= len(it.p.blocks) {
+			it.idx = len(it.docIDs)
+			return false
+		}
+		it.block++
+		it.loadBlock()
+		it.idx = 0
+	}
+	return true
+}
+
+// Advance skips the blocks whose last docID is before the target without
+// decoding them, and then gallops over the docIDs of the block of the target.
+func (it *PostingsIterator) Advance(target int) bool {
+	if it.DocID() >= target {
+		return it.DocID() != noMoreDocs
+	}
+	if next := it.idx + 1; next < len(it.docIDs) && it.docIDs[next] >= target {
+		// The target is the next document, which is common in dense lists.
+		it.idx = next
+		return true
+	}
+	blocks := it.p.blocks
+	if it.block < len(blocks) && blocks[it.block].lastDocID < target {
+		it.block = gallop(it.block+1, len(blocks), func(i int) bool { return blocks[i].lastDocID < target })
+		it.loadBlock()
+	}
+	it.idx = gallop(max(it.idx, 0), len(it.docIDs), func(i int) bool { return it.docIDs[i] < target })
+	// Only the tail can end before the target, which exhausts the iterator.
+	return it.idx < len(it.docIDs)
+}
+
+// blockMax returns the largest term frequency in the block that contains
+// the target, and the last docID of the block, without decoding the block.
+// The target must not be before the current document.
+func (it *PostingsIterator) blockMax(target int) (maxFreq int, lastDocID int) {
+	blocks := it.p.blocks
+	i := gallop(min(it.block, len(blocks)), len(blocks), func(i int) bool { return blocks[i].lastDocID < target })
+	if i < len(blocks) {
+		return blocks[i].maxFreq, blocks[i].lastDocID
+	}
+	return tailMaxFreq(it.p.tail), noMoreDocs
+}
+
+func (it *PostingsIterator) DocID() int {
+	if it.idx < 0 {
+		return -1
+	}
+	if it.idx >= len(it.docIDs) {
+		return noMoreDocs
+	}
+	return it.docIDs[it.idx]
+}
+
+// Freq returns the frequency of the term in the current document.
+func (it *PostingsIterator) Freq() int {
+	return it.freqs[it.idx]
+}
+
+// Positions returns the positions of the term in the current document.
+// The slice is only valid until the iterator moves to another block.
+func (it *PostingsIterator) Positions() []int {
+	if it.block == len(it.p.blocks) {
+		return it.p.tail[it.idx].positions
+	}
+	if !it.decoded {
+		it.positions, it.offsets = it.p.decodePositions(&it.p.blocks[it.block], it.freqs, it.positions, it.offsets)
+		it.decoded = true
+	}
+	return it.positions[it.offsets[it.idx]:it.offsets[it.idx+1]]
+}
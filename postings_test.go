@@ -0,0 +1,284 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+var postingsCodecs = []PostingsCodec{VByteCodec, EliasGammaCodec}
+
+func TestPostingsCodec_RoundTrip(t *testing.T) {
+	values := []int{0, 1, 2, 3, 126, 127, 128, 255, 256, 16383, 16384, 1 << 30, maxInt >> 1}
+	for _, codec := range postingsCodecs {
+		data := codec.appendInts(nil, values)
+		if res := codec.readInts(data, len(values), nil); !reflect.DeepEqual(res, values) {
+			t.Errorf("Wrong %v values: Got %v, Wanted %v.", codec, res, values)
+		}
+	}
+}
+
+func TestPostingsCodec_Size(t *testing.T) {
+	pairs := []struct {
+		codec  PostingsCodec
+		values []int
+		size   int
+	}{
+		{VByteCodec, []int{0, 127}, 2},
+		{VByteCodec, []int{128}, 2},
+		{EliasGammaCodec, []int{0, 0, 0, 0, 0, 0, 0, 0}, 1},
+		{EliasGammaCodec, []int{1, 2}, 1},
+		{EliasGammaCodec, []int{127}, 2},
+	}
+	for _, pair := range pairs {
+		if size := len(pair.codec.appendInts(nil, pair.values)); size != pair.size {
+			t.Errorf("Wrong %v size of %v: Got %d, Wanted %d.", pair.codec, pair.values, size, pair.size)
+		}
+	}
+}
+
+// postingsContents returns all postings of the list, read with an iterator.
+func postingsContents(p *Postings) (postings []posting) {
+	for it := p.Iterator(); it.Next(); {
+		postings = append(postings, posting{it.DocID(), append([]int{}, it.Positions()...)})
+	}
+	return
+}
+
+func TestPostings_AddRemove(t *testing.T) {
+	for _, codec := range postingsCodecs {
+		// A reference of the postings that are expected in the list.
+		want := make(map[int][]int)
+		p := newPostings(codec)
+		r := rand.New(rand.NewSource(1))
+		for docID := 0; docID < 1000; docID += 1 + r.Intn(3) {
+			for pos := r.Intn(5); pos < 20; pos += 1 + r.Intn(10) {
+				p.add(docID, pos)
+				want[docID] = append(want[docID], pos)
+			}
+		}
+		p.flush()
+		// Out of order documents and positions go into the compressed blocks.
+		for _, pair := range [][2]int{{1001, 5}, {500, 100}, {501, 3}, {501, 1}, {0, 50}} {
+			p.add(pair[0], pair[1])
+			positions := want[pair[0]]
+			if j := sort.SearchInts(positions, pair[1]); j == len(positions) || positions[j] != pair[1] {
+				want[pair[0]] = insertAt(positions, j, pair[1])
+			}
+		}
+		for docID := 0; docID < 1000; docID += 7 {
+			if removed := p.remove(docID); removed != (want[docID] != nil) {
+				t.Errorf("Wrong removal of %d: Got %t, Wanted %t.", docID, removed, want[docID] != nil)
+			}
+			delete(want, docID)
+		}
+
+		var postings []posting
+		totalFreq := 0
+		for docID := 0; docID <= 1001; docID++ {
+			if positions, ok := want[docID]; ok {
+				postings = append(postings, posting{docID, positions})
+				totalFreq += len(positions)
+				if res := p.find(docID); !reflect.DeepEqual(res, positions) {
+					t.Errorf("Wrong %v positions of %d: Got %v, Wanted %v.", codec, docID, res, positions)
+				}
+			}
+		}
+		if res := postingsContents(p); !reflect.DeepEqual(res, postings) {
+			t.Errorf("Wrong %v postings: Got %v, Wanted %v.", codec, res, postings)
+		}
+		if p.Len() != len(postings) {
+			t.Errorf("Wrong %v length: Got %d, Wanted %d.", codec, p.Len(), len(postings))
+		}
+		if p.TotalFreq() != totalFreq {
+			t.Errorf("Wrong %v total frequency: Got %d, Wanted %d.", codec, p.TotalFreq(), totalFreq)
+		}
+		for _, block := range p.blocks {
+			if block.count > postingsBlockSize {
+				t.Errorf("Wrong %v block size: Got %d, Wanted at most %d.", codec, block.count, postingsBlockSize)
+			}
+		}
+	}
+}
+
+func TestInvertedIndex_SetCodec(t *testing.T) {
+	ii := SetUpInvertedIndex()
+	ii.flush()
+	want := postingsContents(ii.postingsLists["hello"])
+	ii.setCodec(EliasGammaCodec)
+	if res := postingsContents(ii.postingsLists["hello"]); !reflect.DeepEqual(res, want) {
+		t.Errorf("Wrong postings: Got %v, Wanted %v.", res, want)
+	}
+	if codec := ii.postingsLists["world"].codec; codec != EliasGammaCodec {
+		t.Errorf("Wrong codec: Got %v, Wanted %v.", codec, EliasGammaCodec)
+	}
+}
+
+// benchmarkDocuments is the number of documents of the synthetic benchmark corpus.
+const benchmarkDocuments = 100000
+
+// benchmarkCorpusTerms are the terms of the documents of the benchmark corpus.
+var benchmarkCorpusTerms [][]int
+
+// benchmarkCorpus returns the terms of the documents of the benchmark corpus.
+// Terms with a small number appear in more documents, like the common words of a language.
+func benchmarkCorpus() [][]int {
+	if benchmarkCorpusTerms == nil {
+		r := rand.New(rand.NewSource(1))
+		benchmarkCorpusTerms = make([][]int, benchmarkDocuments)
+		for i := range benchmarkCorpusTerms {
+			terms := make([]int, 50)
+			for j := range terms {
+				terms[j] = int(r.ExpFloat64() * r.ExpFloat64() * 50)
+			}
+			benchmarkCorpusTerms[i] = terms
+		}
+	}
+	return benchmarkCorpusTerms
+}
+
+// slicePostingsIndex is the layout of the inverted index before postings
+// were compressed, which the benchmarks are compared with.
+type slicePostingsIndex struct {
+	postingsLists map[int][]int
+	positions     map[int][][]int
+}
+
+func (si *slicePostingsIndex) add(term int, docID int, position int) {
+	pList := si.postingsLists[term]
+	if n := len(pList); n > 0 && pList[n-1] == docID {
+		si.positions[term][n-1] = append(si.positions[term][n-1], position)
+		return
+	}
+	si.postingsLists[term] = append(pList, docID)
+	si.positions[term] = append(si.positions[term], []int{position})
+}
+
+// linearIntersect is the intersection of the slice layout, which walks both lists.
+func linearIntersect(plist1 []int, plist2 []int) (result []int) {
+	result = []int{}
+	pointer1, pointer2 := 0, 0
+	for pointer1 < len(plist1) && pointer2 < len(plist2) {
+		if plist1[pointer1] == plist2[pointer2] {
+			result = append(result, plist1[pointer1])
+			pointer1++
+			pointer2++
+		} else if plist1[pointer1] < plist2[pointer2] {
+			pointer1++
+		} else {
+			pointer2++
+		}
+	}
+	return
+}
+
+// buildBenchmarkIndex indexes the benchmark corpus in the inverted index,
+// or in the slice layout if codec is negative. Returns the number of postings.
+func buildBenchmarkIndex(codec PostingsCodec) (index interface{}, postings int) {
+	ii := NewInvertedIndexWithCodec(codec)
+	si := &slicePostingsIndex{make(map[int][]int), make(map[int][][]int)}
+	for i, terms := range benchmarkCorpus() {
+		seen := make(map[int]bool)
+		for pos, term := range terms {
+			if !seen[term] {
+				seen[term] = true
+				postings++
+			}
+			if codec < 0 {
+				si.add(term, i+1, pos)
+			} else {
+				ii.addIDToPostingsList(benchmarkTermName(term), i+1, pos)
+			}
+		}
+	}
+	if codec < 0 {
+		return si, postings
+	}
+	ii.flush()
+	return ii, postings
+}
+
+// benchmarkTermName returns the word of a term of the benchmark corpus.
+func benchmarkTermName(term int) string {
+	return "t" + strconv.Itoa(term)
+}
+
+// benchmarkLayouts are the layouts that are benchmarked, with -1 as the slice layout.
+var benchmarkLayouts = []struct {
+	name  string
+	codec PostingsCodec
+}{
+	{"Slice", -1}, {"VByte", VByteCodec}, {"EliasGamma", EliasGammaCodec},
+}
+
+func heapInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
+
+// BenchmarkPostings_Memory reports the heap memory per posting of the index.
+func BenchmarkPostings_Memory(b *testing.B) {
+	for _, layout := range benchmarkLayouts {
+		b.Run(layout.name, func(b *testing.B) {
+			var perPosting float64
+			for i := 0; i < b.N; i++ {
+				before := heapInUse()
+				index, postings := buildBenchmarkIndex(layout.codec)
+				perPosting = float64(heapInUse()-before) / float64(postings)
+				runtime.KeepAlive(index)
+			}
+			b.ReportMetric(perPosting, "B/posting")
+		})
+	}
+}
+
+// BenchmarkPostings_Iterate decodes the docIDs and frequencies of all postings lists.
+func BenchmarkPostings_Iterate(b *testing.B) {
+	for _, layout := range benchmarkLayouts {
+		b.Run(layout.name, func(b *testing.B) {
+			index, postings := buildBenchmarkIndex(layout.codec)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				sum := 0
+				switch index := index.(type) {
+				case *slicePostingsIndex:
+					for term, pList := range index.postingsLists {
+						for j, docID := range pList {
+							sum += docID + len(index.positions[term][j])
+						}
+					}
+				case *InvertedIndex:
+					for _, p := range index.postingsLists {
+						for it := p.Iterator(); it.Next(); {
+							sum += it.DocID() + it.Freq()
+						}
+					}
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*postings), "ns/posting")
+		})
+	}
+}
+
+// BenchmarkPostings_Intersect intersects a frequent and a rarer term.
+func BenchmarkPostings_Intersect(b *testing.B) {
+	for _, layout := range benchmarkLayouts {
+		b.Run(layout.name, func(b *testing.B) {
+			index, _ := buildBenchmarkIndex(layout.codec)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				switch index := index.(type) {
+				case *slicePostingsIndex:
+					linearIntersect(index.postingsLists[0], index.postingsLists[40])
+				case *InvertedIndex:
+					index.Intersect([]string{benchmarkTermName(0), benchmarkTermName(40)})
+				}
+			}
+		})
+	}
+}
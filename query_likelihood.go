@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// QueryLikelihoodParams are the parameters of the smoothing of the
+// document language models in DirichletQuery and JelinekMercerQuery.
+type QueryLikelihoodParams struct {
+	// Mu is the Dirichlet prior, the number of words of the collection
+	// model that are added to each document.
+	Mu float64
+	// Lambda is the weight of the collection model in Jelinek-Mercer
+	// smoothing, between 0 (exclusive) and 1.
+	Lambda float64
+}
+
+// DefaultQueryLikelihoodParams are the values recommended for short queries
+// by Zhai, C., & Lafferty, J. (2004).
+var DefaultQueryLikelihoodParams = QueryLikelihoodParams{Mu: 2000, Lambda: 0.1}
+
+// SetQueryLikelihoodParams sets the parameters used by DirichletQuery and JelinekMercerQuery.
+func (s *Searcher) SetQueryLikelihoodParams(params QueryLikelihoodParams) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.ql = params
+}
+
+// DirichletQuery returns a ranked list of results sorted by the likelihood of
+// the query in the language model of each document, which is smoothed with a
+// Dirichlet prior on the language model of the collection:
+// p(t|d) = (tf + mu * p(t|C)) / (|d| + mu).
+// (Reference) Zhai, C., & Lafferty, J. (2004). A study of smoothing methods for language models applied to information retrieval.
+func (s *Searcher) DirichletQuery(query string) (results []int) {
+	resList := s.dirichletScores(query)
+	sort.Sort(resList)
+	results = resList.ids
+	return
+}
+
+// JelinekMercerQuery returns a ranked list of results sorted by the likelihood
+// of the query in the language model of each document, which is interpolated
+// with the language model of the collection:
+// p(t|d) = (1 - lambda) * tf / |d| + lambda * p(t|C).
+func (s *Searcher) JelinekMercerQuery(query string) (results []int) {
+	resList := s.jelinekMercerScores(query)
+	sort.Sort(resList)
+	results = resList.ids
+	return
+}
+
+// dirichletScores returns the unsorted scores of DirichletQuery.
+// Only the documents that contain a query term are scored, with the
+// log likelihood of the query less the part that is equal in all documents.
+func (s *Searcher) dirichletScores(query string) (resList *ScoringList) {
+	resList = &ScoringList{}
+	queryLength := 0
+	for _, queryTerm := range s.terms(query) {
+		pc := s.collectionProbability(queryTerm)
+		if pc == 0 {
+			// Terms that are not in the collection have the same likelihood in all documents.
+			continue
+		}
+		queryLength++
+		for it := s.ii.Iterator(queryTerm); it.Next(); {
+			resList.add(it.DocID(), s.dirichletWeight(it.Freq(), pc))
+		}
+	}
+	// Longer documents give less weight to the collection model, which
+	// lowers the likelihood of the query terms they do not contain.
+	for i, docID := range resList.ids {
+		resList.scores[i] += s.dirichletLengthWeight(queryLength, docID)
+	}
+	return
+}
+
+// dirichletWeight returns the weight of a query term with the frequency tf in
+// a document and the probability pc in the collection.
+func (s *Searcher) dirichletWeight(tf int, pc float64) float64 {
+	return math.Log(1 + float64(tf)/(s.ql.Mu*pc))
+}
+
+// dirichletLengthWeight returns the weight of the length of a document
+// for a query of queryLength terms that are in the collection.
+func (s *Searcher) dirichletLengthWeight(queryLength int, docID int) float64 {
+	mu := s.ql.Mu
+	return float64(queryLength) * math.Log(mu/(float64(s.documentLength(docID))+mu))
+}
+
+// jelinekMercerScores returns the unsorted scores of JelinekMercerQuery.
+// Only the documents that contain a query term are scored, with the
+// log likelihood of the query less the part that is equal in all documents.
+func (s *Searcher) jelinekMercerScores(query string) (resList *ScoringList) {
+	resList = &ScoringList{}
+	for _, queryTerm := range s.terms(query) {
+		pc := s.collectionProbability(queryTerm)
+		if pc == 0 {
+			continue
+		}
+		for it := s.ii.Iterator(queryTerm); it.Next(); {
+			resList.add(it.DocID(), s.jelinekMercerWeight(it.Freq(), pc, it.DocID()))
+		}
+	}
+	return
+}
+
+// jelinekMercerWeight returns the weight of a query term with the frequency
+// tf in a document and the probability pc in the collection.
+func (s *Searcher) jelinekMercerWeight(tf int, pc float64, docID int) float64 {
+	lambda := s.ql.Lambda
+	return math.Log(1 + (1-lambda)*s.documentProbability(tf, docID)/(lambda*pc))
+}
+
+// explainDirichlet explains the score of a document in DirichletQuery as the
+// sum of the weights of the query terms and the weight of the document length.
+func (s *Searcher) explainDirichlet(query string, docID int) *Explanation {
+	var details []Explanation
+	queryLength := 0
+	for _, queryTerm := range s.terms(query) {
+		pc := s.collectionProbability(queryTerm)
+		if pc == 0 {
+			continue
+		}
+		queryLength++
+		if tf := s.ii.TermFrequency(queryTerm, docID); tf > 0 {
+			details = append(details, Explanation{
+				Value:       s.dirichletWeight(tf, pc),
+				Description: fmt.Sprintf("weight of %q, computed as ln(1 + tf / (mu * p(t|C))) from:", queryTerm),
+				Details: []Explanation{
+					explainValue(float64(tf), "tf, frequency of the term in the document"),
+					explainValue(s.ql.Mu, "mu"), s.explainCollectionProbability(queryTerm),
+				},
+			})
+		}
+	}
+	if len(details) == 0 {
+		return nil
+	}
+	details = append(details, Explanation{
+		Value:       s.dirichletLengthWeight(queryLength, docID),
+		Description: "document length, computed as |q| * ln(mu / (|d| + mu)) from:",
+		Details: []Explanation{
+			explainValue(float64(queryLength), "|q|, number of query terms in the collection"),
+			explainValue(s.ql.Mu, "mu"), explainValue(float64(s.documentLength(docID)), "|d|, length of the document"),
+		},
+	})
+	return explainSum(details)
+}
+
+// explainJelinekMercer explains the score of a document in JelinekMercerQuery
+// as the sum of the weights of the query terms.
+func (s *Searcher) explainJelinekMercer(query string, docID int) *Explanation {
+	var details []Explanation
+	for _, queryTerm := range s.terms(query) {
+		pc := s.collectionProbability(queryTerm)
+		tf := s.ii.TermFrequency(queryTerm, docID)
+		if pc == 0 || tf == 0 {
+			continue
+		}
+		details = append(details, Explanation{
+			Value:       s.jelinekMercerWeight(tf, pc, docID),
+			Description: fmt.Sprintf("weight of %q, computed as ln(1 + (1 - lambda) * p(t|d) / (lambda * p(t|C))) from:", queryTerm),
+			Details: []Explanation{
+				{
+					Value:       s.documentProbability(tf, docID),
+					Description: "p(t|d), tf / |d|, probability of the term in the document",
+					Details: []Explanation{
+						explainValue(float64(tf), "tf, frequency of the term in the document"),
+						explainValue(float64(s.documentLength(docID)), "|d|, length of the document"),
+					},
+				},
+				explainValue(s.ql.Lambda, "lambda"), s.explainCollectionProbability(queryTerm),
+			},
+		})
+	}
+	return explainSum(details)
+}
+
+func (s *Searcher) explainCollectionProbability(term string) Explanation {
+	return Explanation{
+		Value:       s.collectionProbability(term),
+		Description: "p(t|C), cf / |C|, probability of the term in the collection",
+		Details: []Explanation{
+			explainValue(float64(s.ii.CollectionFrequency(term)), "cf, frequency of the term in all documents"),
+			explainValue(float64(s.ii.collectionLength), "|C|, length of the collection"),
+		},
+	}
+}
+
+// collectionProbability returns the probability of the term
+// in the language model of the collection.
+func (s *Searcher) collectionProbability(term string) float64 {
+	if s.ii.collectionLength == 0 {
+		return 0
+	}
+	return float64(s.ii.CollectionFrequency(term)) / float64(s.ii.collectionLength)
+}
+
+// documentProbability returns the probability of a term with the
+// frequency tf in the language model of a document.
+func (s *Searcher) documentProbability(tf int, docID int) float64 {
+	return float64(tf) / float64(s.documentLength(docID))
+}
+
+// documentLength returns the number of words in the Title and Body of a
+// document, which are the words of the document in the inverted index.
+func (s *Searcher) documentLength(docID int) int {
+	return s.fieldLen["title"].docLength(docID) + s.fieldLen["body"].docLength(docID)
+}
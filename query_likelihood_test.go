@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// queryLogLikelihood returns the log likelihood of the query terms
+// in the smoothed language model of the document.
+func queryLogLikelihood(s *Searcher, query string, docID int, smoothing func(tf, docLength, pc float64) float64) (ll float64) {
+	for _, term := range s.terms(query) {
+		if pc := s.collectionProbability(term); pc > 0 {
+			ll += math.Log(smoothing(float64(s.ii.TermFrequency(term, docID)), float64(s.documentLength(docID)), pc))
+		}
+	}
+	return
+}
+
+func TestSearcher_QueryLikelihood(t *testing.T) {
+	s := SetUpSearcher()
+	params := s.ql
+	scorers := []struct {
+		name      string
+		scores    scoringFunc
+		smoothing func(tf, docLength, pc float64) float64
+	}{
+		{"Dirichlet", s.dirichletScores, func(tf, docLength, pc float64) float64 {
+			return (tf + params.Mu*pc) / (docLength + params.Mu)
+		}},
+		{"Jelinek-Mercer", s.jelinekMercerScores, func(tf, docLength, pc float64) float64 {
+			return (1-params.Lambda)*tf/docLength + params.Lambda*pc
+		}},
+	}
+	queries := []string{"statistic", "matrix communication channel", "latent semantic unknownword", "the analysis of the data"}
+	for _, scorer := range scorers {
+		for _, query := range queries {
+			// Scores differ from the log likelihood by the same amount in all documents.
+			resList := scorer.scores(query)
+			if resList.Len() == 0 {
+				t.Errorf("No %s results for %q.", scorer.name, query)
+				continue
+			}
+			offset := queryLogLikelihood(s, query, resList.ids[0], scorer.smoothing) - resList.scores[0]
+			for i, docID := range resList.ids {
+				if diff := queryLogLikelihood(s, query, docID, scorer.smoothing) - resList.scores[i]; math.Abs(diff-offset) > 1e-9 {
+					t.Errorf("Wrong %s score of %d for %q: Got %v, Wanted %v.", scorer.name, docID, query, resList.scores[i], resList.scores[i]+diff-offset)
+				}
+			}
+		}
+	}
+
+	pairs := []struct {
+		query   string
+		fn      queryFunc
+		results []int
+	}{
+		{"cohen's kappa", s.DirichletQuery, []int{1}},
+		{"matrix communication channel", s.DirichletQuery, []int{3, 2}},
+		{"matrix communication channel", s.JelinekMercerQuery, []int{3, 2}},
+		{"unknownword", s.JelinekMercerQuery, nil},
+	}
+	for _, pair := range pairs {
+		results := pair.fn(pair.query)
+		if len(results) != len(pair.results) {
+			t.Errorf("Wrong results for %q: Got %v, Wanted %v.", pair.query, results, pair.results)
+			continue
+		}
+		for i := range results {
+			if results[i] != pair.results[i] {
+				t.Errorf("Wrong results for %q: Got %v, Wanted %v.", pair.query, results, pair.results)
+				break
+			}
+		}
+	}
+}
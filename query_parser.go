@@ -0,0 +1,454 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// queryClause is a single term or quoted phrase of a free text query.
+type queryClause struct {
+	// field restricts the clause to one of the documentFields if not empty.
+	field  string
+	tokens []string
+}
+
+// clausePattern matches a word or a quoted phrase with an optional field prefix.
+// An unterminated quote extends to the end of the query.
+var clausePattern = regexp.MustCompile(`(?:([A-Za-z]+):)?(?:"([^"]*)(?:"|$)|([^\s"]+))`)
+
+// parseClauses splits a free text query into clauses. Each token of a word
+// is a separate clause, while the tokens of a quoted phrase form a single clause.
+// Words and phrases prefixed with the name of a field, e.g. title:kappa or
+// body:"latent semantic", are restricted to that field.
+func parseClauses(query string, analyzer Analyzer) (clauses []queryClause) {
+	for _, match := range clausePattern.FindAllStringSubmatch(query, -1) {
+		field := strings.ToLower(match[1])
+		if field != "" && !isDocumentField(field) {
+			// Not a field, e.g. "http:".
+			field = ""
+			match[3] = match[0]
+			match[2] = ""
+		}
+		if match[3] == "" {
+			if tokens := analyzeTerms(analyzer, match[2]); len(tokens) > 0 {
+				clauses = append(clauses, queryClause{field, tokens})
+			}
+			continue
+		}
+		for _, token := range analyzeTerms(analyzer, match[3]) {
+			clauses = append(clauses, queryClause{field, []string{token}})
+		}
+	}
+	return
+}
+
+// Parser for the query language of BooleanQuery.
+//
+//	query    = or EOF
+//	or       = and { ("OR" | "||") and }
+//	and      = not { [ "AND" | "&&" ] not }
+//	not      = ( "NOT" | "!" ) not | primary
+//	primary  = "(" or ")" | operand
+//	operand  = phrase | word { ( "NEAR/k" | "BEFORE/k" ) word }
+//
+// Adjacent operands without an operator are joined by AND, so multi-word
+// operands such as "natural language" match documents containing both words.
+// A word that contains several tokens (e.g. "code-division") and a quoted
+// phrase match the tokens as an exact phrase. The k of NEAR/k and BEFORE/k
+// is at most maxProximityDistance.
+
+// ParseError describes a syntax error in a query.
+type ParseError struct {
+	// Pos is the byte offset of the offending part of the query.
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+type queryTokenKind int
+
+const (
+	tokenEOF queryTokenKind = iota
+	tokenWord
+	tokenPhrase
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenNear
+	tokenBefore
+	tokenLeftParen
+	tokenRightParen
+)
+
+// queryToken is a single lexical token of a query.
+type queryToken struct {
+	kind queryTokenKind
+	text string
+	pos  int
+	// field restricts words and phrases to one of the documentFields.
+	field string
+	// k is the distance of a NEAR/k or BEFORE/k operator.
+	k int
+}
+
+// describe returns a description of the token to use in error messages.
+func (tok queryToken) describe() string {
+	switch tok.kind {
+	case tokenEOF:
+		return "end of query"
+	case tokenPhrase:
+		return "phrase " + strconv.Quote(tok.text)
+	case tokenWord:
+		return "word " + strconv.Quote(tok.text)
+	}
+	return strconv.Quote(tok.text)
+}
+
+// lexQuery splits the query into tokens.
+func lexQuery(query string) (tokens []queryToken, err error) {
+	pos := 0
+	for pos < len(query) {
+		r, size := utf8.DecodeRuneInString(query[pos:])
+		switch {
+		case unicode.IsSpace(r):
+			pos += size
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: tokenLeftParen, text: "(", pos: pos})
+			pos++
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: tokenRightParen, text: ")", pos: pos})
+			pos++
+		case r == '!':
+			tokens = append(tokens, queryToken{kind: tokenNot, text: "!", pos: pos})
+			pos++
+		case strings.HasPrefix(query[pos:], "&&"):
+			tokens = append(tokens, queryToken{kind: tokenAnd, text: "&&", pos: pos})
+			pos += 2
+		case strings.HasPrefix(query[pos:], "||"):
+			tokens = append(tokens, queryToken{kind: tokenOr, text: "||", pos: pos})
+			pos += 2
+		case r == '"':
+			tok, err := lexPhrase(query, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			pos += len(tok.text) + 2
+		default:
+			end := pos
+			for end < len(query) {
+				r, size := utf8.DecodeRuneInString(query[end:])
+				rest := query[end:]
+				if unicode.IsSpace(r) || strings.ContainsRune(`()"`, r) ||
+					strings.HasPrefix(rest, "&&") || strings.HasPrefix(rest, "||") {
+					break
+				}
+				end += size
+			}
+			word := query[pos:end]
+			if field := strings.TrimSuffix(word, ":"); field != word && isDocumentField(field) && strings.HasPrefix(query[end:], "\"") {
+				// A phrase restricted to a field, e.g. title:"cohen's kappa".
+				tok, err := lexPhrase(query, end)
+				if err != nil {
+					return nil, err
+				}
+				tok.pos, tok.field = pos, field
+				tokens = append(tokens, tok)
+				end += len(tok.text) + 2
+			} else {
+				tokens = append(tokens, classifyWord(word, pos))
+			}
+			pos = end
+		}
+	}
+	tokens = append(tokens, queryToken{kind: tokenEOF, pos: len(query)})
+	return
+}
+
+// lexPhrase returns the phrase token starting with the quote at pos.
+func lexPhrase(query string, pos int) (queryToken, error) {
+	end := strings.IndexByte(query[pos+1:], '"')
+	if end == -1 {
+		return queryToken{}, &ParseError{Pos: pos, Msg: "unterminated phrase"}
+	}
+	return queryToken{kind: tokenPhrase, text: query[pos+1 : pos+1+end], pos: pos}, nil
+}
+
+// classifyWord returns the token for a word, which is either
+// an operator or a search term.
+func classifyWord(word string, pos int) queryToken {
+	tok := queryToken{kind: tokenWord, text: word, pos: pos}
+	if idx := strings.IndexByte(word, ':'); idx != -1 && isDocumentField(strings.ToLower(word[:idx])) {
+		tok.field, tok.text = strings.ToLower(word[:idx]), word[idx+1:]
+		return tok
+	}
+	switch word {
+	case "AND":
+		tok.kind = tokenAnd
+	case "OR":
+		tok.kind = tokenOr
+	case "NOT":
+		tok.kind = tokenNot
+	default:
+		if op, k, ok := parseProximityOperator(word); ok {
+			tok.k = k
+			if op == "NEAR" {
+				tok.kind = tokenNear
+			} else {
+				tok.kind = tokenBefore
+			}
+		}
+	}
+	return tok
+}
+
+// parseBooleanQuery parses the query into a tree of boolean operations.
+func parseBooleanQuery(query string, analyzer Analyzer) (booleanNode, error) {
+	tokens, err := lexQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens, analyzer: analyzer}
+	if p.peek().kind == tokenEOF {
+		return nil, &ParseError{Pos: 0, Msg: "empty query"}
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		if tok.kind == tokenRightParen {
+			return nil, &ParseError{Pos: tok.pos, Msg: "unmatched closing parenthesis"}
+		}
+		return nil, &ParseError{Pos: tok.pos, Msg: "unexpected " + tok.describe()}
+	}
+	return node, nil
+}
+
+type queryParser struct {
+	tokens   []queryToken
+	next     int
+	analyzer Analyzer
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.next]
+}
+
+func (p *queryParser) consume() queryToken {
+	tok := p.tokens[p.next]
+	if tok.kind != tokenEOF {
+		p.next++
+	}
+	return tok
+}
+
+func (p *queryParser) parseOr() (node booleanNode, err error) {
+	if node, err = p.parseAnd(); err != nil {
+		return
+	}
+	for p.peek().kind == tokenOr {
+		p.consume()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		node = orNode{node, right}
+	}
+	return
+}
+
+func (p *queryParser) parseAnd() (node booleanNode, err error) {
+	if node, err = p.parseNot(); err != nil {
+		return
+	}
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.consume()
+		case tokenWord, tokenPhrase, tokenNot, tokenLeftParen:
+			// Implicit AND between adjacent operands.
+		default:
+			return
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		node = andNode{node, right}
+	}
+}
+
+func (p *queryParser) parseNot() (booleanNode, error) {
+	if p.peek().kind == tokenNot {
+		p.consume()
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{child}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (booleanNode, error) {
+	tok := p.consume()
+	switch tok.kind {
+	case tokenLeftParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokenRightParen {
+			return nil, &ParseError{Pos: tok.pos, Msg: "missing closing parenthesis"}
+		}
+		p.consume()
+		return node, nil
+	case tokenPhrase:
+		return termNode{tok.field, analyzeTerms(p.analyzer, tok.text)}, nil
+	case tokenWord:
+		return p.parseProximity(tok)
+	}
+	return nil, &ParseError{Pos: tok.pos, Msg: "expected a term but found " + tok.describe()}
+}
+
+// parseProximity parses a word followed by any number of proximity operators.
+// Operators apply to the adjacent tokens of words containing several tokens.
+func (p *queryParser) parseProximity(word queryToken) (booleanNode, error) {
+	left := analyzeTerms(p.analyzer, word.text)
+	node := booleanNode(termNode{word.field, left})
+	for p.peek().kind == tokenNear || p.peek().kind == tokenBefore {
+		op := p.consume()
+		next := p.consume()
+		if next.kind != tokenWord {
+			return nil, &ParseError{Pos: next.pos, Msg: "expected a word after " + op.text + " but found " + next.describe()}
+		}
+		if next.field != word.field {
+			return nil, &ParseError{Pos: next.pos, Msg: "words joined by " + op.text + " must be in the same field"}
+		}
+		right := analyzeTerms(p.analyzer, next.text)
+		if len(left) == 0 || len(right) == 0 {
+			node = termNode{}
+		} else {
+			prox := proximityNode{word.field, left[len(left)-1], right[0], op.k, op.kind == tokenBefore}
+			// The proximity node already requires a single term to be present.
+			if term, ok := node.(termNode); ok && len(term.tokens) == 1 {
+				node = prox
+			} else {
+				node = andNode{node, prox}
+			}
+		}
+		if len(right) > 1 {
+			node = andNode{node, termNode{next.field, right}}
+		}
+		word, left = next, right
+	}
+	return node, nil
+}
+
+// booleanNode is a node in the tree of a parsed boolean query.
+type booleanNode interface {
+	// iterator returns an iterator over the IDs of documents matching the node.
+	iterator(s *Searcher) DocIterator
+	String() string
+}
+
+// termNode matches documents containing a term or a phrase.
+type termNode struct {
+	field  string
+	tokens []string
+}
+
+// andNode matches documents matching both nodes.
+type andNode struct {
+	left, right booleanNode
+}
+
+// orNode matches documents matching either node.
+type orNode struct {
+	left, right booleanNode
+}
+
+// notNode matches documents not matching the node.
+type notNode struct {
+	child booleanNode
+}
+
+// proximityNode matches documents where two terms appear within k words.
+type proximityNode struct {
+	field       string
+	left, right string
+	k           int
+	ordered     bool
+}
+
+func (n termNode) iterator(s *Searcher) DocIterator {
+	if len(n.tokens) == 0 {
+		return newSliceIterator(nil)
+	}
+	return s.clauseIterator(queryClause{n.field, n.tokens})
+}
+
+func (n andNode) iterator(s *Searcher) DocIterator {
+	// Avoids iterating over the complement of a NOT over all documents.
+	if not, ok := n.right.(notNode); ok {
+		return &exclusionIterator{n.left.iterator(s), not.child.iterator(s)}
+	}
+	if not, ok := n.left.(notNode); ok {
+		return &exclusionIterator{n.right.iterator(s), not.child.iterator(s)}
+	}
+	return newConjunctionIterator([]DocIterator{n.left.iterator(s), n.right.iterator(s)})
+}
+
+func (n orNode) iterator(s *Searcher) DocIterator {
+	return newDisjunctionIterator([]DocIterator{n.left.iterator(s), n.right.iterator(s)})
+}
+
+func (n notNode) iterator(s *Searcher) DocIterator {
+	return &exclusionIterator{newSliceIterator(s.docLen.documentIDs()), n.child.iterator(s)}
+}
+
+func (n proximityNode) iterator(s *Searcher) DocIterator {
+	return newSliceIterator(s.index(n.field).ProximityPostings(n.left, n.right, n.k, n.ordered))
+}
+
+func (n termNode) String() string {
+	str := strconv.Quote(strings.Join(n.tokens, " "))
+	if len(n.tokens) == 1 {
+		str = n.tokens[0]
+	}
+	if n.field != "" {
+		str = n.field + ":" + str
+	}
+	return str
+}
+
+func (n andNode) String() string {
+	return "(" + n.left.String() + " AND " + n.right.String() + ")"
+}
+
+func (n orNode) String() string {
+	return "(" + n.left.String() + " OR " + n.right.String() + ")"
+}
+
+func (n notNode) String() string {
+	return "NOT " + n.child.String()
+}
+
+func (n proximityNode) String() string {
+	op := "NEAR"
+	if n.ordered {
+		op = "BEFORE"
+	}
+	if n.field != "" {
+		return fmt.Sprintf("(%s:%s %s/%d %s:%s)", n.field, n.left, op, n.k, n.field, n.right)
+	}
+	return fmt.Sprintf("(%s %s/%d %s)", n.left, op, n.k, n.right)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseClauses(t *testing.T) {
+	pairs := []struct{
+		query string
+		clauses []queryClause
+	}{
+		{"Latent semantic", []queryClause{{"", []string{"latent"}}, {"", []string{"semantic"}}}},
+		{`"latent semantic" code-division`, []queryClause{{"", []string{"latent", "semantic"}}, {"", []string{"code"}}, {"", []string{"division"}}}},
+		{`title:kappa BODY:"a statistic"`, []queryClause{{"title", []string{"kappa"}}, {"body", []string{"a", "statistic"}}}},
+		{`http://wikipedia.org "" "unterminated phrase`, []queryClause{{"", []string{"http"}}, {"", []string{"wikipedia"}}, {"", []string{"org"}}, {"", []string{"unterminated", "phrase"}}}},
+		{"", nil},
+	}
+	for _, pair := range pairs {
+		clauses := parseClauses(pair.query, SimpleAnalyzer)
+		if !reflect.DeepEqual(clauses, pair.clauses) {
+			t.Errorf("Wrong clauses for %q: Got %v, Wanted %v.", pair.query, clauses, pair.clauses)
+		}
+	}
+}
+
+func TestLexQuery(t *testing.T) {
+	pairs := []struct{
+		query string
+		kinds []queryTokenKind
+	}{
+		{"A || B", []queryTokenKind{tokenWord, tokenOr, tokenWord, tokenEOF}},
+		{"A&&B", []queryTokenKind{tokenWord, tokenAnd, tokenWord, tokenEOF}},
+		{"NOT (a OR b) AND c", []queryTokenKind{tokenNot, tokenLeftParen, tokenWord, tokenOr, tokenWord, tokenRightParen, tokenAnd, tokenWord, tokenEOF}},
+		{`!"a b" c NEAR/2 d BEFORE/3 e`, []queryTokenKind{tokenNot, tokenPhrase, tokenWord, tokenNear, tokenWord, tokenBefore, tokenWord, tokenEOF}},
+		{"and or not near/2 NEAR/x", []queryTokenKind{tokenWord, tokenWord, tokenWord, tokenWord, tokenWord, tokenEOF}},
+		{"", []queryTokenKind{tokenEOF}},
+	}
+	for _, pair := range pairs {
+		tokens, err := lexQuery(pair.query)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v.", pair.query, err)
+			continue
+		}
+		if len(tokens) == len(pair.kinds) {
+			for i := range tokens {
+				if tokens[i].kind != pair.kinds[i] {
+					t.Errorf("Wrong token kind for %q: Got %v, Wanted %v.", pair.query, tokens, pair.kinds)
+				}
+			}
+		} else {
+			t.Errorf("Different number of tokens for %q: Got %v, Wanted %v.", pair.query, tokens, pair.kinds)
+		}
+	}
+}
+
+func TestParseBooleanQuery(t *testing.T) {
+	pairs := []struct{
+		query string
+		tree string
+	}{
+		{"A || B", "(a OR b)"},
+		{"A || B && C", "(a OR (b AND c))"},
+		{"A && B || C && D", "((a AND b) OR (c AND d))"},
+		{"(A || B) && C", "((a OR b) AND c)"},
+		{"NOT A AND !B", "(NOT a AND NOT b)"},
+		{"NOT NOT A", "NOT NOT a"},
+		{"natural language || kappa", "((natural AND language) OR kappa)"},
+		{`"Latent Semantic" code-division`, `("latent semantic" AND "code division")`},
+		{"code NEAR/3 access", "(code NEAR/3 access)"},
+		{"a BEFORE/1 b NEAR/2 c", "((a BEFORE/1 b) AND (b NEAR/2 c))"},
+		{"a NEAR/1000 b", "(a NEAR/99 b)"},
+		{`title:Kappa || body:"a statistic" url:wiki`, `(title:kappa OR (body:"a statistic" AND url:wiki))`},
+		{"title:a NEAR/2 title:b other:c", `((title:a NEAR/2 title:b) AND "other c")`},
+	}
+	for _, pair := range pairs {
+		node, err := parseBooleanQuery(pair.query, SimpleAnalyzer)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v.", pair.query, err)
+		} else if node.String() != pair.tree {
+			t.Errorf("Wrong tree for %q: Got %s, Wanted %s.", pair.query, node.String(), pair.tree)
+		}
+	}
+}
+
+func TestParseBooleanQuery_Errors(t *testing.T) {
+	pairs := []struct{
+		query string
+		pos int
+	}{
+		{"", 0},
+		{"   ", 0},
+		{"A||B&&||", 6},
+		{"|| technique", 0},
+		{"(a || b", 0},
+		{"a || b)", 6},
+		{"a && (b || )", 11},
+		{`a "b c`, 2},
+		{"a NEAR/2", 8},
+		{"a NEAR/2 (b)", 9},
+		{"NOT", 3},
+		{"title:a NEAR/2 b", 15},
+	}
+	for _, pair := range pairs {
+		_, err := parseBooleanQuery(pair.query, SimpleAnalyzer)
+		parseErr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("Expected a ParseError for %q: Got %v.", pair.query, err)
+		} else if parseErr.Pos != pair.pos {
+			t.Errorf("Wrong position for %q: Got %d, Wanted %d (%v).", pair.query, parseErr.Pos, pair.pos, err)
+		}
+	}
+}
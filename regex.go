@@ -0,0 +1,263 @@
+package main
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// regexPattern is a regular expression of RegexQuery, which matches whole
+// terms, and the k-grams that every term that it matches contains.
+type regexPattern struct {
+	re    *regexp.Regexp
+	grams []string
+	// minLength is the fewest runes of a term that the pattern matches.
+	minLength int
+}
+
+// regexLiteral is a string that every match of a regular expression contains,
+// and whether it is at the start or end of the match.
+type regexLiteral struct {
+	text           string
+	atStart, atEnd bool
+}
+
+// regexInfo describes the strings that a regular expression matches. If exact,
+// it only matches text, otherwise every match contains all the literals.
+type regexInfo struct {
+	exact    bool
+	text     string
+	literals []regexLiteral
+}
+
+// regexTokenPattern matches the patterns of a regex query, which are separated by spaces.
+var regexTokenPattern = regexp.MustCompile(`\S+`)
+
+// parseRegexQuery parses the regular expressions of a query, which are separated
+// by spaces and can be enclosed in slashes, e.g. "/an[ae]l.+s/". The literals of
+// the patterns are normalized by the analyzer, and letters match in any case.
+// Returns a ParseError at the first pattern that is not a valid regular expression.
+func parseRegexQuery(query string, analyzer Analyzer, k int) (patterns []regexPattern, err error) {
+	for _, loc := range regexTokenPattern.FindAllStringIndex(query, -1) {
+		pattern := query[loc[0]:loc[1]]
+		if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			pattern = pattern[1 : len(pattern)-1]
+		}
+		re, err := syntax.Parse(pattern, syntax.Perl|syntax.FoldCase)
+		if err != nil {
+			return nil, &ParseError{Pos: loc[0], Msg: err.Error()}
+		}
+		re = normalizeRegexLiterals(re.Simplify(), analyzer)
+		p := regexPattern{
+			re:        regexp.MustCompile(`^(?:` + re.String() + `)$`),
+			minLength: regexMinLength(re),
+		}
+		info := analyzeRegex(re)
+		if info.exact {
+			info.literals = []regexLiteral{{info.text, true, true}}
+		}
+		for _, literal := range info.literals {
+			p.grams = append(p.grams, literalKGrams(literal, k)...)
+		}
+		p.grams = uniqueStrings(p.grams)
+		patterns = append(patterns, p)
+	}
+	return
+}
+
+// normalizeRegexLiterals normalizes the literal strings of the regular
+// expression like the terms of the k-gram index are normalized.
+func normalizeRegexLiterals(re *syntax.Regexp, analyzer Analyzer) *syntax.Regexp {
+	if re.Op == syntax.OpLiteral {
+		re.Rune = []rune(analyzer.Normalize(string(re.Rune)))
+	}
+	for _, sub := range re.Sub {
+		normalizeRegexLiterals(sub, analyzer)
+	}
+	return re
+}
+
+// analyzeRegex returns the strings that the regular expression matches.
+func analyzeRegex(re *syntax.Regexp) regexInfo {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return regexInfo{exact: true, text: string(re.Rune)}
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return regexInfo{exact: true}
+	case syntax.OpCapture:
+		return analyzeRegex(re.Sub[0])
+	case syntax.OpPlus:
+		// The first repetition is at the start and the last one at the end.
+		sub := analyzeRegex(re.Sub[0])
+		if sub.exact {
+			return regexInfo{literals: []regexLiteral{{sub.text, true, false}, {sub.text, false, true}}}
+		}
+		return sub
+	case syntax.OpConcat:
+		return analyzeRegexConcat(re.Sub)
+	}
+	// Alternations, optional repetitions and character classes have no literals.
+	return regexInfo{}
+}
+
+// analyzeRegexConcat returns the strings that the concatenation of the regular
+// expressions matches, joining the literals of adjacent expressions.
+func analyzeRegexConcat(subs []*syntax.Regexp) (info regexInfo) {
+	infos := make([]regexInfo, len(subs))
+	info.exact = true
+	for i, sub := range subs {
+		infos[i] = analyzeRegex(sub)
+		info.exact = info.exact && infos[i].exact
+		info.text += infos[i].text
+	}
+	if info.exact {
+		return
+	}
+	info.text = ""
+	// cur is the literal that the next expression continues, if any.
+	cur := &regexLiteral{atStart: true}
+	for _, sub := range infos {
+		if sub.exact {
+			if cur == nil {
+				cur = &regexLiteral{}
+			}
+			cur.text += sub.text
+			continue
+		}
+		var next *regexLiteral
+		for _, literal := range sub.literals {
+			if literal.atStart {
+				if cur != nil {
+					literal.text, literal.atStart = cur.text+literal.text, cur.atStart
+					cur = nil
+				} else {
+					literal.atStart = false
+				}
+			}
+			if literal.atEnd {
+				literal.atEnd = false
+				next = &regexLiteral{literal.text, literal.atStart, false}
+			} else {
+				info.literals = append(info.literals, literal)
+			}
+		}
+		if cur != nil && cur.text != "" {
+			info.literals = append(info.literals, *cur)
+		}
+		cur = next
+	}
+	if cur != nil && cur.text != "" {
+		cur.atEnd = true
+		info.literals = append(info.literals, *cur)
+	}
+	return
+}
+
+// regexMinLength returns the fewest runes that the regular expression matches.
+func regexMinLength(re *syntax.Regexp) (length int) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return len(re.Rune)
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return 1
+	case syntax.OpCapture, syntax.OpPlus:
+		return regexMinLength(re.Sub[0])
+	case syntax.OpRepeat:
+		return re.Min * regexMinLength(re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			length += regexMinLength(sub)
+		}
+		return
+	case syntax.OpAlternate:
+		length = maxInt
+		for _, sub := range re.Sub {
+			length = min(length, regexMinLength(sub))
+		}
+		return
+	}
+	return 0
+}
+
+// literalKGrams returns the k-grams of the literal, padded with '$' like
+// buildKGrams at the start and end of the term.
+func literalKGrams(literal regexLiteral, k int) (grams []string) {
+	padding := strings.Repeat("$", k-1)
+	text := literal.text
+	if literal.atStart {
+		text = padding + text
+	}
+	if literal.atEnd {
+		text += padding
+	}
+	runes := []rune(text)
+	for i := 0; i+k <= len(runes); i++ {
+		grams = append(grams, string(runes[i:i+k]))
+	}
+	return
+}
+
+// regexSurfaces returns the terms of the k-gram index that the pattern matches.
+// The candidates are the terms that contain all the k-grams of the pattern,
+// and the terms that are too short to have k-grams if the pattern can match them.
+func (s *Searcher) regexSurfaces(p regexPattern) (terms []string) {
+	var candidates []string
+	if len(p.grams) == 0 {
+		candidates = s.ki.Terms()
+	} else {
+		candidates = s.ki.termsWithKGrams(p.grams)
+		if p.minLength < s.ki.k-1 {
+			candidates = append(candidates, s.ki.shortTerms()...)
+		}
+	}
+	for _, term := range uniqueStrings(candidates) {
+		if p.re.MatchString(term) {
+			terms = append(terms, term)
+		}
+	}
+	return
+}
+
+// regexExpansions returns the expansions of each pattern of RegexQuery,
+// which are the terms that match the pattern and are all equally close.
+// Malformed queries have no expansions.
+func (s *Searcher) regexExpansions(query string) (expansions []queryExpansion) {
+	patterns, err := parseRegexQuery(query, s.analyzer, s.ki.k)
+	if err != nil {
+		return
+	}
+	queryTerms := regexTokenPattern.FindAllString(query, -1)
+	for i, p := range patterns {
+		var surfaces []termExpansion
+		for _, surface := range s.regexSurfaces(p) {
+			surfaces = append(surfaces, newTermExpansion(surface, 1, 1))
+		}
+		expansions = append(expansions, queryExpansion{queryTerms[i], s.expandSurfaces(surfaces)})
+	}
+	return
+}
+
+// RegexQuery returns a ranked list of documents that contain a term matching
+// each of the regular expressions of the query, e.g. "/an[ae]l.+s/".
+// A regular expression matches whole terms before stemming. The k-grams of the
+// literals that every match contains select the candidate terms from the
+// k-gram index, which are then matched by the regular expression.
+// Documents are ranked like WildcardQuery, where all matching terms are equally close.
+func (s *Searcher) RegexQuery(query string) (results []int) {
+	resList := s.regexScores(query)
+	sort.Sort(resList)
+	results = resList.ids
+	return
+}
+
+// regexScores returns the unsorted scores of RegexQuery.
+func (s *Searcher) regexScores(query string) *ScoringList {
+	return s.expandedScores(s.regexExpansions(query))
+}
+
+// explainRegex explains the score of a document in RegexQuery.
+func (s *Searcher) explainRegex(query string, docID int) *Explanation {
+	return s.explainExpanded(s.regexExpansions(query), docID)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParseRegexQuery(t *testing.T) {
+	pairs := []struct {
+		query     string
+		grams     []string
+		minLength int
+	}{
+		{"/an[ae]l.+s/", []string{"$$a", "$an", "s$$"}, 6},
+		{"kappa", []string{"$$k", "$ka", "kap", "app", "ppa", "pa$", "a$$"}, 5},
+		{"K.PPA", []string{"$$k", "ppa", "pa$", "a$$"}, 5},
+		{"(ab)+c", []string{"$$a", "$ab", "abc", "bc$", "c$$"}, 3},
+		{"x(ab|cd)y", []string{"$$x", "y$$"}, 4},
+		{"sem.*ic", []string{"$$s", "$se", "sem", "ic$", "c$$"}, 5},
+		{".*", nil, 0},
+	}
+	for _, pair := range pairs {
+		patterns, err := parseRegexQuery(pair.query, DefaultAnalyzer, 3)
+		if err != nil || len(patterns) != 1 {
+			t.Errorf("Wrong patterns of %q: Got %v (%v).", pair.query, patterns, err)
+			continue
+		}
+		grams := patterns[0].grams
+		sort.Strings(grams)
+		sort.Strings(pair.grams)
+		if !reflect.DeepEqual(grams, pair.grams) {
+			t.Errorf("Wrong k-grams of %q: Got %v, Wanted %v.", pair.query, grams, pair.grams)
+		}
+		if patterns[0].minLength != pair.minLength {
+			t.Errorf("Wrong minimum length of %q: Got %d, Wanted %d.", pair.query, patterns[0].minLength, pair.minLength)
+		}
+	}
+
+	_, err := parseRegexQuery("kappa /coh(en/", DefaultAnalyzer, 3)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Pos != 6 {
+		t.Errorf("Wrong error: Got %v, Wanted a syntax error at position 6.", err)
+	}
+}
+
+func TestSearcher_RegexQuery(t *testing.T) {
+	pairs := []struct {
+		query   string
+		results []int
+	}{
+		{"/an[ae]l.+s/", []int{2}},
+		{"/cohe.?/", []int{1}},
+		{"/K.PPA/ /coh[aeiou]n/", []int{1}},
+		{"/κ/", []int{1}},
+		{"/stat.*/", []int{1}},
+		{"/la?tent/ /.*ic/", []int{2}},
+		{"/x{3}/", []int{}},
+		{"/coh(en/", nil},
+	}
+	s := SetUpSearcher()
+	for _, pair := range pairs {
+		if res := s.RegexQuery(pair.query); !reflect.DeepEqual(res, pair.results) {
+			t.Errorf("Wrong results for %q: Got %v, Wanted %v.", pair.query, res, pair.results)
+		}
+	}
+}
+
+func TestKGramIndex_TermsWithKGrams(t *testing.T) {
+	ki := NewKGramIndex(3)
+	for _, term := range []string{"analysis", "analyses", "anal", "catalysis"} {
+		ki.addWordToPostingsList(term)
+	}
+	terms := ki.termsWithKGrams([]string{"$an", "sis", "s$$"})
+	sort.Strings(terms)
+	if !reflect.DeepEqual(terms, []string{"analysis"}) {
+		t.Errorf("Wrong terms: Got %v, Wanted [analysis].", terms)
+	}
+	if terms := ki.termsWithKGrams([]string{"$an", "zzz"}); len(terms) != 0 {
+		t.Errorf("Wrong terms: Got %v, Wanted none.", terms)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// Hit is a document that matches a query.
+type Hit struct {
+	ID int
+	// Score is only set for ranked search algorithms.
+	Score *float64
+	// MatchedTerms are the terms of the query, or the terms that
+	// it is expanded to, that the document contains.
+	MatchedTerms []string
+}
+
+// SearchResult is the result of a query, with the hits in the order of relevance.
+type SearchResult struct {
+	Hits []Hit
+	// TotalHits is the number of documents that match the query, which is
+	// more than the number of hits if only the top hits are returned.
+	TotalHits int
+	// Took is the time that the query took.
+	Took time.Duration
+}
+
+// hitIDs returns the document IDs of the hits.
+func hitIDs(hits []Hit) []int {
+	ids := make([]int, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	return ids
+}
+
+// termsFunc defines methods that take in a query string and return
+// the terms of the inverted index that the query is expanded to.
+type termsFunc func(string) []string
+
+// Search returns all the documents that are relevant to the query, where
+// relevance is defined by the given queryFunc. If scorer is not nil, the
+// hits are ranked and scored by it instead. The matched terms of the hits
+// are the terms of the termsFunc, and are not set if it is nil.
+func (s *Searcher) Search(query string, fn queryFunc, scorer scoringFunc, terms termsFunc) (result SearchResult) {
+	start := time.Now()
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	if scorer == nil {
+		result.Hits = newHits(fn(query), nil)
+	} else {
+		resList := scorer(query)
+		sort.Sort(resList)
+		result.Hits = newHits(resList.ids, resList.scores)
+	}
+	result.TotalHits = len(result.Hits)
+	if terms != nil {
+		s.setMatchedTerms(result.Hits, terms(query))
+	}
+	result.Took = time.Since(start)
+	return
+}
+
+// SearchTopK returns the k documents that are the most relevant to the query,
+// where relevance is defined by the given topKFunc. The total hits are the
+// number of documents that match any clause of the query, i.e. the documents
+// that would be scored without early termination. Counting them reads every
+// posting of the query terms, so the cost of SearchTopK is at least that of
+// a disjunction of the clauses, even though the topKFunc may skip most of them.
+func (s *Searcher) SearchTopK(query string, k int, fn topKFunc, terms termsFunc) (result SearchResult) {
+	start := time.Now()
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	resList := fn(query, k)
+	result.Hits = newHits(resList.ids, resList.scores)
+	result.TotalHits = s.clauseMatches(query)
+	if terms != nil {
+		s.setMatchedTerms(result.Hits, terms(query))
+	}
+	result.Took = time.Since(start)
+	return
+}
+
+func newHits(ids []int, scores []float64) []Hit {
+	hits := make([]Hit, len(ids))
+	for i, id := range ids {
+		hits[i].ID = id
+		if scores != nil {
+			hits[i].Score = &scores[i]
+		}
+	}
+	return hits
+}
+
+// setMatchedTerms sets the matched terms of the hits to the terms
+// that they contain, in the order of the terms.
+func (s *Searcher) setMatchedTerms(hits []Hit, terms []string) {
+	hitIndex := make(map[int]int, len(hits))
+	for i, hit := range hits {
+		hitIndex[hit.ID] = i
+	}
+	// Each postings list is read once rather than once for each hit.
+	for _, term := range uniqueStrings(terms) {
+		for it := s.ii.Iterator(term); it.Next(); {
+			if i, ok := hitIndex[it.DocID()]; ok {
+				hits[i].MatchedTerms = append(hits[i].MatchedTerms, term)
+			}
+		}
+	}
+}
+
+// clauseMatches returns the number of documents that match a clause of the query.
+// It iterates over the union of the postings lists of the clauses.
+func (s *Searcher) clauseMatches(query string) (count int) {
+	var its []DocIterator
+	for _, clause := range parseClauses(query, s.analyzer) {
+		its = append(its, s.clauseIterator(clause))
+	}
+	for it := newDisjunctionIterator(its); it.Next(); {
+		count++
+	}
+	return
+}
+
+// queryTerms returns the terms of the inverted index that a query of the
+// search algorithm is expanded to, including the terms that fuzzy, wildcard
+// and regex queries are expanded to, with the analyzer of the request. Unless
+// allFields, the terms that are restricted to a field other than the body are excluded.
+func (s *Searcher) queryTerms(query string, funcName string, analyzer Analyzer, allFields bool) (terms []string) {
+	switch funcName {
+	case "Fuzzy":
+		terms = expansionTerms(s.fuzzyExpansions(query))
+	case "Wildcard":
+		terms = expansionTerms(s.wildcardExpansions(query))
+	case "Regex":
+		terms = expansionTerms(s.regexExpansions(query))
+	default:
+		for _, clause := range parseClauses(query, analyzer) {
+			if allFields || clause.field == "" || clause.field == "body" {
+				terms = append(terms, clause.tokens...)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearcher_Search(t *testing.T) {
+	s := SetUpSearcher()
+	pairs := []struct {
+		query    string
+		funcName string
+		ids      []int
+		matched  [][]string
+		ranked   bool
+	}{
+		{"matrix communication channel", "BM25", []int{3, 2}, [][]string{{"communic", "channel"}, {"matrix"}}, true},
+		{"cohen || latent", "Boolean", []int{1, 2}, [][]string{{"cohen"}, {"latent"}}, false},
+		{"kapa", "Fuzzy", []int{1}, [][]string{{"kappa"}}, true},
+		{"title:kappa", "BM25", []int{1}, [][]string{{"kappa"}}, true},
+	}
+	for _, pair := range pairs {
+		res := s.Search(pair.query, s.mapNameToFunc(pair.funcName), s.mapNameToScoringFunc(pair.funcName), s.mapNameToTermsFunc(pair.funcName))
+		if !reflect.DeepEqual(hitIDs(res.Hits), pair.ids) || res.TotalHits != len(pair.ids) {
+			t.Errorf("Wrong hits of %q: Got %v of %d, Wanted %v.", pair.query, hitIDs(res.Hits), res.TotalHits, pair.ids)
+			continue
+		}
+		for i, hit := range res.Hits {
+			if !reflect.DeepEqual(hit.MatchedTerms, pair.matched[i]) {
+				t.Errorf("Wrong matched terms of %d for %q: Got %v, Wanted %v.", hit.ID, pair.query, hit.MatchedTerms, pair.matched[i])
+			}
+			if (hit.Score != nil) != pair.ranked {
+				t.Errorf("Wrong score of %d for %q: Got %v, Wanted ranked %t.", hit.ID, pair.query, hit.Score, pair.ranked)
+			}
+		}
+		if res.Took <= 0 {
+			t.Errorf("Wrong time of %q: Got %v.", pair.query, res.Took)
+		}
+	}
+
+	if res := s.Search("kappa", s.BM25Query, nil, nil); res.Hits[0].MatchedTerms != nil {
+		t.Errorf("Wrong matched terms without a termsFunc: Got %v, Wanted nil.", res.Hits[0].MatchedTerms)
+	}
+}
+
+func TestSearcher_SearchTopK(t *testing.T) {
+	s := SetUpSearcher()
+	full := s.Search("matrix communication channel", s.BM25Query, s.bm25Scores, nil)
+	top := s.SearchTopK("matrix communication channel", 1, s.BM25TopK, s.mapNameToTermsFunc("BM25"))
+	if len(top.Hits) != 1 || top.Hits[0].ID != full.Hits[0].ID || *top.Hits[0].Score != *full.Hits[0].Score {
+		t.Errorf("Wrong top hits: Got %+v, Wanted %+v.", top.Hits, full.Hits[:1])
+	}
+	if top.TotalHits != full.TotalHits {
+		t.Errorf("Wrong total hits: Got %d, Wanted %d.", top.TotalHits, full.TotalHits)
+	}
+	if !reflect.DeepEqual(top.Hits[0].MatchedTerms, []string{"communic", "channel"}) {
+		t.Errorf("Wrong matched terms: Got %v.", top.Hits[0].MatchedTerms)
+	}
+}
@@ -1,165 +1,342 @@
 package main
 
 import (
+	"fmt"
+	"math"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
 // The Searcher type is an implementation of a search engine.
+// Documents can be added, updated and deleted while the Searcher is
+// serving queries through Query.
 type Searcher struct {
 	ii InvertedIndex
+	// fields maps each of the documentFields to an inverted index
+	// of only that field.
+	fields map[string]*InvertedIndex
+	// ki indexes the terms before stemming, as they are typed by users.
 	ki KGramIndex
+	// permuterm indexes the terms of ki for wildcard queries
+	// if the PermutermDictionary is selected, and is nil otherwise.
+	permuterm *PermutermIndex
+	// surfaceForms maps terms of the inverted index to the terms
+	// of the k-gram index that they were produced from.
+	surfaceForms map[string][]string
+	// completions completes queries with the terms of ki and the titles of documents.
+	completions CompletionTrie
 	docLen DocumentLengths
+	// docVectors stores the norms of the term vectors of documents for VectorSpaceQuery.
+	docVectors DocumentVectors
+	// docTerms stores the terms and surface forms of each document for removing it.
+	docTerms DocumentTerms
+	// fieldLen stores the number of tokens in each of the documentFields.
+	fieldLen map[string]*DocumentLengths
+	bm25f BM25FParams
+	ql QueryLikelihoodParams
+	smart SMARTWeighting
+	expansion ExpansionParams
+	analyzer Analyzer
 	storage DocumentStorage
+	mux sync.RWMutex
 }
 
 func NewSearcher(k int, storage DocumentStorage) *Searcher {
-	return &Searcher{ii: *NewInvertedIndex(), ki: *NewKGramIndex(k), docLen: DocumentLengths{}, storage:storage}
+	return &Searcher{
+		ii: *NewInvertedIndex(),
+		fields: newFieldIndices(VByteCodec),
+		ki: *NewKGramIndex(k),
+		surfaceForms: make(map[string][]string),
+		completions: *NewCompletionTrie(),
+		docLen: DocumentLengths{},
+		fieldLen: newFieldLengths(),
+		bm25f: DefaultBM25FParams,
+		ql: DefaultQueryLikelihoodParams,
+		smart: DefaultSMARTWeighting,
+		expansion: DefaultExpansionParams,
+		analyzer: DefaultAnalyzer,
+		storage:storage,
+	}
+}
+
+// SetAnalyzer sets the Analyzer used for documents and queries.
+// The indices are only correct for the analyzer they were built with, so
+// it should be set before the indices are built with BuildIndices or loaded.
+func (s *Searcher) SetAnalyzer(analyzer Analyzer) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.analyzer = analyzer
+}
+
+// currentAnalyzer returns the Analyzer. Requests read it once and pass it
+// down, so that the query and the snippets are analyzed alike even if it is set meanwhile.
+func (s *Searcher) currentAnalyzer() Analyzer {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.analyzer
+}
+
+// SetPostingsCodec sets the codec that compresses the postings lists of the
+// inverted indices. The indices that are already built are recompressed.
+func (s *Searcher) SetPostingsCodec(codec PostingsCodec) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.ii.setCodec(codec)
+	for _, index := range s.fields {
+		index.setCodec(codec)
+	}
+}
+
+// SetTermDictionary sets the index of terms that wildcard queries are
+// looked up in. The PermutermIndex is built from the terms of the k-gram index.
+func (s *Searcher) SetTermDictionary(dict TermDictionary) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if dict == PermutermDictionary {
+		s.permuterm = buildPermutermIndex(s.ki.Terms())
+	} else {
+		s.permuterm = nil
+	}
+}
+
+// terms returns the terms of the text using the analyzer of the Searcher.
+func (s *Searcher) terms(text string) []string {
+	return analyzeTerms(s.analyzer, text)
+}
+
+// surfaceTerms returns the terms of the text before stemming, which are the
+// tokens of the analyzer with only its character level normalization applied.
+func (s *Searcher) surfaceTerms(text string) (terms []string) {
+	for _, token := range s.analyzer.Analyze(text) {
+		terms = append(terms, s.analyzer.Normalize(text[token.Start:token.End]))
+	}
+	return
+}
+
+// buildSurfaceForms returns the surfaceForms of the terms in the k-gram index.
+func buildSurfaceForms(ki *KGramIndex, analyzer Analyzer) map[string][]string {
+	surfaceForms := make(map[string][]string)
+	for _, surface := range ki.Terms() {
+		for _, term := range analyzeTerms(analyzer, surface) {
+			surfaceForms[term] = append(surfaceForms[term], surface)
+		}
+	}
+	return surfaceForms
+}
+
+// newFieldLengths returns empty DocumentLengths for each of the documentFields.
+func newFieldLengths() map[string]*DocumentLengths {
+	fieldLen := make(map[string]*DocumentLengths)
+	for _, field := range documentFields {
+		fieldLen[field] = &DocumentLengths{}
+	}
+	return fieldLen
+}
+
+// newFieldIndices returns an empty inverted index for each of the documentFields.
+func newFieldIndices(codec PostingsCodec) map[string]*InvertedIndex {
+	fields := make(map[string]*InvertedIndex)
+	for _, field := range documentFields {
+		fields[field] = NewInvertedIndexWithCodec(codec)
+	}
+	return fields
+}
+
+// index returns the inverted index of the given field, or the index
+// of the Title and Body if field is empty.
+func (s *Searcher) index(field string) *InvertedIndex {
+	if field == "" {
+		return &s.ii
+	}
+	return s.fields[field]
+}
+
+// inverseDocumentFrequency returns log10(N / df) of a term that appears
+// in df of the N documents, or 0 if no document contains the term.
+func (s *Searcher) inverseDocumentFrequency(docFreq int) float64 {
+	N := s.docLen.documentCount()
+	if N == 0 || docFreq == 0 {
+		return 0
+	}
+	return math.Log10(float64(N) / float64(docFreq))
+}
+
+// lengths returns the lengths of the given field in documents, or the
+// lengths of the Body if field is empty, which normalize the weights of
+// the terms of the index of the field.
+func (s *Searcher) lengths(field string) *DocumentLengths {
+	if field == "" {
+		return &s.docLen
+	}
+	return s.fieldLen[field]
 }
 
 // queryFunc defines methods that take in a query string and
 // returns a list of document IDs that are relevant to the query.
 type queryFunc func(string) []int
 
+// scoringFunc defines methods that take in a query string and
+// returns the unsorted scores of documents that are relevant to the query.
+type scoringFunc func(string) *ScoringList
+
 // Query returns a list of documents that are relevant to the query,
 // where relevance is defined by the given queryFunc.
+// See Search for the scores and total number of hits.
 func (s *Searcher) Query(query string, fn queryFunc) []Document {
-	resultIDs := fn(query)
-	return s.storage.Get(resultIDs)
+	return s.storage.Get(hitIDs(s.Search(query, fn, nil, nil).Hits))
+}
+
+// QueryTopK returns the k documents that are the most relevant to the query,
+// where relevance is defined by the given topKFunc.
+// See SearchTopK for the scores and total number of hits.
+func (s *Searcher) QueryTopK(query string, k int, fn topKFunc) []Document {
+	return s.storage.Get(hitIDs(s.SearchTopK(query, k, fn, nil).Hits))
 }
 
 // Query Methods
 
 // TermsQuery returns documents that contain an exact match of
 // all of the words in the query.
+// Words can be restricted to a field, e.g. "title:kappa".
 func (s *Searcher) TermsQuery(query string) (results []int) {
-	results = s.ii.Intersect(tokenize(query))
-	return
+	clauses := parseClauses(query, s.analyzer)
+	if len(clauses) == 0 {
+		return
+	}
+	// The clauses with the fewest documents lead so that the others skip the most.
+	sort.SliceStable(clauses, func(i, j int) bool { return s.clauseFrequency(clauses[i]) < s.clauseFrequency(clauses[j]) })
+	its := make([]DocIterator, len(clauses))
+	for i, clause := range clauses {
+		its[i] = s.clauseIterator(clause)
+	}
+	return collectDocs(newConjunctionIterator(its))
 }
 
-// BooleanQuery returns documents based on the boolean retrieval model.
-// Only supports AND (&&) and OR (||).
-func (s *Searcher) BooleanQuery(query string) (results []int) {
-	// Allows boolean operations between terms. Terms should only consist of a single word.
-	var queryTerms []string
-	intersectFlag := strings.Contains(query, "&&")
-	unionFlag := strings.Contains(query, "||")
-	// If the query does not contain both && or ||, we do not need to create postfix tree.
-	if intersectFlag && unionFlag {
-		queryTerms = shuntingYard(parseInfix(query))
-		var stack [][]int
-		for i := 0; i < len(queryTerms); i++ {
-			if queryTerms[i] == "&&" {
-				if len(stack) >= 2 {
-					stack[len(stack)-2] = IntersectPosting(stack[len(stack)-1], stack[len(stack)-2])
-					stack = stack[:len(stack)-1]
-				} else {
-					stack = [][]int{}
-					break
-				}
-			} else if queryTerms[i] == "||" {
-				if len(stack) >= 2 {
-					stack[len(stack)-2] = UnionPosting(stack[len(stack)-1], stack[len(stack)-2])
-					stack = stack[:len(stack)-1]
-				} else {
-					stack = [][]int{}
-					break
-				}
-			}  else {
-				stack = append(stack, s.ii.PostingsList(queryTerms[i]))
-			}
-		}
-		if len(stack) == 1 {
-			results = stack[0]
-		}
-	} else if unionFlag {
-		results = s.ii.Union(splitTrimToLower(query, "||"))
-	} else {
-		results = s.ii.Intersect(splitTrimToLower(query, "&&"))
+// clauseIterator returns an iterator over the documents that match the clause.
+func (s *Searcher) clauseIterator(clause queryClause) DocIterator {
+	if len(clause.tokens) == 1 {
+		return s.index(clause.field).Iterator(clause.tokens[0])
 	}
-	return
+	return s.index(clause.field).PhraseIterator(clause.tokens)
 }
 
-// parseInfix parses the expression and splits it into a set of tokens,
-// where each token is either a word or an operator.
-func parseInfix(expr string) (output []string) {
-	for _, i := range splitTrimToLower(expr, "&&") {
-		for _, j := range splitTrimToLower(i, "||") {
-			output = append(output, j, "||")
-		}
-		if len(output) > 0 {
-			output[len(output) - 1] = "&&"
-		}
+// clauseFrequency returns the number of documents that contain the rarest
+// token of the clause, which is at least the number that match the clause.
+func (s *Searcher) clauseFrequency(clause queryClause) (freq int) {
+	freq = maxInt
+	for _, token := range clause.tokens {
+		freq = min(freq, s.index(clause.field).DocumentFrequency(token))
 	}
-	output = output[:len(output) - 1]
 	return
 }
 
-// shuntingYard converts an expression given in infix notation to a
-// postfix "Reverse Polish" notation.
-// This is a partial implementation of Shunting-yard algorithm,
-// which only considers left associative, binary operators.
-func shuntingYard(tokens []string) (output []string) {
-	// orderOfOperations is a map containing operations used in our BooleanQuery.
-	// Larger value implies higher precedence; Values are arbitrary.
-	orderOfOperations := map[string]int{"||": 1, "&&": 2}
-	var operatorStack []string
-	for _, token := range tokens {
-		if _, ok := orderOfOperations[token]; !ok {
-			output = append(output, token)
-		} else {
-			for i := len(operatorStack) - 1; i>= 0; i-- {
-				// If equal, assumes token is left associative.
-				if orderOfOperations[operatorStack[i]] >= orderOfOperations[token] {
-					output = append(output, operatorStack[i])
-					operatorStack = operatorStack[:i]
-				} else {
-					break
-				}
+// PhraseQuery returns documents that contain the words in the query
+// as an exact phrase.
+func (s *Searcher) PhraseQuery(query string) (results []int) {
+	results, _ = s.ii.PhrasePostings(s.terms(query))
+	return
+}
+
+// ProximityQuery returns documents that contain all of the words in the query,
+// where words joined by "NEAR/k" appear within k words of each other and
+// words joined by "BEFORE/k" also appear in that order.
+// For example "code NEAR/3 access" matches "access method code".
+func (s *Searcher) ProximityQuery(query string) (results []int) {
+	var terms []string
+	var constraints [][]int
+	fields := strings.Fields(query)
+	for i, field := range fields {
+		if op, k, ok := parseProximityOperator(field); ok {
+			// Operators join the adjacent words, dangling operators are ignored.
+			if len(terms) == 0 || i == len(fields) - 1 {
+				continue
 			}
-			operatorStack = append(operatorStack, token)
+			next := s.terms(fields[i+1])
+			if len(next) > 0 && !isProximityOperator(fields[i+1]) {
+				constraints = append(constraints, s.ii.ProximityPostings(terms[len(terms)-1], next[0], k, op == "BEFORE"))
+			}
+			continue
 		}
+		terms = append(terms, s.terms(field)...)
+	}
+	if len(terms) == 0 {
+		return
 	}
-	for i := len(operatorStack) - 1; i>= 0; i-- {
-		output = append(output, operatorStack[i])
+	results = s.ii.Intersect(terms)
+	for _, plist := range constraints {
+		results = IntersectPosting(results, plist)
 	}
 	return
 }
 
-// splitTrimToLower splits the input string with the provided token,
-// trims whitespace and converts the output tokens to lowercase.
-func splitTrimToLower(str string, split string) (out []string) {
-	out = strings.Split(str, split)
-	for i := range out {
-		out[i] = strings.TrimSpace(strings.ToLower(out[i]))
+// maxProximityDistance is the largest k of NEAR/k and BEFORE/k, which is
+// below fieldPositionGap so that they cannot match across the Title and Body.
+const maxProximityDistance = fieldPositionGap - 1
+
+// parseProximityOperator parses operators of the form "NEAR/k" and "BEFORE/k".
+// Larger distances than maxProximityDistance are capped to it.
+func parseProximityOperator(str string) (op string, k int, ok bool) {
+	parts := strings.SplitN(str, "/", 2)
+	if len(parts) != 2 || (parts[0] != "NEAR" && parts[0] != "BEFORE") {
+		return
 	}
-	return
+	k, err := strconv.Atoi(parts[1])
+	if err != nil || k < 1 {
+		return
+	}
+	return parts[0], min(k, maxProximityDistance), true
+}
+
+// isProximityOperator checks if the string is a proximity operator.
+func isProximityOperator(str string) bool {
+	_, _, ok := parseProximityOperator(str)
+	return ok
+}
+
+// BooleanQuery returns documents based on the boolean retrieval model.
+// Supports AND (&&), OR (||), NOT (!), parentheses, quoted phrases and
+// the proximity operators NEAR/k and BEFORE/k. See parseBooleanQuery for
+// the syntax. Malformed queries return no documents.
+func (s *Searcher) BooleanQuery(query string) (results []int) {
+	node, err := parseBooleanQuery(query, s.analyzer)
+	if err != nil {
+		return
+	}
+	return collectDocs(node.iterator(s))
 }
 
-// FuzzyQuery returns documents that contain all of the provided terms.
+// FuzzyQuery returns a ranked list of documents that contain all of the provided terms.
 // Each term also accepts other terms that are within a certain edit distance.
 // For example "Fizzy" will match the query "Fuzzy".
+// Documents are ranked by the weights of the terms, where terms that are
+// closer to the query terms weigh more (see expandedScores).
 func (s *Searcher) FuzzyQuery(query string) (results []int) {
-	for _, queryTerm := range tokenize(query) {
-		fuzziness := getFuzziness(queryTerm)
-		terms := s.ki.GetCloseTerms(queryTerm, fuzziness)
-
-		if len(results) == 0 {
-			results = s.ii.Union(terms)
-		} else {
-			results = IntersectPosting(results, s.ii.Union(terms))
-		}
-	}
+	resList := s.fuzzyScores(query)
+	sort.Sort(resList)
+	results = resList.ids
 	return
 }
 
+// fuzzyScores returns the unsorted scores of FuzzyQuery.
+func (s *Searcher) fuzzyScores(query string) *ScoringList {
+	return s.expandedScores(s.fuzzyExpansions(query))
+}
+
+// explainFuzzy explains the score of a document in FuzzyQuery.
+func (s *Searcher) explainFuzzy(query string, docID int) *Explanation {
+	return s.explainExpanded(s.fuzzyExpansions(query), docID)
+}
+
 // getFuzziness determines the edit distance for each term
 // based on its length.
 // Longer words are allowed more spelling mistakes.
 func getFuzziness(str string) (fuzziness int) {
-	if len(str) <= 2 {
+	if length := utf8.RuneCountInString(str); length <= 2 {
 		fuzziness = 0
-	} else if len(str) <= 5 {
+	} else if length <= 5 {
 		fuzziness = 1
 	} else {
 		fuzziness = 2
@@ -167,23 +344,33 @@ func getFuzziness(str string) (fuzziness int) {
 	return
 }
 
-// WildcardQuery returns documents that contain all of the provided terms.
+// WildcardQuery returns a ranked list of documents that contain all of the provided terms.
 // Terms can contain the characters '?' which represents a single character,
 // and '*' which can be expanded into one or more characters.
-func (s Searcher) WildcardQuery(query string) (results []int) {
-	for _, queryTerm := range tokenizeWildcard(query) {
-		terms := s.ki.KGramMatch(queryTerm)
-		var partialResult  []string
-		for _, res := range terms {
-			if wildcardMatch(queryTerm, res) {
-				partialResult = append(partialResult, res)
-			}
-		}
-		if len(results) == 0 {
-			results = s.ii.Union(partialResult)
-		} else {
-			results = IntersectPosting(results, s.ii.Union(partialResult))
-		}
+// Documents are ranked by the weights of the matching terms, where terms
+// with fewer characters matched by '*' weigh more (see expandedScores).
+func (s *Searcher) WildcardQuery(query string) (results []int) {
+	resList := s.wildcardScores(query)
+	sort.Sort(resList)
+	results = resList.ids
+	return
+}
+
+// wildcardScores returns the unsorted scores of WildcardQuery.
+func (s *Searcher) wildcardScores(query string) *ScoringList {
+	return s.expandedScores(s.wildcardExpansions(query))
+}
+
+// explainWildcard explains the score of a document in WildcardQuery.
+func (s *Searcher) explainWildcard(query string, docID int) *Explanation {
+	return s.explainExpanded(s.wildcardExpansions(query), docID)
+}
+
+// wildcardPatterns splits a wildcard query into patterns,
+// normalized like the terms of the analyzer.
+func (s *Searcher) wildcardPatterns(query string) (patterns []string) {
+	for _, token := range wildcardTokenizer.Tokenize(query) {
+		patterns = append(patterns, s.analyzer.Normalize(token.Text))
 	}
 	return
 }
@@ -193,94 +380,546 @@ func (s Searcher) WildcardQuery(query string) (results []int) {
 type ScoringList struct {
 	ids []int
 	scores []float64
+	// index maps a document ID to its index in ids.
+	index map[int]int
 }
 
 func (r ScoringList) Len() int { return len(r.ids) }
 func (r ScoringList) Swap(i, j int) {
 	r.scores[i], r.scores[j] = r.scores[j], r.scores[i]
 	r.ids[i], r.ids[j] = r.ids[j], r.ids[i]
+	if r.index != nil {
+		r.index[r.ids[i]], r.index[r.ids[j]] = i, j
+	}
+}
+func (r ScoringList) Less(i, j int) bool {
+	// Ties are broken by document ID so that the order is deterministic.
+	return r.scores[i] > r.scores[j] || (r.scores[i] == r.scores[j] && r.ids[i] < r.ids[j])
 }
-func (r ScoringList) Less(i, j int) bool { return r.scores[i] > r.scores[j] }
 
-// VectorSpaceQuery returns a ranked list of results sorted by
-// cosine similarity using the vector space model.
-// Scores are calculated using tf-idf and document length normalization.
-func (s Searcher) VectorSpaceQuery(query string) (results []int) {
-	resList := &ScoringList{}
-	for _, queryTerm := range tokenize(query) {
-		for _, docID := range s.ii.PostingsList(queryTerm) {
-			resultsIndex := findIndexInArray(resList.ids, docID)
-			// Calculate tf-idf score
-			score := float64(s.ii.TermFrequency(queryTerm, docID)) * s.ii.InverseDocumentFrequency(queryTerm)
-			if resultsIndex == -1 {
-				// Document ID not yet in results.
-				resList.ids = append(resList.ids, docID)
-				resList.scores = append(resList.scores, score)
-			} else {
-				resList.scores[resultsIndex] += score
-			}
-		}
+// add adds the score to the document, which is added to the list
+// if it is not yet in the list.
+func (r *ScoringList) add(docID int, score float64) {
+	if r.index == nil {
+		r.index = make(map[int]int)
 	}
-	for i := range resList.ids {
-		resList.scores[i] /= float64(s.docLen.docLength(resList.ids[i]))
+	resultsIndex, ok := r.index[docID]
+	if !ok {
+		// Document ID not yet in results.
+		r.index[docID] = len(r.ids)
+		r.ids = append(r.ids, docID)
+		r.scores = append(r.scores, score)
+	} else {
+		r.scores[resultsIndex] += score
 	}
+}
+
+// VectorSpaceQuery returns a ranked list of results sorted by the similarity
+// of the query and documents in the vector space model, with the terms weighted
+// by the SMART weighting of the Searcher, which is cosine similarity by default.
+func (s *Searcher) VectorSpaceQuery(query string) (results []int) {
+	resList := s.vectorSpaceScores(query)
 	sort.Sort(resList)
 	results = resList.ids
 	return
 }
 
-// findIndexInArray returns the index of the given int
-// if it exists in the array, else returns -1.
-func findIndexInArray(arr []int, value int) int {
-	for i, v := range arr {
-		if v == value {
-			return i
-		}
-	}
-	return -1
+// vectorSpaceScores returns the unsorted scores of VectorSpaceQuery.
+func (s *Searcher) vectorSpaceScores(query string) (resList *ScoringList) {
+	return s.smartScores(query, s.smart)
 }
 
 // BM25Query returns a ranked list of results scored by the Okapi BM25 algorithm.
 // Scores are calculated using tf-idf and document length normalization.
-func (s Searcher) BM25Query(query string) (results []int) {
-	k1 := 0.9
-	b := 0.4
-	resList := &ScoringList{}
-	for _, queryTerm := range tokenize(query) {
-		for _, docID := range s.ii.PostingsList(queryTerm) {
-			resultsIndex := findIndexInArray(resList.ids, docID)
-			// Calculate BM25 score
-			tf := float64(s.ii.TermFrequency(queryTerm, docID))
-			idf := s.ii.InverseDocumentFrequency(queryTerm)
-			score :=  idf * (k1 + 1) * tf / (k1 * ((1 - b) + b * (float64(s.docLen.docLength(docID)) / s.docLen.averageDocumentLength())) + tf)
-			if resultsIndex == -1 {
-				// Document ID not yet in results.
-				resList.ids = append(resList.ids, docID)
-				resList.scores = append(resList.scores, score)
-			} else {
-				resList.scores[resultsIndex] += score
+// Quoted phrases and words restricted to a field, e.g. "title:kappa", are supported.
+func (s *Searcher) BM25Query(query string) (results []int) {
+	resList := s.bm25Scores(query)
+	sort.Sort(resList)
+	results = resList.ids
+	return
+}
+
+// proximityWindow is the largest distance between two query terms that
+// contributes to the proximity score in BM25ProximityQuery.
+const proximityWindow = 5
+
+// BM25ProximityQuery returns a ranked list of results scored by BM25,
+// boosting documents where query terms appear close together.
+// Each pair of query terms adds the BM25 score of a pseudo term with frequency
+// equal to the sum of 1/d^2 over all occurrences of the pair that are at
+// most proximityWindow words apart, where d is their distance.
+// (Reference) Rasolofo, Y., & Savoy, J. (2003). Term proximity scoring for keyword-based retrieval systems.
+func (s *Searcher) BM25ProximityQuery(query string) (results []int) {
+	resList := s.bm25ProximityScores(query)
+	sort.Sort(resList)
+	results = resList.ids
+	return
+}
+
+// bm25ProximityScores returns the unsorted scores of BM25ProximityQuery.
+func (s *Searcher) bm25ProximityScores(query string) (resList *ScoringList) {
+	resList = s.bm25Scores(query)
+	terms := s.proximityTerms(query)
+	for i := range terms {
+		for j := i + 1; j < len(terms); j++ {
+			docFreq := max(s.ii.DocumentFrequency(terms[i]), s.ii.DocumentFrequency(terms[j]))
+			it1, it2 := s.ii.Iterator(terms[i]), s.ii.Iterator(terms[j])
+			for it := newConjunctionIterator([]DocIterator{it1, it2}); it.Next(); {
+				acc := proximityAccumulator(it1.Positions(), it2.Positions(), proximityWindow)
+				if acc > 0 {
+					resList.add(it.DocID(), DefaultBM25Weighter.Weight(s.proximityStats(acc, docFreq, it.DocID())))
+				}
 			}
 		}
 	}
+	return
+}
+
+// explainBM25Proximity explains the score of a document in BM25ProximityQuery
+// as its BM25 score and the scores of the pairs of query terms in it.
+func (s *Searcher) explainBM25Proximity(query string, docID int) *Explanation {
+	var details []Explanation
+	if bm25 := s.explainWeighted(query, docID, DefaultBM25Weighter); bm25 != nil {
+		details = append(details, *bm25)
+	}
+	terms := s.proximityTerms(query)
+	for i := range terms {
+		for j := i + 1; j < len(terms); j++ {
+			acc := proximityAccumulator(s.ii.Positions(terms[i], docID), s.ii.Positions(terms[j], docID), proximityWindow)
+			if acc == 0 {
+				continue
+			}
+			docFreq := max(s.ii.DocumentFrequency(terms[i]), s.ii.DocumentFrequency(terms[j]))
+			details = append(details, explainProximity(terms[i], terms[j], s.proximityStats(acc, docFreq, docID)))
+		}
+	}
+	if len(details) == 1 {
+		// Only the BM25 score, which is already a sum.
+		return &details[0]
+	}
+	return explainSum(details)
+}
+
+// proximityTerms returns the unique query terms that are paired in
+// BM25ProximityQuery, which are the single words not restricted to a field.
+func (s *Searcher) proximityTerms(query string) []string {
+	var terms []string
+	for _, clause := range parseClauses(query, s.analyzer) {
+		if clause.field == "" && len(clause.tokens) == 1 {
+			terms = append(terms, clause.tokens[0])
+		}
+	}
+	return uniqueStrings(terms)
+}
+
+// proximityStats returns the TermStats of the pseudo term of a pair of query
+// terms in a document, whose frequency is the proximity accumulator of the
+// pair and whose document frequency is the larger one of the two terms.
+func (s *Searcher) proximityStats(acc float64, docFreq int, docID int) TermStats {
+	return TermStats{
+		Freq: acc,
+		DocLength: float64(s.docLen.docLength(docID)),
+		AvgDocLength: s.docLen.averageDocumentLength(),
+		DocFreq: docFreq,
+		IDF: s.inverseDocumentFrequency(docFreq),
+		DocCount: s.docLen.documentCount(),
+	}
+}
+
+// explainProximity explains the BM25 weight of the pseudo term of a pair of query terms.
+func explainProximity(term1, term2 string, stats TermStats) Explanation {
+	w := DefaultBM25Weighter
+	return Explanation{
+		Value: w.Weight(stats),
+		Description: fmt.Sprintf("proximity of %q and %q, computed as idf * (k1 + 1) * tf / (k1 * norm + tf) from:", term1, term2),
+		Details: []Explanation{
+			{
+				Value: stats.IDF,
+				Description: "idf, log10(N / df), lower inverse document frequency of the pair",
+				Details: []Explanation{
+					explainValue(float64(stats.DocCount), "N, number of documents"),
+					explainValue(float64(stats.DocFreq), "df, larger number of documents with either term"),
+				},
+			},
+			explainValue(stats.Freq, fmt.Sprintf("tf, sum of 1/d^2 of the occurrences at most %d words apart", proximityWindow)),
+			explainValue(w.K1, "k1"), explainLengthNorm(w.B, stats),
+		},
+	}
+}
+
+// bm25Scores returns the unsorted BM25 scores of documents
+// that contain at least one query term.
+// Quoted phrases are scored as a single term and words restricted to
+// a field are scored with the index of that field.
+func (s *Searcher) bm25Scores(query string) (resList *ScoringList) {
+	return s.weightedScores(query, DefaultBM25Weighter)
+}
+
+// bm25 returns the BM25 score of a term in a document given its
+// term frequency, inverse document frequency and the lengths of its field.
+func (s *Searcher) bm25(tf float64, idf float64, lengths *DocumentLengths, docID int) float64 {
+	return DefaultBM25Weighter.Weight(TermStats{
+		Freq: tf,
+		DocLength: float64(lengths.docLength(docID)),
+		AvgDocLength: lengths.averageDocumentLength(),
+		IDF: idf,
+	})
+}
+
+// The parameters k1 and b of BM25.
+const (
+	bm25K1 = 0.9
+	bm25B = 0.4
+)
+
+// BM25FParams are the parameters of BM25FQuery.
+type BM25FParams struct {
+	K1 float64
+	// Fields maps the fields that unrestricted query terms are searched in
+	// to their weight and length normalization.
+	Fields map[string]BM25FField
+}
+
+// BM25FField is the weight (Boost) and length normalization (B) of a field in BM25F.
+type BM25FField struct {
+	Boost float64
+	B     float64
+}
+
+// DefaultBM25FParams weighs a term in the Title three times as much as
+// a term in the Body, and normalizes the (short) Title less.
+var DefaultBM25FParams = BM25FParams{
+	K1: 1.2,
+	Fields: map[string]BM25FField{
+		"title": {Boost: 3, B: 0.5},
+		"body":  {Boost: 1, B: 0.75},
+	},
+}
+
+// SetBM25FParams sets the parameters used by BM25FQuery.
+func (s *Searcher) SetBM25FParams(params BM25FParams) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.bm25f = params
+}
+
+// BM25FQuery returns a ranked list of results scored by BM25F, which
+// combines the term frequencies of each field, weighted by the boost of
+// the field and normalized by the length of the field, before saturation.
+// Terms restricted to a field, e.g. "title:kappa", are only searched in that field.
+// (Reference) Robertson, S., Zaragoza, H., & Taylor, M. (2004). Simple BM25 extension to multiple weighted fields.
+func (s *Searcher) BM25FQuery(query string) (results []int) {
+	resList := s.bm25fScores(query)
 	sort.Sort(resList)
 	results = resList.ids
 	return
 }
 
+// bm25fScores returns the unsorted scores of BM25FQuery.
+func (s *Searcher) bm25fScores(query string) (resList *ScoringList) {
+	resList = &ScoringList{}
+	for _, clause := range parseClauses(query, s.analyzer) {
+		fields, fieldParams := s.bm25fFields(clause)
+		// Weighted and normalized term frequency of each document.
+		tf := make(map[int]float64)
+		for _, field := range fields {
+			docIDs, freqs := s.fields[field].PhrasePostings(clause.tokens)
+			for i, docID := range docIDs {
+				tf[docID] += s.bm25fFieldFreq(field, fieldParams[field], freqs[i], docID)
+			}
+		}
+		idf := s.bm25fIDF(len(tf))
+		for docID, freq := range tf {
+			resList.add(docID, s.bm25fWeight(idf, freq))
+		}
+	}
+	return
+}
+
+// bm25fFields returns the fields that a clause of BM25FQuery is searched in,
+// with their parameters. The fields are sorted, so that the frequencies of
+// the fields are summed in the same order by every query and explanation.
+func (s *Searcher) bm25fFields(clause queryClause) (fields []string, fieldParams map[string]BM25FField) {
+	fieldParams = s.bm25f.Fields
+	if clause.field != "" {
+		params, ok := fieldParams[clause.field]
+		if !ok {
+			params = BM25FField{Boost: 1}
+		}
+		fieldParams = map[string]BM25FField{clause.field: params}
+	}
+	for field := range fieldParams {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return
+}
+
+// bm25fFieldFreq returns the boosted and length normalized frequency
+// of a term in a field of a document.
+func (s *Searcher) bm25fFieldFreq(field string, params BM25FField, freq int, docID int) float64 {
+	return params.Boost * float64(freq) / s.fieldLengthNorm(field, params.B, docID)
+}
+
+// bm25fIDF returns the inverse document frequency of a term
+// that is in a field of docFreq documents.
+func (s *Searcher) bm25fIDF(docFreq int) float64 {
+	N := float64(s.docLen.documentCount())
+	return math.Log(1 + (N - float64(docFreq) + 0.5) / (float64(docFreq) + 0.5))
+}
+
+// bm25fWeight returns the weight of a term with the sum tf of its
+// frequencies in the fields of a document.
+func (s *Searcher) bm25fWeight(idf float64, tf float64) float64 {
+	return idf * (s.bm25f.K1 + 1) * tf / (s.bm25f.K1 + tf)
+}
+
+// explainBM25F explains the score of a document in BM25FQuery as the sum
+// of the weights of the clauses, with the term frequency of each field.
+func (s *Searcher) explainBM25F(query string, docID int) *Explanation {
+	var details []Explanation
+	for _, clause := range parseClauses(query, s.analyzer) {
+		fields, fieldParams := s.bm25fFields(clause)
+		docs := make(map[int]bool)
+		tf := Explanation{Description: "tf, sum of boost * tf / norm of the fields, computed from:"}
+		for _, field := range fields {
+			docIDs, freqs := s.fields[field].PhrasePostings(clause.tokens)
+			for _, id := range docIDs {
+				docs[id] = true
+			}
+			i := sort.SearchInts(docIDs, docID)
+			if i == len(docIDs) || docIDs[i] != docID {
+				continue
+			}
+			fieldFreq := s.explainBM25FFieldFreq(field, fieldParams[field], freqs[i], docID)
+			tf.Value += fieldFreq.Value
+			tf.Details = append(tf.Details, fieldFreq)
+		}
+		if len(tf.Details) == 0 {
+			continue
+		}
+		idf := s.bm25fIDF(len(docs))
+		details = append(details, Explanation{
+			Value: s.bm25fWeight(idf, tf.Value),
+			Description: "weight of " + clauseString(clause) + ", computed as idf * (k1 + 1) * tf / (k1 + tf) from:",
+			Details: []Explanation{
+				{
+					Value: idf,
+					Description: "idf, ln(1 + (N - df + 0.5) / (df + 0.5)), inverse document frequency",
+					Details: []Explanation{
+						explainValue(float64(s.docLen.documentCount()), "N, number of documents"),
+						explainValue(float64(len(docs)), "df, number of documents with the term in a field"),
+					},
+				},
+				tf,
+				explainValue(s.bm25f.K1, "k1"),
+			},
+		})
+	}
+	return explainSum(details)
+}
+
+// explainBM25FFieldFreq explains the frequency of a term in a field of a document.
+func (s *Searcher) explainBM25FFieldFreq(field string, params BM25FField, freq int, docID int) Explanation {
+	fieldLen := s.fieldLen[field]
+	return Explanation{
+		Value: s.bm25fFieldFreq(field, params, freq, docID),
+		Description: fmt.Sprintf("%s, boost * tf / norm", field),
+		Details: []Explanation{
+			explainValue(params.Boost, "boost"),
+			explainValue(float64(freq), "tf, frequency of the term in the field"),
+			{
+				Value: s.fieldLengthNorm(field, params.B, docID),
+				Description: "norm, (1 - b) + b * dl / avgdl, length normalization",
+				Details: []Explanation{
+					explainValue(params.B, "b"),
+					explainValue(float64(fieldLen.docLength(docID)), "dl, length of the field"),
+					explainValue(fieldLen.averageDocumentLength(), "avgdl, average length of the field"),
+				},
+			},
+		},
+	}
+}
+
+// fieldLengthNorm returns the length normalization of a field in a document.
+func (s *Searcher) fieldLengthNorm(field string, b float64, docID int) float64 {
+	fieldLen := s.fieldLen[field]
+	avgLen := fieldLen.averageDocumentLength()
+	if avgLen == 0 {
+		return 1
+	}
+	return (1 - b) + b * float64(fieldLen.docLength(docID)) / avgLen
+}
+
 // BuildIndices builds the inverted index and k-gram index
 // from the document storage.
 func (s *Searcher) BuildIndices() {
-	s.storage.Apply(func(doc Document) {
-		// Only take word count of Body.
-		s.docLen.addDocumentLength(doc.Body)
-		// Adds words in Title and Body to index.
-		for _, token := range tokenize(doc.Title) {
-			s.ii.addIDToPostingsList(token, doc.id)
-			s.ki.addWordToPostingsList(token)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.storage.Apply(s.indexDocument)
+	s.flushIndices()
+}
+
+// AddDocument adds a new document to the indices.
+// The document must be saved in the DocumentStorage first, so that
+// the results of queries show the document that was indexed.
+func (s *Searcher) AddDocument(doc Document) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.docLen.hasDocument(doc.id) {
+		return fmt.Errorf("document %d is already indexed", doc.id)
+	}
+	if err := s.checkStored(doc); err != nil {
+		return err
+	}
+	s.indexDocument(doc)
+	s.flushIndices()
+	return nil
+}
+
+// UpdateDocument replaces the indexed contents of an existing document
+// with the given document, which is matched by its ID. The document must
+// be replaced in the DocumentStorage first, as in AddDocument.
+func (s *Searcher) UpdateDocument(doc Document) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if !s.docLen.hasDocument(doc.id) {
+		return fmt.Errorf("document %d is not indexed", doc.id)
+	}
+	if err := s.checkStored(doc); err != nil {
+		return err
+	}
+	s.removeDocument(doc.id)
+	s.indexDocument(doc)
+	s.flushIndices()
+	return nil
+}
+
+// DeleteDocument removes the document with the given ID from the indices.
+// The document is no longer a result of queries, so it can be removed
+// from the DocumentStorage before or after.
+func (s *Searcher) DeleteDocument(docID int) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if !s.docLen.hasDocument(docID) {
+		return fmt.Errorf("document %d is not indexed", docID)
+	}
+	s.removeDocument(docID)
+	s.flushIndices()
+	return nil
+}
+
+// checkStored returns an error unless the DocumentStorage returns
+// the same document as the one that is about to be indexed.
+func (s *Searcher) checkStored(doc Document) error {
+	if s.storage == nil {
+		return fmt.Errorf("document %d is not in the storage", doc.id)
+	}
+	stored := s.storage.Get([]int{doc.id})[0]
+	if stored.id != doc.id {
+		return fmt.Errorf("document %d is not in the storage", doc.id)
+	}
+	if stored != doc {
+		return fmt.Errorf("document %d differs from the one in the storage", doc.id)
+	}
+	return nil
+}
+
+// fieldPositionGap separates the positions of tokens in the Title and Body,
+// so that phrases cannot match across the two.
+const fieldPositionGap = 100
+
+// indexDocument adds the words in the Title and Body of a document to the indices,
+// and the words of each field to the index of that field.
+func (s *Searcher) indexDocument(doc Document) {
+	tokens := make(map[string][]Token)
+	for _, field := range documentFields {
+		tokens[field] = s.analyzer.Analyze(doc.field(field))
+		s.fieldLen[field].setDocumentLength(doc.id, len(tokens[field]))
+		for pos, token := range tokens[field] {
+			s.fields[field].addIDToPostingsList(token.Text, doc.id, pos)
 		}
-		for _, token := range tokenize(doc.Body) {
-			s.ii.addIDToPostingsList(token, doc.id)
-			s.ki.addWordToPostingsList(token)
+	}
+	// Only take word count of Body.
+	s.docLen.setDocumentLength(doc.id, len(tokens["body"]))
+	var terms []string
+	for _, token := range append(tokens["title"], tokens["body"]...) {
+		terms = append(terms, token.Text)
+	}
+	s.docVectors.setDocumentVector(doc.id, termFreqs(terms))
+	s.completions.addTitle(doc.id, s.analyzer.Normalize(doc.Title), doc.Title)
+	// Adds words in Title and Body to index.
+	for pos, token := range tokens["title"] {
+		s.ii.addIDToPostingsList(token.Text, doc.id, pos)
+	}
+	offset := len(tokens["title"]) + fieldPositionGap
+	for pos, token := range tokens["body"] {
+		s.ii.addIDToPostingsList(token.Text, doc.id, offset + pos)
+	}
+	for _, form := range s.docTerms.setDocumentTerms(doc.id, newDocumentTerms(tokens, doc, s.analyzer.Normalize)) {
+		s.addSurfaceForm(form)
+	}
+}
+
+// flushIndices compresses the postings that were added to the inverted indices
+// and merges the terms that were added to or removed from the permuterm index.
+func (s *Searcher) flushIndices() {
+	s.ii.flush()
+	for _, index := range s.fields {
+		index.flush()
+	}
+	if s.permuterm != nil {
+		s.permuterm.flush()
+	}
+}
+
+// addSurfaceForm adds a term before stemming, which no other document
+// contains, to the k-gram index.
+func (s *Searcher) addSurfaceForm(form surfaceForm) {
+	s.ki.addWordToPostingsList(form.surface)
+	if s.permuterm != nil {
+		s.permuterm.addTerm(form.surface)
+	}
+	s.completions.addTerm(form.surface, form.term)
+	s.surfaceForms[form.term] = append(s.surfaceForms[form.term], form.surface)
+}
+
+// removeSurfaceForm removes a term before stemming, which no
+// document contains anymore, from the k-gram index.
+func (s *Searcher) removeSurfaceForm(form surfaceForm) {
+	s.ki.removeWordFromPostingsList(form.surface)
+	if s.permuterm != nil {
+		s.permuterm.removeTerm(form.surface)
+	}
+	s.completions.removeTerm(form.surface)
+	surfaces := s.surfaceForms[form.term][:0]
+	for _, surface := range s.surfaceForms[form.term] {
+		if surface != form.surface {
+			surfaces = append(surfaces, surface)
 		}
-	})
-}
\ No newline at end of file
+	}
+	if len(surfaces) == 0 {
+		delete(s.surfaceForms, form.term)
+	} else {
+		s.surfaceForms[form.term] = surfaces
+	}
+}
+
+// removeDocument removes a document from the postings lists of its terms. The
+// surface forms that no longer appear in any document are removed from the k-gram index.
+func (s *Searcher) removeDocument(docID int) {
+	s.docLen.removeDocumentLength(docID)
+	s.docVectors.removeDocumentVector(docID)
+	if title, ok := s.completions.titles[docID]; ok {
+		s.completions.removeTitle(docID, s.analyzer.Normalize(title))
+	}
+	terms, removed := s.docTerms.removeDocumentTerms(docID)
+	for field, index := range s.fields {
+		index.removeID(docID, terms.fields[field])
+		s.fieldLen[field].removeDocumentLength(docID)
+	}
+	s.ii.removeID(docID, terms.indexTerms())
+	for _, form := range removed {
+		s.removeSurfaceForm(form)
+	}
+}
@@ -1,103 +1,101 @@
 package main
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 )
 
-func TestParseInfix(t *testing.T) {
-	pairs := []struct{
-		expr string
-		token []string
-	}{
-		{"A || B", []string{"a", "||", "b"}},
-		{"A&&B", []string{"a", "&&", "b"}},
-		{"A&& B &&C||D", []string{"a", "&&", "b", "&&", "c", "||", "d"}},
-		{"A&&B&&C&&D", []string{"a", "&&", "b", "&&", "c", "&&", "d"}},
-		{"A||B|| C ||D", []string{"a", "||", "b", "||", "c", "||", "d"}},
-		{" A &&&&C|| D ", []string{"a", "&&", "", "&&", "c", "||", "d"}},
-		{"A||B&&||", []string{"a", "||", "b", "&&", "", "||", ""}},
-
-	}
-	for _, pair := range pairs {
-		tok := parseInfix(pair.expr)
-		if len(tok) == len(pair.token) {
-			for i := range tok {
-				if tok[i] != pair.token[i] {
-					t.Errorf("Wrong token: Got %v, Wanted %v.", tok, pair.token)
-				}
-			}
-		} else {
-			t.Errorf("Different number of token: Got %v, Wanted %v.", tok, pair.token)
-		}
-	}
+func SetUpSearcher() (s *Searcher) {
+	s = NewSearcher(3, NewCSVStorage("example.csv"))
+	s.BuildIndices()
+	return
 }
 
-func TestShuntingYard(t *testing.T) {
+func TestSearcher_TermQuery(t *testing.T) {
 	pairs := []struct{
-		token []string
-		output []string
+		query string
+		results []int
 	}{
-		{[]string{"A", "||", "B"}, []string{"A", "B", "||"}},
-		{[]string{"A", "||", "B", "&&", "C"}, []string{"A", "B", "C", "&&", "||"}},
-		{[]string{"A", "||", "B", "&&", "C", "||", "D"}, []string{"A", "B", "C", "&&", "||", "D", "||"}},
-		{[]string{"A", "||", "B", "&&", "", "&&", "C"}, []string{"A", "B", "", "&&", "C", "&&", "||"}},
-
+		{"is a statistic", []int{1}},
+		{"language", []int{2}},
+		{"is", []int{1, 2, 3}},
+		{"title:kappa", []int{1}},
+		{"body:statistic kappa", []int{1}},
+		{"title:statistic", []int{}},
+		{"url:wiki access", []int{3}},
+		{`title:"multiple access"`, []int{3}},
 	}
+	s := SetUpSearcher()
 	for _, pair := range pairs {
-		out := shuntingYard(pair.token)
-		if len(out) == len(pair.output) {
-			for i := range out {
-				if out[i] != pair.output[i] {
-					t.Errorf("Wrong token: Got %v, Wanted %v.", out, pair.output)
+		res := s.TermsQuery(pair.query)
+		if len(res) == len(pair.results) {
+			for i := range res {
+				if res[i] != pair.results[i] {
+					t.Errorf("Wrong id: Got %v, Wanted %v.", res, pair.results)
 				}
 			}
 		} else {
-			t.Errorf("Different number of token: Got %v, Wanted %v.", out, pair.output)
+			t.Errorf("Different number of results: Got %v, Wanted %v.", res, pair.results)
 		}
 	}
 }
 
-func TestSplitTrimToLower(t *testing.T) {
+func TestSearcher_BooleanQuery(t *testing.T) {
 	pairs := []struct{
-		str string
-		output []string
+		query string
+		results []int
 	}{
-		{"Hello && World", []string{"hello", "world"}},
-		{"Hello, Goodbye && Tomorrow && Time ", []string{"hello, goodbye", "tomorrow", "time"}},
+		{"", []int{}},
+		{"statistic && coefficient", []int{1}},
+		{"statistic && coefficient && items", []int{1}},
+		{"sTatistic && coeffIcient &&items", []int{1}},
+		{"reliability || technologies", []int{1, 3}},
+		{"qualitative || semantics && reliability || technologies", []int{1, 3}},
+		// Malformed queries return no documents.
+		{"|| technique && language && processing", []int{}},
+		{`"natural language" && technique`, []int{2}},
+		{`"language natural" || "channel access"`, []int{3}},
+		{"(qualitative || semantics) && (reliability || technologies)", []int{1}},
+		{"is AND NOT (kappa OR channel)", []int{2}},
+		{"!statistic", []int{2, 3}},
+		{"natural language processing || kappa", []int{1, 2}},
+		{"code NEAR/3 access || cohen BEFORE/2 kappa", []int{1, 3}},
+		{"title:kappa || title:analysis", []int{1, 2}},
+		{"body:code NEAR/3 body:access", []int{3}},
+		{"body:code NEAR/2 body:access", []int{}},
+		{`is && !title:"latent semantic"`, []int{1, 3}},
 	}
+	s := SetUpSearcher()
 	for _, pair := range pairs {
-		out := splitTrimToLower(pair.str, "&&")
-		if len(out) == len(pair.output) {
-			for i := range out {
-				if out[i] != pair.output[i] {
-					t.Errorf("Wrong token: Got %v, Wanted %v.", out, pair.output)
+		res := s.BooleanQuery(pair.query)
+		if len(res) == len(pair.results) {
+			for i := range res {
+				if res[i] != pair.results[i] {
+					t.Errorf("Wrong id: Got %v, Wanted %v.", res, pair.results)
 				}
 			}
 		} else {
-			t.Errorf("Different number of token: Got %v, Wanted %v.", out, pair.output)
+			t.Errorf("Different number of results: Got %v, Wanted %v.", res, pair.results)
 		}
 	}
 }
 
-func SetUpSearcher() (s *Searcher) {
-	s = NewSearcher(3, NewCSVStorage("example.csv"))
-	s.BuildIndices()
-	return
-}
-
-func TestSearcher_TermQuery(t *testing.T) {
+func TestSearcher_PhraseQuery(t *testing.T) {
 	pairs := []struct{
 		query string
 		results []int
 	}{
+		{"latent semantic analysis", []int{2}},
+		{"Cohen's kappa", []int{1}},
 		{"is a statistic", []int{1}},
-		{"language", []int{2}},
-		{"is", []int{1, 2, 3}},
-
+		{"semantic latent", []int{}},
+		// Phrases do not match across the title and body.
+		{"analysis latent", []int{}},
 	}
 	s := SetUpSearcher()
 	for _, pair := range pairs {
-		res := s.TermsQuery(pair.query)
+		res := s.PhraseQuery(pair.query)
 		if len(res) == len(pair.results) {
 			for i := range res {
 				if res[i] != pair.results[i] {
@@ -110,22 +108,24 @@ func TestSearcher_TermQuery(t *testing.T) {
 	}
 }
 
-func TestSearcher_BooleanQuery(t *testing.T) {
+func TestSearcher_ProximityQuery(t *testing.T) {
 	pairs := []struct{
 		query string
 		results []int
 	}{
-		{"", []int{}},
-		{"statistic && coefficient", []int{1}},
-		{"statistic && coefficient && items", []int{1}},
-		{"sTatistic && coeffIcient &&items", []int{1}},
-		{"reliability || technologies", []int{1, 3}},
-		{"qualitative || semantics && reliability || technologies", []int{1, 3}},
-		{"|| technique && language && processing", []int{2}},
+		{"code NEAR/3 access", []int{3}},
+		{"access NEAR/3 code", []int{3}},
+		{"code BEFORE/3 access", []int{3}},
+		{"access BEFORE/3 code", []int{}},
+		{"code NEAR/2 access", []int{}},
+		{"statistic NEAR/2 measure", []int{}},
+		{"statistic NEAR/5 measure", []int{1}},
+		{"semantic BEFORE/1 analysis latent", []int{2}},
+		{"NEAR/2 kappa", []int{1}},
 	}
 	s := SetUpSearcher()
 	for _, pair := range pairs {
-		res := s.BooleanQuery(pair.query)
+		res := s.ProximityQuery(pair.query)
 		if len(res) == len(pair.results) {
 			for i := range res {
 				if res[i] != pair.results[i] {
@@ -133,7 +133,7 @@ func TestSearcher_BooleanQuery(t *testing.T) {
 				}
 			}
 		} else {
-			t.Errorf("Different number of results: Got %v, Wanted %v.", res, pair.results)
+			t.Errorf("Different number of results for %q: Got %v, Wanted %v.", pair.query, res, pair.results)
 		}
 	}
 }
@@ -224,6 +224,10 @@ func TestSearcher_BM25Query(t *testing.T) {
 		{"latent semantic", []int{2}},
 		{"statistic that", []int{1, 2}},
 		{"matrix communication channel", []int{3, 2}},
+		{`"statistic that"`, []int{1}},
+		{`"latent semantic" cohen`, []int{1, 2}},
+		{"title:channel", []int{}},
+		{"title:access channel", []int{3}},
 	}
 	s := SetUpSearcher()
 	for _, pair := range pairs {
@@ -238,4 +242,367 @@ func TestSearcher_BM25Query(t *testing.T) {
 			t.Errorf("Different number of results: Got %v, Wanted %v.", res, pair.results)
 		}
 	}
-}
\ No newline at end of file
+}
+// memoryStorage is a DocumentStorage that keeps documents in memory.
+type memoryStorage struct {
+	docs []Document
+}
+
+func (store *memoryStorage) Apply(fn documentFn) {
+	for _, doc := range store.docs {
+		fn(doc)
+	}
+}
+
+// put adds the document, or replaces the document with the same ID.
+func (store *memoryStorage) put(doc Document) {
+	for i := range store.docs {
+		if store.docs[i].id == doc.id {
+			store.docs[i] = doc
+			return
+		}
+	}
+	store.docs = append(store.docs, doc)
+}
+
+// SetUpMemorySearcher returns a Searcher of the documents of example.csv,
+// which are kept in a memoryStorage so that they can be changed.
+func SetUpMemorySearcher() (*Searcher, *memoryStorage) {
+	store := &memoryStorage{}
+	NewCSVStorage("example.csv").Apply(store.put)
+	s := NewSearcher(3, store)
+	s.BuildIndices()
+	return s, store
+}
+
+func (store *memoryStorage) Get(ids []int) (resultsList []Document) {
+	resultsList = make([]Document, len(ids))
+	for idx, id := range ids {
+		for _, doc := range store.docs {
+			if doc.id == id {
+				resultsList[idx] = doc
+			}
+		}
+	}
+	return
+}
+
+func TestSearcher_UpdateDocuments(t *testing.T) {
+	s, store := SetUpMemorySearcher()
+	docs := append([]Document{}, store.docs...)
+	updated := Document{id: 3, Title: "Channel access", Body: "A latent channel is shared by many users."}
+	added := Document{id: 7, Title: "Kappa statistic", Body: "Kappa measures the agreement between raters."}
+
+	if s.UpdateDocument(updated) == nil || s.AddDocument(added) == nil {
+		t.Errorf("Expected errors for documents that are not in the storage.")
+	}
+	store.put(updated)
+	store.put(added)
+	if err := s.DeleteDocument(2); err != nil {
+		t.Error(err)
+	}
+	if err := s.UpdateDocument(updated); err != nil {
+		t.Error(err)
+	}
+	if err := s.AddDocument(added); err != nil {
+		t.Error(err)
+	}
+	if s.DeleteDocument(2) == nil || s.UpdateDocument(Document{id: 2}) == nil || s.AddDocument(added) == nil {
+		t.Errorf("Expected errors for missing or duplicate documents.")
+	}
+
+	rebuilt := NewSearcher(3, &memoryStorage{docs: []Document{docs[0], updated, added}})
+	rebuilt.BuildIndices()
+	if !reflect.DeepEqual(s.ii, rebuilt.ii) {
+		t.Errorf("Inverted index differs from a rebuilt index.")
+	}
+	if !reflect.DeepEqual(s.fields, rebuilt.fields) {
+		t.Errorf("Field indices differ from rebuilt indices.")
+	}
+	if got, want := s.ki.Terms(), rebuilt.ki.Terms(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong k-gram terms: Got %v, Wanted %v.", got, want)
+	}
+	if !reflect.DeepEqual(s.docTerms, rebuilt.docTerms) {
+		t.Errorf("Document terms differ from rebuilt document terms.")
+	}
+	if !reflect.DeepEqual(s.docLen, rebuilt.docLen) {
+		t.Errorf("Wrong document lengths: Got %v, Wanted %v.", s.docLen, rebuilt.docLen)
+	}
+	if !reflect.DeepEqual(s.fieldLen, rebuilt.fieldLen) {
+		t.Errorf("Field lengths differ from rebuilt lengths.")
+	}
+	for _, query := range []string{"kappa", "latent channel", "semantic", "agreement between"} {
+		if res, want := s.BM25Query(query), rebuilt.BM25Query(query); !reflect.DeepEqual(res, want) {
+			t.Errorf("Wrong results for %q: Got %v, Wanted %v.", query, res, want)
+		}
+	}
+}
+
+func TestSearcher_DeleteDocumentSurfaceForms(t *testing.T) {
+	s := NewSearcher(3, &memoryStorage{docs: []Document{
+		{id: 1, Title: "Running", Body: "The running man."},
+		{id: 2, Title: "Runs", Body: "He runs home."},
+		{id: 3, Title: "Walking", Body: "She walks home."},
+	}})
+	s.SetTermDictionary(PermutermDictionary)
+	s.BuildIndices()
+	if err := s.DeleteDocument(1); err != nil {
+		t.Fatal(err)
+	}
+	// "run" is still indexed, but only as the surface form "runs".
+	if res := s.BM25Query("running"); len(res) != 1 || res[0] != 2 {
+		t.Errorf("Wrong results: Got %v, Wanted [2].", res)
+	}
+	if s.ki.hasTerm("running") || !s.ki.hasTerm("runs") {
+		t.Errorf("Wrong k-gram terms: Got %v.", s.ki.Terms())
+	}
+	if got := s.surfaceForms["run"]; len(got) != 1 || got[0] != "runs" {
+		t.Errorf("Wrong surface forms: Got %v, Wanted [runs].", got)
+	}
+	if got := s.permuterm.WildcardTerms("run*"); len(got) != 1 || got[0] != "runs" {
+		t.Errorf("Wrong permuterm terms: Got %v, Wanted [runs].", got)
+	}
+	for _, completion := range s.Complete("run", 5, false) {
+		if completion.Text == "running" {
+			t.Errorf("Deleted surface form %q was completed.", completion.Text)
+		}
+	}
+	if res := s.fields["body"].PostingsList("man"); len(res) != 0 {
+		t.Errorf("Wrong postings list: Got %v, Wanted [].", res)
+	}
+	if res := s.ii.PostingsList("home"); len(res) != 2 {
+		t.Errorf("Wrong postings list: Got %v, Wanted [2 3].", res)
+	}
+}
+
+func TestSearcher_BM25ProximityQuery(t *testing.T) {
+	s := NewSearcher(3, &memoryStorage{docs: []Document{
+		{id: 1, Title: "One", Body: "red red car blue apple"},
+		{id: 2, Title: "Two", Body: "blue car and red apple"},
+		{id: 3, Title: "Three", Body: "green bicycle"},
+	}})
+	s.BuildIndices()
+	// Document 1 contains "red" more often, but the terms are next to each other in document 2.
+	if res := s.BM25Query("red apple"); len(res) != 2 || res[0] != 1 {
+		t.Errorf("Wrong BM25 results: Got %v, Wanted [1 2].", res)
+	}
+	if res := s.BM25ProximityQuery("red apple"); len(res) != 2 || res[0] != 2 {
+		t.Errorf("Wrong BM25 proximity results: Got %v, Wanted [2 1].", res)
+	}
+
+	pairs := []struct{
+		query string
+		results []int
+	}{
+		{"cohen", []int{1}},
+		{"latent semantic", []int{2}},
+		{"matrix communication channel", []int{3, 2}},
+	}
+	s = SetUpSearcher()
+	for _, pair := range pairs {
+		res := s.BM25ProximityQuery(pair.query)
+		if len(res) == len(pair.results) {
+			for i := range res {
+				if res[i] != pair.results[i] {
+					t.Errorf("Wrong id: Got %v, Wanted %v.", res, pair.results)
+				}
+			}
+		} else {
+			t.Errorf("Different number of results: Got %v, Wanted %v.", res, pair.results)
+		}
+	}
+}
+
+func TestSearcher_BM25FQuery(t *testing.T) {
+	s := NewSearcher(3, &memoryStorage{docs: []Document{
+		{id: 1, Title: "Cohen's kappa", Body: "A statistic of agreement between two raters."},
+		{id: 2, Title: "Fleiss' kappa", Body: "Fleiss' kappa generalizes Cohen's kappa to more than two raters, unlike Cohen's kappa."},
+	}})
+	s.BuildIndices()
+	// Document 2 mentions the phrase twice in its body, but document 1 has it as its title.
+	if res := s.BM25FQuery(`"cohen's kappa"`); len(res) != 2 || res[0] != 1 {
+		t.Errorf("Wrong BM25F results: Got %v, Wanted [1 2].", res)
+	}
+	s.SetBM25FParams(BM25FParams{K1: 1.2, Fields: map[string]BM25FField{"body": {Boost: 1, B: 0.75}}})
+	if res := s.BM25FQuery(`"cohen's kappa"`); len(res) != 1 || res[0] != 2 {
+		t.Errorf("Wrong BM25F results without title: Got %v, Wanted [2].", res)
+	}
+
+	pairs := []struct{
+		query string
+		results []int
+	}{
+		{"cohen", []int{1}},
+		{"kappa", []int{1}},
+		{"latent semantic", []int{2}},
+		{"matrix communication channel", []int{3, 2}},
+		{"title:access channel", []int{3}},
+		{"title:channel", []int{}},
+	}
+	s = SetUpSearcher()
+	for _, pair := range pairs {
+		res := s.BM25FQuery(pair.query)
+		if len(res) == len(pair.results) {
+			for i := range res {
+				if res[i] != pair.results[i] {
+					t.Errorf("Wrong id: Got %v, Wanted %v.", res, pair.results)
+				}
+			}
+		} else {
+			t.Errorf("Different number of results: Got %v, Wanted %v.", res, pair.results)
+		}
+	}
+}
+
+func TestSearcher_Stemming(t *testing.T) {
+	s := SetUpSearcher()
+	pairs := []struct {
+		query   string
+		fn      func(string) []int
+		results []int
+	}{
+		{"relationship", s.TermsQuery, []int{2}},
+		{"analyzing relationships", s.BM25Query, []int{2}},
+		{"analyzed", s.TermsQuery, []int{2}},
+		{`"measuring inter rater"`, s.TermsQuery, []int{1}},
+		{"relationshp", s.FuzzyQuery, []int{2}},
+		{"analyzin*", s.WildcardQuery, []int{2}},
+		{"analyzed*", s.WildcardQuery, []int{}},
+	}
+	for _, pair := range pairs {
+		if res := pair.fn(pair.query); !reflect.DeepEqual(res, pair.results) {
+			t.Errorf("Wrong results for %q: Got %v, Wanted %v.", pair.query, res, pair.results)
+		}
+	}
+	// The k-gram index keeps the words as they appear in the documents.
+	for _, term := range []string{"analyzing", "relationships"} {
+		if !s.ki.hasTerm(term) {
+			t.Errorf("Missing k-gram term %q.", term)
+		}
+	}
+}
+
+func TestSearcher_StemmedMatches(t *testing.T) {
+	s := NewSearcher(3, &memoryStorage{docs: []Document{
+		{id: 1, Title: "Latent semantic analysis", Body: "The analysis of the relationship between terms and documents."},
+		{id: 2, Title: "Cohen's kappa", Body: "Kappa is analyzed for raters."},
+	}})
+	s.BuildIndices()
+	// None of the query words appear in the documents as they are written.
+	pairs := []struct {
+		query   string
+		fn      func(string) []int
+		results []int
+	}{
+		{"relationships", s.TermsQuery, []int{1}},
+		{"analyzing relationships", s.BM25Query, []int{1, 2}},
+		{"analyzing AND relationships", s.BooleanQuery, []int{1}},
+		{"analyses", s.TermsQuery, []int{1, 2}},
+	}
+	for _, pair := range pairs {
+		if res := pair.fn(pair.query); !reflect.DeepEqual(res, pair.results) {
+			t.Errorf("Wrong results for %q: Got %v, Wanted %v.", pair.query, res, pair.results)
+		}
+	}
+}
+
+func TestSearcher_UnicodeQuery(t *testing.T) {
+	s := NewSearcher(3, &memoryStorage{docs: []Document{
+		{id: 1, Title: "Cohen's kappa", Body: "Cohen's kappa coefficient (κ) is a statistic."},
+		{id: 2, Title: "Hinrich Schütze", Body: "Schütze is a computational linguist."},
+		{id: 3, Title: "東京都", Body: "東京都は日本の首都である。"},
+	}})
+	s.BuildIndices()
+	pairs := []struct {
+		query   string
+		fn      func(string) []int
+		results []int
+	}{
+		{"κ", s.TermsQuery, []int{1}},
+		{"Schütze", s.TermsQuery, []int{2}},
+		{"SCHÜTZE", s.BM25Query, []int{2}},
+		{"日本", s.TermsQuery, []int{3}},
+		{"京都", s.TermsQuery, []int{3}},
+		{"日本の首都", s.PhraseQuery, []int{3}},
+		{"schütz?", s.WildcardQuery, []int{2}},
+		{"schützr", s.FuzzyQuery, []int{2}},
+	}
+	for _, pair := range pairs {
+		if res := pair.fn(pair.query); !reflect.DeepEqual(res, pair.results) {
+			t.Errorf("Wrong results for %q: Got %v, Wanted %v.", pair.query, res, pair.results)
+		}
+	}
+}
+
+// benchmarkSearcher is the Searcher of the benchmark corpus, which is built once.
+var benchmarkSearcher *Searcher
+
+func SetUpBenchmarkSearcher(b *testing.B) *Searcher {
+	if benchmarkSearcher == nil {
+		b.StopTimer()
+		benchmarkSearcher = SetUpCorpusSearcher(benchmarkCorpus())
+		b.StartTimer()
+	}
+	return benchmarkSearcher
+}
+
+// SetUpCorpusSearcher indexes documents of the benchmark corpus, where
+// the first word of a document is its Title and the rest its Body.
+func SetUpCorpusSearcher(corpus [][]int) *Searcher {
+	s := NewSearcher(3, nil)
+	s.SetAnalyzer(SimpleAnalyzer)
+	for i, terms := range corpus {
+		words := make([]string, len(terms))
+		for j, term := range terms {
+			words[j] = benchmarkTermName(term)
+		}
+		s.indexDocument(Document{id: i + 1, Title: words[0], Body: strings.Join(words[1:], " ")})
+	}
+	s.flushIndices()
+	return s
+}
+
+// BenchmarkSearcher_Query runs queries from a common and a rare term
+// on the 100,000 documents of the benchmark corpus.
+func BenchmarkSearcher_Query(b *testing.B) {
+	queries := map[string]queryFunc{}
+	s := SetUpBenchmarkSearcher(b)
+	queries["TermsQuery"] = s.TermsQuery
+	queries["BooleanQuery"] = s.BooleanQuery
+	queries["BM25Query"] = s.BM25Query
+	queries["BM25TopK"] = func(query string) []int { return s.BM25TopK(query, 10).ids }
+	for _, name := range []string{"TermsQuery", "BooleanQuery", "BM25Query", "BM25TopK"} {
+		for _, pair := range benchmarkIntersections {
+			query := benchmarkTermName(pair.term1) + " " + benchmarkTermName(pair.term2)
+			b.Run(name+"/"+pair.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					queries[name](query)
+				}
+			})
+		}
+	}
+}
+
+func TestSearcher_ProximityAcrossFields(t *testing.T) {
+	s := NewSearcher(3, &memoryStorage{docs: []Document{
+		{id: 1, Title: "Fleiss' kappa", Body: "A statistic of the agreement between many raters."},
+	}})
+	s.BuildIndices()
+	// Distances beyond the gap between the Title and Body are capped below it.
+	pairs := []struct{
+		query string
+		results []int
+	}{
+		{"kappa NEAR/1000 statistic", []int{}},
+		{"statistic NEAR/1000 raters", []int{1}},
+		{"fleiss BEFORE/1000 kappa", []int{1}},
+	}
+	for _, pair := range pairs {
+		if res := s.ProximityQuery(pair.query); !reflect.DeepEqual(append([]int{}, res...), pair.results) {
+			t.Errorf("Wrong proximity results for %q: Got %v, Wanted %v.", pair.query, res, pair.results)
+		}
+		if res := s.BooleanQuery(pair.query); !reflect.DeepEqual(append([]int{}, res...), pair.results) {
+			t.Errorf("Wrong Boolean results for %q: Got %v, Wanted %v.", pair.query, res, pair.results)
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 )
 
 const ResultsPerPage = 5
@@ -13,18 +15,83 @@ const ResultsPerPage = 5
 type SERP struct {
 	Query string
 	Page int
-	Results []Document
+	Results []SERPResult
 	Algorithm string
 	NextURL string
 	PrevURL string
+	// Suggestion is a corrected query that is proposed when
+	// the query has few results, with SuggestionURL to search it.
+	Suggestion string
+	SuggestionURL string
+	// Error describes why the query could not be parsed.
+	Error string
+	// Explain shows how the score of each result was computed.
+	Explain bool
+	// TotalHits is the number of documents that match the query,
+	// and Took is the time that the query took.
+	TotalHits int
+	Took time.Duration
 }
 
-// paginateResult returns a subset of the results based on the page number.
-func paginateResult(results []Document, page int) (resSlice []Document) {
-	if len(results) >= (page - 1) * ResultsPerPage {
-		resSlice = results[(page - 1) * ResultsPerPage : min(page * ResultsPerPage, len(results))]
+// ResultRange describes the results of the page, e.g. "1–5 of 1,234 results".
+func (p *SERP) ResultRange() string {
+	if len(p.Results) == 0 {
+		return fmt.Sprintf("No results of %s", formatCount(p.TotalHits))
 	}
-	return
+	first := (p.Page - 1) * ResultsPerPage + 1
+	return fmt.Sprintf("%s–%s of %s results", formatCount(first), formatCount(first + len(p.Results) - 1), formatCount(p.TotalHits))
+}
+
+// formatCount formats a non-negative count with commas between thousands.
+func formatCount(n int) string {
+	str := strconv.Itoa(n)
+	for i := len(str) - 3; i > 0; i -= 3 {
+		str = str[:i] + "," + str[i:]
+	}
+	return str
+}
+
+// SERPResult is a document in the SERP with a snippet of its body.
+type SERPResult struct {
+	Document
+	Hit
+	Snippet Snippet
+	// Explanation is only set when the scores are explained.
+	Explanation *Explanation
+}
+
+// FormatScore formats the score of a ranked result, or returns "" if it has no score.
+func (r SERPResult) FormatScore() string {
+	if r.Score == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*r.Score, 'f', 4, 64)
+}
+
+// paginateResult returns a subset of the hits based on the page number.
+func paginateResult(hits []Hit, page int) (hitSlice []Hit) {
+	start, end := pageRange(page, ResultsPerPage, len(hits))
+	return hits[start:end]
+}
+
+// pageRange returns the start and end of the results of a page of the given
+// size out of n results, which are both n for the pages after the last.
+// The page is compared before it is multiplied, so large pages do not overflow.
+func pageRange(page int, size int, n int) (start int, end int) {
+	if page < 1 || page - 1 > n / size {
+		return n, n
+	}
+	start = (page - 1) * size
+	return start, min(start + size, n)
+}
+
+// resultsThroughPage returns the number of results up to the end of the
+// page, which is maxInt for pages that would overflow.
+func resultsThroughPage(page int, size int) int {
+	if page > maxInt / size {
+		return maxInt
+	}
+	return page * size
 }
 
 // changePageURL creates a new URL from an existing URL with a different page number.
@@ -36,35 +103,247 @@ func changePageURL(u *url.URL, page int) string {
 	return u.String()
 }
 
-func (s *Searcher) mapNameToFunc(funcName string) (f queryFunc) {
-	funcMap := map[string]queryFunc{
+// changeQueryURL creates a new URL from an existing URL with a different query,
+// which starts again from the first page.
+func changeQueryURL(u *url.URL, query string) string {
+	u, _ = url.Parse(u.String())
+	q := u.Query()
+	q.Set("q", query)
+	q.Del("page")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// queryFuncs maps the names of the search algorithms to their queryFunc.
+func (s *Searcher) queryFuncs() map[string]queryFunc {
+	return map[string]queryFunc{
 		"BM25": s.BM25Query,
+		"BM25 Proximity": s.BM25ProximityQuery,
+		"BM25F": s.BM25FQuery,
+		"BM25+": s.weightedQuery(termWeighters["BM25+"]),
+		"BM25L": s.weightedQuery(termWeighters["BM25L"]),
+		"DFR PL2": s.weightedQuery(termWeighters["DFR PL2"]),
+		"DFR InL2": s.weightedQuery(termWeighters["DFR InL2"]),
+		"DPH": s.weightedQuery(termWeighters["DPH"]),
 		"Classic TF-IDF": s.VectorSpaceQuery,
+		"Query Likelihood (Dirichlet)": s.DirichletQuery,
+		"Query Likelihood (Jelinek-Mercer)": s.JelinekMercerQuery,
 		"Boolean": s.BooleanQuery,
 		"Terms": s.TermsQuery,
+		"Phrase": s.PhraseQuery,
+		"Proximity": s.ProximityQuery,
 		"Fuzzy": s.FuzzyQuery,
 		"Wildcard": s.WildcardQuery,
+		"Regex": s.RegexQuery,
 	}
-	f, ok := funcMap[funcName]
+}
+
+func (s *Searcher) mapNameToFunc(funcName string) (f queryFunc) {
+	f, ok := s.queryFuncs()[funcName]
 	if !ok {
 		f = s.BM25Query  // Defaults to BM25
 	}
 	return
 }
 
+// mapNameToScoringFunc returns the scoringFunc of ranked search algorithms,
+// or nil if the algorithm does not score its results.
+func (s *Searcher) mapNameToScoringFunc(funcName string) scoringFunc {
+	funcMap := map[string]scoringFunc{
+		"BM25": s.bm25Scores,
+		"BM25 Proximity": s.bm25ProximityScores,
+		"BM25F": s.bm25fScores,
+		"BM25+": s.weightedScoringFunc(termWeighters["BM25+"]),
+		"BM25L": s.weightedScoringFunc(termWeighters["BM25L"]),
+		"DFR PL2": s.weightedScoringFunc(termWeighters["DFR PL2"]),
+		"DFR InL2": s.weightedScoringFunc(termWeighters["DFR InL2"]),
+		"DPH": s.weightedScoringFunc(termWeighters["DPH"]),
+		"Classic TF-IDF": s.vectorSpaceScores,
+		"Query Likelihood (Dirichlet)": s.dirichletScores,
+		"Query Likelihood (Jelinek-Mercer)": s.jelinekMercerScores,
+		"Fuzzy": s.fuzzyScores,
+		"Wildcard": s.wildcardScores,
+		"Regex": s.regexScores,
+	}
+	return funcMap[funcName]
+}
+
+// mapNameToExplainFunc returns the explainFunc of ranked search algorithms,
+// or nil if the algorithm does not score its results.
+func (s *Searcher) mapNameToExplainFunc(funcName string) explainFunc {
+	funcMap := map[string]explainFunc{
+		"BM25": s.weightedExplainFunc(DefaultBM25Weighter),
+		"BM25 Proximity": s.explainBM25Proximity,
+		"BM25F": s.explainBM25F,
+		"BM25+": s.weightedExplainFunc(termWeighters["BM25+"]),
+		"BM25L": s.weightedExplainFunc(termWeighters["BM25L"]),
+		"DFR PL2": s.weightedExplainFunc(termWeighters["DFR PL2"]),
+		"DFR InL2": s.weightedExplainFunc(termWeighters["DFR InL2"]),
+		"DPH": s.weightedExplainFunc(termWeighters["DPH"]),
+		"Classic TF-IDF": s.explainVectorSpace,
+		"Query Likelihood (Dirichlet)": s.explainDirichlet,
+		"Query Likelihood (Jelinek-Mercer)": s.explainJelinekMercer,
+		"Fuzzy": s.explainFuzzy,
+		"Wildcard": s.explainWildcard,
+		"Regex": s.explainRegex,
+	}
+	return funcMap[funcName]
+}
+
+// topKFunc defines methods that take in a query string and returns
+// the sorted scores of the k documents that are the most relevant to the query.
+type topKFunc func(string, int) *ScoringList
+
+// mapNameToTopKFunc returns the topKFunc of ranked search algorithms that
+// can stop after the top results, or nil if the algorithm cannot.
+// Like mapNameToFunc, unknown algorithms default to BM25.
+func (s *Searcher) mapNameToTopKFunc(funcName string) topKFunc {
+	if _, ok := s.queryFuncs()[funcName]; !ok || funcName == "BM25" {
+		return s.BM25TopK
+	}
+	return nil
+}
+
+// mapRequestToFuncs returns the queryFunc, scoringFunc and explainFunc of the algorithm
+// with its parameters replaced by the parameters in the request, which are
+// the weightParamNames of a TermWeighter, e.g. k1 and b of BM25, and the SMART
+// weighting of Classic TF-IDF as smart, e.g. lnc.ltc. Returns nil functions if
+// the request has no parameters, and an error if the algorithm does not have them.
+// Like mapNameToFunc, unknown algorithms default to BM25.
+func (s *Searcher) mapRequestToFuncs(funcName string, values url.Values) (queryFunc, scoringFunc, explainFunc, error) {
+	if _, ok := s.queryFuncs()[funcName]; !ok {
+		funcName = "BM25"
+	}
+	params, err := parseWeightParams(values)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if notation := values.Get("smart"); notation != "" {
+		if funcName != "Classic TF-IDF" {
+			return nil, nil, nil, fmt.Errorf("algorithm %q has no SMART weighting", funcName)
+		}
+		if len(params) > 0 {
+			return nil, nil, nil, fmt.Errorf("algorithm %q has no parameters but smart", funcName)
+		}
+		w, err := ParseSMARTWeighting(notation)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return s.smartQuery(w), s.smartScoringFunc(w), s.smartExplainFunc(w), nil
+	}
+	if len(params) == 0 {
+		return nil, nil, nil, nil
+	}
+	w, err := termWeighter(funcName, params)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return s.weightedQuery(w), s.weightedScoringFunc(w), s.weightedExplainFunc(w), nil
+}
+
+// parseExplain parses the explain parameter of a request, which is false
+// if it is empty. Returns an error if the algorithm cannot explain its scores.
+// Like mapNameToFunc, unknown algorithms default to BM25.
+func (s *Searcher) parseExplain(funcName string, value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	explain, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid explain: %q is not a boolean", value)
+	}
+	if _, ok := s.queryFuncs()[funcName]; explain && ok && s.mapNameToExplainFunc(funcName) == nil {
+		return false, fmt.Errorf("algorithm %q does not score its results", funcName)
+	}
+	return explain, nil
+}
+
+// suggestsSpelling checks if the search algorithm proposes a corrected
+// query when it has few results, which are BM25 (the default) and Terms.
+func (s *Searcher) suggestsSpelling(funcName string) bool {
+	_, ok := s.queryFuncs()[funcName]
+	return !ok || funcName == "BM25" || funcName == "Terms"
+}
+
+// mapNameToTermsFunc returns the termsFunc of the search algorithm,
+// which expands the query to the terms that the hits are matched by.
+func (s *Searcher) mapNameToTermsFunc(funcName string) termsFunc {
+	return func(query string) []string { return s.queryTerms(query, funcName, s.analyzer, true) }
+}
+
+// highlightTerms returns the terms to highlight in the body of the results,
+// including the terms that fuzzy, wildcard and regex queries are expanded to.
+func (s *Searcher) highlightTerms(query string, funcName string, analyzer Analyzer) (terms []string) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	// Terms restricted to other fields do not match the body.
+	return s.queryTerms(query, funcName, analyzer, false)
+}
+
+// queryError returns the syntax error in the query for algorithms
+// that parse their queries, otherwise returns nil.
+func (s *Searcher) queryError(query string, funcName string, analyzer Analyzer) error {
+	if funcName == "Boolean" && query != "" {
+		_, err := parseBooleanQuery(query, analyzer)
+		return err
+	}
+	if funcName == "Regex" {
+		s.mux.RLock()
+		k := s.ki.k
+		s.mux.RUnlock()
+		_, err := parseRegexQuery(query, analyzer, k)
+		return err
+	}
+	return nil
+}
+
 func (s *Searcher) queryHandler(w http.ResponseWriter, r *http.Request) {
 	queryString := r.URL.Query().Get("q")
 	page, err := strconv.Atoi(r.URL.Query().Get("page"))
-	if err != nil {
+	if err != nil || page < 1 {
 		page = 1
 	}
 	searchAlgorithm := r.URL.Query().Get("alg")
-	res := s.Query(queryString, s.mapNameToFunc(searchAlgorithm))
-	resultSlice := paginateResult(res, page)
+	analyzer := s.currentAnalyzer()
+	var res SearchResult
+	// Queries with parameters that are not understood are not run.
+	requested, requestedScorer, requestedExplainer, paramErr := s.mapRequestToFuncs(searchAlgorithm, r.URL.Query())
+	explain, explainErr := s.parseExplain(searchAlgorithm, r.URL.Query().Get("explain"))
+	if paramErr == nil {
+		paramErr = explainErr
+	}
+	matchTerms := s.mapNameToTermsFunc(searchAlgorithm)
+	if requested != nil {
+		res = s.Search(queryString, requested, requestedScorer, matchTerms)
+	} else if paramErr != nil {
+		res = SearchResult{}
+	} else if topK := s.mapNameToTopKFunc(searchAlgorithm); topK != nil {
+		// Only the results of the page are needed.
+		res = s.SearchTopK(queryString, resultsThroughPage(page, ResultsPerPage), topK, matchTerms)
+	} else {
+		res = s.Search(queryString, s.mapNameToFunc(searchAlgorithm), s.mapNameToScoringFunc(searchAlgorithm), matchTerms)
+	}
+	hitSlice := paginateResult(res.Hits, page)
+	resultSlice := s.storage.Get(hitIDs(hitSlice))
+	terms := s.highlightTerms(queryString, searchAlgorithm, analyzer)
+	results := make([]SERPResult, len(resultSlice))
+	explainer := s.mapNameToExplainFunc(searchAlgorithm)
+	if explainer == nil {
+		explainer = s.weightedExplainFunc(DefaultBM25Weighter)  // Defaults to BM25
+	}
+	if requestedExplainer != nil {
+		explainer = requestedExplainer
+	}
+	for i, doc := range resultSlice {
+		results[i] = SERPResult{Document: doc, Hit: hitSlice[i], Snippet: MakeSnippet(doc.Body, terms, analyzer)}
+		if explain {
+			results[i].Explanation = s.Explain(queryString, doc.id, explainer)
+		}
+	}
 
 	// Create URLs for pagination.
 	var nextURL, prevURL string
-	if len(res) > page * ResultsPerPage {
+	if res.TotalHits > resultsThroughPage(page, ResultsPerPage) {
 		nextURL = changePageURL(r.URL, page + 1)
 	} else {
 		nextURL = "#"
@@ -78,10 +357,24 @@ func (s *Searcher) queryHandler(w http.ResponseWriter, r *http.Request) {
 	resultPage :=  &SERP{
 		Query: queryString,
 		Page:      page,
-		Results:   resultSlice,
+		Results:   results,
 		Algorithm: searchAlgorithm,
 		NextURL: nextURL,
 		PrevURL : prevURL,
+		Explain: explain,
+		TotalHits: res.TotalHits,
+		Took: res.Took,
+	}
+	if err := s.queryError(queryString, searchAlgorithm, analyzer); err != nil {
+		resultPage.Error = err.Error()
+	} else if paramErr != nil {
+		resultPage.Error = paramErr.Error()
+	}
+	if page == 1 && res.TotalHits < ResultsPerPage && s.suggestsSpelling(searchAlgorithm) {
+		if suggestion, ok := s.Suggest(queryString); ok {
+			resultPage.Suggestion = suggestion
+			resultPage.SuggestionURL = changeQueryURL(r.URL, suggestion)
+		}
 	}
 
 	t, err := template.ParseFiles("templates/main.html")
@@ -95,9 +388,19 @@ func (s *Searcher) queryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func RunServer(k int, store DocumentStorage) {
+// RunServer starts the search server. Indices are loaded from indexFile
+// if possible, otherwise they are built from the storage and saved to indexFile.
+func RunServer(k int, store DocumentStorage, indexFile string) {
 	s := NewSearcher(k, store)
-	s.BuildIndices()
+	if err := s.LoadIndices(indexFile); err != nil {
+		log.Println("Rebuilding indices:", err)
+		s.BuildIndices()
+		if err := s.SaveIndices(indexFile); err != nil {
+			log.Println("Cannot save indices:", err)
+		}
+	}
 	http.HandleFunc("/", s.queryHandler)
+	http.HandleFunc("/api/search", s.apiSearchHandler)
+	http.HandleFunc("/suggest", s.suggestHandler)
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
\ No newline at end of file
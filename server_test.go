@@ -0,0 +1,163 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFormatCount(t *testing.T) {
+	pairs := []struct {
+		n   int
+		str string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{1234, "1,234"},
+		{1234567, "1,234,567"},
+	}
+	for _, pair := range pairs {
+		if str := formatCount(pair.n); str != pair.str {
+			t.Errorf("Wrong count: Got %s, Wanted %s.", str, pair.str)
+		}
+	}
+}
+
+func TestSERP_ResultRange(t *testing.T) {
+	pairs := []struct {
+		serp SERP
+		str  string
+	}{
+		{SERP{Page: 1, Results: make([]SERPResult, 5), TotalHits: 1234}, "1–5 of 1,234 results"},
+		{SERP{Page: 3, Results: make([]SERPResult, 2), TotalHits: 12}, "11–12 of 12 results"},
+		{SERP{Page: 1, TotalHits: 0}, "No results of 0"},
+	}
+	for _, pair := range pairs {
+		if str := pair.serp.ResultRange(); str != pair.str {
+			t.Errorf("Wrong range: Got %s, Wanted %s.", str, pair.str)
+		}
+	}
+}
+
+func TestSearcher_QueryHandlerTotalHits(t *testing.T) {
+	pairs := []struct {
+		rawQuery string
+		want     string
+	}{
+		{"q=is", "1–3 of 3 results"},
+		{"q=is&alg=Terms", "1–3 of 3 results"},
+		{"q=matrix+communication+channel&alg=DPH", "1–2 of 2 results"},
+		{"q=zzzz", "No results"},
+	}
+	s := SetUpSearcher()
+	for _, pair := range pairs {
+		w := httptest.NewRecorder()
+		s.queryHandler(w, httptest.NewRequest(http.MethodGet, "/?"+pair.rawQuery, nil))
+		if body := w.Body.String(); !strings.Contains(body, pair.want) {
+			t.Errorf("Wrong results for %q: Wanted %q.", pair.rawQuery, pair.want)
+		}
+	}
+}
+
+func TestSearcher_HandlersShowChangedDocuments(t *testing.T) {
+	s, store := SetUpMemorySearcher()
+	updated := Document{id: 3, Title: "Channel access method", Body: "Many users share a channel."}
+	added := Document{id: 4, Title: "Fleiss' kappa", Body: "Gwet's AC1 is an alternative to kappa."}
+	store.put(updated)
+	store.put(added)
+	if err := s.UpdateDocument(updated); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddDocument(added); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp APIResponse
+	getAPISearch(t, s, "q=gwet", &resp)
+	if len(resp.Results) != 1 || resp.Results[0].ID != 4 || resp.Results[0].Title != added.Title {
+		t.Errorf("Wrong added result: Got %+v.", resp.Results)
+	}
+	resp = APIResponse{}
+	getAPISearch(t, s, "q=users+share", &resp)
+	if len(resp.Results) != 1 || resp.Results[0].ID != 3 || resp.Results[0].Title != updated.Title {
+		t.Errorf("Wrong updated result: Got %+v.", resp.Results)
+	}
+
+	w := httptest.NewRecorder()
+	s.queryHandler(w, httptest.NewRequest(http.MethodGet, "/?q=gwet", nil))
+	if body := w.Body.String(); !strings.Contains(body, "Fleiss&#39; kappa") {
+		t.Errorf("Missing title of the added document.")
+	}
+}
+
+func TestPageRange(t *testing.T) {
+	pairs := []struct {
+		page, size, n int
+		start, end    int
+	}{
+		{1, 5, 12, 0, 5},
+		{3, 5, 12, 10, 12},
+		{4, 5, 12, 12, 12},
+		{0, 5, 12, 12, 12},
+		{922337203685477581, 10, 12, 12, 12},
+	}
+	for _, pair := range pairs {
+		if start, end := pageRange(pair.page, pair.size, pair.n); start != pair.start || end != pair.end {
+			t.Errorf("Wrong range of page %d: Got [%d, %d), Wanted [%d, %d).", pair.page, start, end, pair.start, pair.end)
+		}
+	}
+	if n := resultsThroughPage(922337203685477581, 10); n != maxInt {
+		t.Errorf("Wrong results through a large page: Got %d, Wanted %d.", n, maxInt)
+	}
+}
+
+func TestSearcher_HandlersLargePage(t *testing.T) {
+	s := SetUpSearcher()
+	var resp APIResponse
+	if code := getAPISearch(t, s, "q=kappa&page=922337203685477581&size=10", &resp); code != http.StatusOK {
+		t.Errorf("Wrong status: Got %d, Wanted %d.", code, http.StatusOK)
+	}
+	if len(resp.Results) != 0 || resp.Next != "" {
+		t.Errorf("Wrong results: Got %+v.", resp)
+	}
+	for _, rawQuery := range []string{"q=kappa&page=922337203685477581", "q=kappa&alg=BM25&page=1844674407370955162", "q=kappa&page=-1"} {
+		w := httptest.NewRecorder()
+		s.queryHandler(w, httptest.NewRequest(http.MethodGet, "/?"+rawQuery, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: Wrong status: Got %d, Wanted %d.", rawQuery, w.Code, http.StatusOK)
+		}
+	}
+}
+
+// TestSearcher_HandlersSetAnalyzer runs requests while the analyzer is set,
+// which races with the requests unless they read it under the lock (go test -race).
+func TestSearcher_HandlersSetAnalyzer(t *testing.T) {
+	s := SetUpSearcher()
+	stop, done := make(chan bool), make(chan bool)
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				s.SetAnalyzer(DefaultAnalyzer)
+			}
+		}
+	}()
+	for _, rawQuery := range []string{"q=kappa", "q=kappa+AND+cohen&alg=Boolean", "q=kap.*&alg=Regex"} {
+		var resp APIResponse
+		if code := getAPISearch(t, s, rawQuery, &resp); code != http.StatusOK || len(resp.Results) == 0 {
+			t.Errorf("Wrong response to %q: Got %d %+v.", rawQuery, code, resp.Results)
+		}
+		w := httptest.NewRecorder()
+		s.queryHandler(w, httptest.NewRequest(http.MethodGet, "/?"+rawQuery, nil))
+		if !strings.Contains(w.Body.String(), "<mark>") {
+			t.Errorf("Missing highlights for %q.", rawQuery)
+		}
+	}
+	close(stop)
+	<-done
+}
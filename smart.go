@@ -0,0 +1,368 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// SMART term weighting.
+//
+// A SMART weighting is written ddd.qqq, where ddd weighs the terms of
+// documents and qqq the terms of the query, e.g. lnc.ltc. The letters are
+// the weight of the term frequency, the document frequency and the normalization:
+//
+//	term frequency      n (natural)      tf
+//	                    l (logarithm)    1 + ln(tf)
+//	                    a (augmented)    0.5 + 0.5 * tf / max tf
+//	                    b (boolean)      1
+//	                    L (log average)  (1 + ln(tf)) / (1 + ln(average tf))
+//	document frequency  n (no)           1
+//	                    t (idf)          ln(N / df)
+//	                    p (prob idf)     max(0, ln((N - df) / df))
+//	normalization       n (none)         1
+//	                    c (cosine)       1 / length of the weight vector
+//	                    u (pivoted unique) 1 / ((1 - slope) * pivot + slope * unique terms)
+//
+// The score of a document is the dot product of the weights of the query and document.
+// (Reference) Singhal, A., Buckley, C., & Mitra, M. (1996). Pivoted document length normalization.
+
+// smartTFs are the letters of the term frequency weights, in the order of documentVector.norms.
+const smartTFs = "nlabL"
+
+// smartPivotSlope is the slope of pivoted unique normalization, and the
+// pivot is the average number of unique terms in a document.
+const smartPivotSlope = 0.2
+
+// SMARTScheme is the weighting of the terms of documents or queries.
+type SMARTScheme struct {
+	TF, DF, Norm byte
+}
+
+// SMARTWeighting is the weighting of the documents and query of VectorSpaceQuery.
+// It is not a TermWeighter, because the weight of a term depends on the other
+// terms of its vector: cosine and pivoted unique normalization divide by the
+// length or the unique terms of the whole document and query, and the query
+// terms are weighted too, none of which are in TermStats. Like the parameters
+// of a TermWeighter, it is chosen per request (see mapRequestToFuncs).
+type SMARTWeighting struct {
+	Document, Query SMARTScheme
+}
+
+// DefaultSMARTWeighting is lnc.ltc, which weighs the logarithm of the term
+// frequency, only weighs the query by idf and compares by cosine similarity.
+var DefaultSMARTWeighting = SMARTWeighting{Document: SMARTScheme{'l', 'n', 'c'}, Query: SMARTScheme{'l', 't', 'c'}}
+
+// ParseSMARTWeighting parses a weighting in the SMART notation, e.g. "lnc.ltc".
+// Documents cannot be cosine normalized after weighting by document frequency,
+// because their norms are computed when they are indexed and the document
+// frequencies change afterwards.
+func ParseSMARTWeighting(notation string) (w SMARTWeighting, err error) {
+	if len(notation) != 7 || notation[3] != '.' {
+		return w, fmt.Errorf("SMART weighting %q is not of the form ddd.qqq", notation)
+	}
+	w.Document = SMARTScheme{notation[0], notation[1], notation[2]}
+	w.Query = SMARTScheme{notation[4], notation[5], notation[6]}
+	for _, scheme := range []SMARTScheme{w.Document, w.Query} {
+		if strings.IndexByte(smartTFs, scheme.TF) < 0 || strings.IndexByte("ntp", scheme.DF) < 0 || strings.IndexByte("ncu", scheme.Norm) < 0 {
+			return w, fmt.Errorf("unknown SMART weighting %q", notation)
+		}
+	}
+	if w.Document.Norm == 'c' && w.Document.DF != 'n' {
+		return w, fmt.Errorf("SMART weighting %q cannot cosine normalize documents weighted by document frequency", notation)
+	}
+	return w, nil
+}
+
+func (w SMARTWeighting) String() string {
+	return string([]byte{w.Document.TF, w.Document.DF, w.Document.Norm, '.', w.Query.TF, w.Query.DF, w.Query.Norm})
+}
+
+// vectorStats are the statistics of the term frequencies of a document
+// or query that the SMART weights depend on.
+type vectorStats struct {
+	uniqueTerms int
+	maxFreq     int
+	// length is the sum of the term frequencies.
+	length int
+}
+
+func newVectorStats(freqs map[string]int) (v vectorStats) {
+	for _, freq := range freqs {
+		v.uniqueTerms++
+		v.maxFreq = max(v.maxFreq, freq)
+		v.length += freq
+	}
+	return
+}
+
+// tfWeight returns the term frequency weight of a term in the vector.
+func (v vectorStats) tfWeight(scheme byte, tf int) float64 {
+	if tf == 0 {
+		return 0
+	}
+	switch scheme {
+	case 'l':
+		return 1 + math.Log(float64(tf))
+	case 'a':
+		return 0.5 + 0.5*float64(tf)/float64(v.maxFreq)
+	case 'b':
+		return 1
+	case 'L':
+		return (1 + math.Log(float64(tf))) / (1 + math.Log(float64(v.length)/float64(v.uniqueTerms)))
+	}
+	return float64(tf)
+}
+
+// smartDFWeight returns the document frequency weight of a term
+// that is in docFreq of the docCount documents.
+func smartDFWeight(scheme byte, docCount int, docFreq int) float64 {
+	if docFreq == 0 {
+		return 0
+	}
+	switch scheme {
+	case 't':
+		return math.Log(float64(docCount) / float64(docFreq))
+	case 'p':
+		return math.Max(0, math.Log(float64(docCount-docFreq)/float64(docFreq)))
+	}
+	return 1
+}
+
+// smartNorm returns the length that the weights of a vector with the
+// given Euclidean norm and number of unique terms are divided by.
+func (s *Searcher) smartNorm(scheme byte, norm float64, uniqueTerms int) float64 {
+	switch scheme {
+	case 'c':
+		return norm
+	case 'u':
+		return (1-smartPivotSlope)*s.docVectors.averageUniqueTerms() + smartPivotSlope*float64(uniqueTerms)
+	}
+	return 1
+}
+
+// DocumentVectors stores the statistics of the term vectors of documents,
+// which are computed when the documents are indexed.
+type DocumentVectors struct {
+	vectors map[int]documentVector
+	// totalUniqueTerms is the sum of the unique terms of all documents.
+	totalUniqueTerms int
+}
+
+type documentVector struct {
+	vectorStats
+	// norms are the Euclidean norms of the vector weighted by each
+	// of the term frequency weights of smartTFs.
+	norms [len(smartTFs)]float64
+}
+
+// setDocumentVector sets the vector of a document from its term frequencies.
+func (dv *DocumentVectors) setDocumentVector(docID int, freqs map[string]int) {
+	v := documentVector{vectorStats: newVectorStats(freqs)}
+	for i := range v.norms {
+		sumSquares := 0.0
+		for _, freq := range freqs {
+			weight := v.tfWeight(smartTFs[i], freq)
+			sumSquares += weight * weight
+		}
+		v.norms[i] = math.Sqrt(sumSquares)
+	}
+	dv.removeDocumentVector(docID)
+	if dv.vectors == nil {
+		dv.vectors = make(map[int]documentVector)
+	}
+	dv.vectors[docID] = v
+	dv.totalUniqueTerms += v.uniqueTerms
+}
+
+// removeDocumentVector removes the vector of a document.
+func (dv *DocumentVectors) removeDocumentVector(docID int) {
+	if v, ok := dv.vectors[docID]; ok {
+		dv.totalUniqueTerms -= v.uniqueTerms
+		delete(dv.vectors, docID)
+	}
+}
+
+// averageUniqueTerms returns the average number of unique terms in a document.
+func (dv *DocumentVectors) averageUniqueTerms() float64 {
+	if len(dv.vectors) == 0 {
+		return 0
+	}
+	return float64(dv.totalUniqueTerms) / float64(len(dv.vectors))
+}
+
+// termFreqs returns the frequency of each term.
+func termFreqs(terms []string) map[string]int {
+	freqs := make(map[string]int)
+	for _, term := range terms {
+		freqs[term]++
+	}
+	return freqs
+}
+
+// SetSMARTWeighting sets the weighting used by VectorSpaceQuery
+// in the SMART notation, e.g. "lnc.ltc".
+func (s *Searcher) SetSMARTWeighting(notation string) error {
+	w, err := ParseSMARTWeighting(notation)
+	if err != nil {
+		return err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.smart = w
+	return nil
+}
+
+// smartQuery returns the queryFunc of VectorSpaceQuery with the SMART weighting.
+func (s *Searcher) smartQuery(w SMARTWeighting) queryFunc {
+	return func(query string) []int {
+		resList := s.smartScores(query, w)
+		sort.Sort(resList)
+		return resList.ids
+	}
+}
+
+// smartScoringFunc returns the scoringFunc of VectorSpaceQuery with the SMART weighting.
+func (s *Searcher) smartScoringFunc(w SMARTWeighting) scoringFunc {
+	return func(query string) *ScoringList { return s.smartScores(query, w) }
+}
+
+// smartScores returns the unsorted scores of documents that contain a query
+// term, which are the dot products of the weights of the query and document.
+func (s *Searcher) smartScores(query string, w SMARTWeighting) (resList *ScoringList) {
+	resList = &ScoringList{}
+	q := s.smartQueryVector(query, w.Query)
+	if q.norm == 0 {
+		return
+	}
+	tfIndex := strings.IndexByte(smartTFs, w.Document.TF)
+	for i, term := range q.terms {
+		if q.weights[i] == 0 {
+			continue
+		}
+		docFreq := s.ii.DocumentFrequency(term)
+		queryWeight := s.smartWeight(w.Query, q.vectorStats, q.freqs[term], docFreq, q.norm)
+		for it := s.ii.Iterator(term); it.Next(); {
+			v := s.docVectors.vectors[it.DocID()]
+			docNorm := s.smartNorm(w.Document.Norm, v.norms[tfIndex], v.uniqueTerms)
+			resList.add(it.DocID(), queryWeight*s.smartWeight(w.Document, v.vectorStats, it.Freq(), docFreq, docNorm))
+		}
+	}
+	return
+}
+
+// smartWeight returns the weight of a term in a query or document vector,
+// which is divided by the norm of the vector.
+func (s *Searcher) smartWeight(scheme SMARTScheme, v vectorStats, tf int, docFreq int, norm float64) float64 {
+	return v.tfWeight(scheme.TF, tf) * smartDFWeight(scheme.DF, s.docLen.documentCount(), docFreq) / norm
+}
+
+// smartQueryVector is the weighted term vector of a query.
+type smartQueryVector struct {
+	vectorStats
+	// terms are the unique terms of the query in query order, so
+	// that the sums are deterministic, with their frequencies and weights.
+	terms   []string
+	freqs   map[string]int
+	weights []float64
+	// norm is the length that the weights are divided by.
+	norm float64
+}
+
+// smartQueryVector returns the weighted term vector of the query.
+func (s *Searcher) smartQueryVector(query string, scheme SMARTScheme) (q smartQueryVector) {
+	terms := s.terms(query)
+	q.freqs = termFreqs(terms)
+	q.vectorStats = newVectorStats(q.freqs)
+	q.terms = uniqueStrings(terms)
+	q.weights = make([]float64, len(q.terms))
+	docCount := s.docLen.documentCount()
+	sumSquares := 0.0
+	for i, term := range q.terms {
+		q.weights[i] = q.tfWeight(scheme.TF, q.freqs[term]) * smartDFWeight(scheme.DF, docCount, s.ii.DocumentFrequency(term))
+		sumSquares += q.weights[i] * q.weights[i]
+	}
+	q.norm = s.smartNorm(scheme.Norm, math.Sqrt(sumSquares), q.uniqueTerms)
+	return
+}
+
+// smartExplainFunc returns the explainFunc of VectorSpaceQuery with the SMART weighting.
+func (s *Searcher) smartExplainFunc(w SMARTWeighting) explainFunc {
+	return func(query string, docID int) *Explanation { return s.explainSMART(query, docID, w) }
+}
+
+// explainVectorSpace explains the score of a document in VectorSpaceQuery.
+func (s *Searcher) explainVectorSpace(query string, docID int) *Explanation {
+	return s.explainSMART(query, docID, s.smart)
+}
+
+// explainSMART explains the score of a document as the sum of the products
+// of the weights of each query term in the query and document.
+func (s *Searcher) explainSMART(query string, docID int, w SMARTWeighting) *Explanation {
+	v, ok := s.docVectors.vectors[docID]
+	q := s.smartQueryVector(query, w.Query)
+	if !ok || q.norm == 0 {
+		return nil
+	}
+	docNorm := s.smartNorm(w.Document.Norm, v.norms[strings.IndexByte(smartTFs, w.Document.TF)], v.uniqueTerms)
+	var details []Explanation
+	for i, term := range q.terms {
+		tf := s.ii.TermFrequency(term, docID)
+		if q.weights[i] == 0 || tf == 0 {
+			continue
+		}
+		docFreq := s.ii.DocumentFrequency(term)
+		queryWeight := s.explainSMARTWeight("query", w.Query, q.vectorStats, q.freqs[term], docFreq, q.norm)
+		docWeight := s.explainSMARTWeight("document", w.Document, v.vectorStats, tf, docFreq, docNorm)
+		details = append(details, Explanation{
+			Value:       queryWeight.Value * docWeight.Value,
+			Description: fmt.Sprintf("weight of %q, computed as query weight * document weight from:", term),
+			Details:     []Explanation{queryWeight, docWeight},
+		})
+	}
+	return explainSum(details)
+}
+
+// explainSMARTWeight explains the weight of a term in a query or document vector.
+func (s *Searcher) explainSMARTWeight(vector string, scheme SMARTScheme, v vectorStats, tf int, docFreq int, norm float64) Explanation {
+	return Explanation{
+		Value:       s.smartWeight(scheme, v, tf, docFreq, norm),
+		Description: fmt.Sprintf("%s weight (%c%c%c), computed as tf weight * df weight / norm from:", vector, scheme.TF, scheme.DF, scheme.Norm),
+		Details: []Explanation{
+			{
+				Value:       v.tfWeight(scheme.TF, tf),
+				Description: fmt.Sprintf("tf weight (%c)", scheme.TF),
+				Details:     []Explanation{explainValue(float64(tf), "tf, frequency of the term in the "+vector)},
+			},
+			{
+				Value:       smartDFWeight(scheme.DF, s.docLen.documentCount(), docFreq),
+				Description: fmt.Sprintf("df weight (%c)", scheme.DF),
+				Details: []Explanation{
+					explainValue(float64(docFreq), "df, number of documents with the term"),
+					explainValue(float64(s.docLen.documentCount()), "N, number of documents"),
+				},
+			},
+			s.explainSMARTNorm(vector, scheme.Norm, norm, v.uniqueTerms),
+		},
+	}
+}
+
+// explainSMARTNorm explains the length that the weights of a vector are divided by.
+func (s *Searcher) explainSMARTNorm(vector string, scheme byte, norm float64, uniqueTerms int) Explanation {
+	switch scheme {
+	case 'c':
+		return explainValue(norm, "norm, length of the weight vector of the "+vector)
+	case 'u':
+		return Explanation{
+			Value:       norm,
+			Description: "norm, (1 - slope) * pivot + slope * unique terms, pivoted unique normalization",
+			Details: []Explanation{
+				explainValue(smartPivotSlope, "slope"),
+				explainValue(s.docVectors.averageUniqueTerms(), "pivot, average number of unique terms in a document"),
+				explainValue(float64(uniqueTerms), "unique terms, number of unique terms in the "+vector),
+			},
+		}
+	}
+	return explainValue(norm, "norm, no normalization")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseSMARTWeighting(t *testing.T) {
+	pairs := []struct {
+		notation string
+		valid    bool
+	}{
+		{"lnc.ltc", true},
+		{"Lnu.ltu", true},
+		{"ltn.lnc", true},
+		{"anc.bpn", true},
+		{"ltc.ltc", false},
+		{"xnc.ltc", false},
+		{"lnc.lnx", false},
+		{"lnc-ltc", false},
+		{"lnc", false},
+	}
+	for _, pair := range pairs {
+		w, err := ParseSMARTWeighting(pair.notation)
+		if (err == nil) != pair.valid {
+			t.Errorf("Wrong validity of %q: Got %v, Wanted %t.", pair.notation, err, pair.valid)
+		}
+		if err == nil && w.String() != pair.notation {
+			t.Errorf("Wrong notation: Got %q, Wanted %q.", w.String(), pair.notation)
+		}
+	}
+}
+
+func TestVectorStats_TFWeight(t *testing.T) {
+	// A vector with the frequencies 1, 2 and 5.
+	v := newVectorStats(map[string]int{"a": 1, "b": 2, "c": 5})
+	pairs := []struct {
+		scheme byte
+		tf     int
+		weight float64
+	}{
+		{'n', 2, 2},
+		{'l', 1, 1},
+		{'l', 5, 1 + math.Log(5)},
+		{'a', 5, 1},
+		{'a', 2, 0.7},
+		{'b', 2, 1},
+		{'L', 2, (1 + math.Log(2)) / (1 + math.Log(8.0/3))},
+		{'l', 0, 0},
+	}
+	for _, pair := range pairs {
+		if weight := v.tfWeight(pair.scheme, pair.tf); math.Abs(weight-pair.weight) > 1e-12 {
+			t.Errorf("Wrong %c weight of %d: Got %v, Wanted %v.", pair.scheme, pair.tf, weight, pair.weight)
+		}
+	}
+}
+
+func TestDocumentVectors(t *testing.T) {
+	dv := DocumentVectors{}
+	dv.setDocumentVector(1, map[string]int{"a": 1, "b": 2})
+	dv.setDocumentVector(2, map[string]int{"a": 3, "b": 4, "c": 1, "d": 1})
+	if norm := dv.vectors[1].norms[0]; math.Abs(norm-math.Sqrt(5)) > 1e-12 {
+		t.Errorf("Wrong natural norm: Got %v, Wanted %v.", norm, math.Sqrt(5))
+	}
+	if norm := dv.vectors[2].norms[3]; norm != 2 {
+		t.Errorf("Wrong boolean norm: Got %v, Wanted 2.", norm)
+	}
+	if avg := dv.averageUniqueTerms(); avg != 3 {
+		t.Errorf("Wrong average unique terms: Got %v, Wanted 3.", avg)
+	}
+	dv.setDocumentVector(2, map[string]int{"a": 1})
+	dv.removeDocumentVector(1)
+	if avg := dv.averageUniqueTerms(); avg != 1 {
+		t.Errorf("Wrong average unique terms after removal: Got %v, Wanted 1.", avg)
+	}
+}
+
+func TestSearcher_SMARTScores(t *testing.T) {
+	s := SetUpSearcher()
+	// A query of the whole document has a cosine similarity of 1 with it.
+	cosine, _ := ParseSMARTWeighting("nnc.nnc")
+	for _, doc := range s.storage.Get([]int{1, 2, 3}) {
+		resList := s.smartScores(doc.Title+" "+doc.Body, cosine)
+		if score := resList.scores[resList.index[doc.id]]; math.Abs(score-1) > 1e-9 {
+			t.Errorf("Wrong similarity of %d with itself: Got %v, Wanted 1.", doc.id, score)
+		}
+	}
+
+	for _, notation := range []string{"lnc.ltc", "Lnu.ltu", "ltn.lnc", "anc.apc", "bnn.bnn"} {
+		w, _ := ParseSMARTWeighting(notation)
+		if res := s.smartQuery(w)("matrix communication channel"); len(res) != 2 || res[0] != 3 {
+			t.Errorf("Wrong %s results: Got %v, Wanted [3 2].", notation, res)
+		}
+	}
+
+	if err := s.SetSMARTWeighting("ltc.ltc"); err == nil {
+		t.Errorf("Missing error for cosine normalized documents with idf.")
+	}
+	if err := s.SetSMARTWeighting("bnn.bnn"); err != nil || s.smart.String() != "bnn.bnn" {
+		t.Errorf("Wrong weighting: Got %v (%v), Wanted bnn.bnn.", s.smart, err)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import "sort"
+
+const (
+	// snippetWindow is the number of tokens in a fragment of a snippet.
+	snippetWindow = 30
+	// snippetFragments is the maximum number of fragments in a snippet.
+	snippetFragments = 2
+)
+
+// Match is the byte offsets of matched tokens within a Fragment.
+// Overlapping and adjacent tokens are a single Match.
+type Match struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Fragment is a window of the text of a document.
+type Fragment struct {
+	Text    string  `json:"text"`
+	Matches []Match `json:"matches"`
+	// Start and End are the byte offsets of the fragment in the original text.
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// FragmentPart is a part of a fragment that either matched a query term or not.
+type FragmentPart struct {
+	Text  string
+	Match bool
+}
+
+// Parts splits the fragment into matched and unmatched parts,
+// so the matches can be highlighted.
+func (frag Fragment) Parts() (parts []FragmentPart) {
+	prev := 0
+	for _, m := range frag.Matches {
+		if m.Start > prev {
+			parts = append(parts, FragmentPart{Text: frag.Text[prev:m.Start]})
+		}
+		parts = append(parts, FragmentPart{Text: frag.Text[m.Start:m.End], Match: true})
+		prev = m.End
+	}
+	if prev < len(frag.Text) {
+		parts = append(parts, FragmentPart{Text: frag.Text[prev:]})
+	}
+	return
+}
+
+// Snippet is a query-biased summary of a text.
+type Snippet struct {
+	Fragments []Fragment `json:"fragments"`
+	// Leading and Trailing are true if text was cut before the first
+	// or after the last fragment.
+	Leading  bool `json:"leading"`
+	Trailing bool `json:"trailing"`
+}
+
+// MakeSnippet returns the windows of the text that contain the most query terms,
+// where the text is split into terms by the analyzer.
+// Windows with more distinct terms are preferred over windows that repeat a term.
+// If the text contains none of the terms, the snippet is the start of the text.
+func MakeSnippet(text string, terms []string, analyzer Analyzer) (snippet Snippet) {
+	tokens := analyzer.Analyze(text)
+	if len(tokens) == 0 {
+		return
+	}
+	termSet := make(map[string]bool)
+	for _, term := range terms {
+		termSet[term] = true
+	}
+	matched := make([]string, len(tokens))
+	for i, token := range tokens {
+		if termSet[token.Text] {
+			matched[i] = token.Text
+		}
+	}
+
+	window := min(snippetWindow, len(tokens))
+	var starts []int
+	used := make([]bool, len(tokens))
+	for len(starts) < snippetFragments {
+		start, score := bestWindow(matched, used, window)
+		if start == -1 || (score == 0 && len(starts) > 0) {
+			break
+		}
+		start = centerWindow(matched, used, start, window)
+		starts = append(starts, start)
+		for i := start; i < start+window; i++ {
+			used[i] = true
+		}
+	}
+	sort.Ints(starts)
+
+	for _, start := range starts {
+		frag := Fragment{Start: tokens[start].Start, End: tokens[start+window-1].End}
+		if start == 0 {
+			frag.Start = 0
+		}
+		if start+window == len(tokens) {
+			frag.End = len(text)
+		}
+		frag.Text = text[frag.Start:frag.End]
+		for i := start; i < start+window; i++ {
+			if matched[i] == "" {
+				continue
+			}
+			m := Match{tokens[i].Start - frag.Start, tokens[i].End - frag.Start}
+			// Tokens such as the bigrams of CJK text overlap, and are
+			// merged with the previous match so that no text is repeated.
+			if n := len(frag.Matches); n > 0 && m.Start <= frag.Matches[n-1].End {
+				frag.Matches[n-1].End = max(frag.Matches[n-1].End, m.End)
+				continue
+			}
+			frag.Matches = append(frag.Matches, m)
+		}
+		snippet.Fragments = append(snippet.Fragments, frag)
+	}
+	snippet.Leading = starts[0] != 0
+	snippet.Trailing = starts[len(starts)-1]+window != len(tokens)
+	return
+}
+
+// bestWindow returns the start of the window with the highest score that
+// does not overlap used tokens, or -1 if there is no such window.
+// A window scores a point for each match and ten for each distinct term.
+func bestWindow(matched []string, used []bool, window int) (best int, bestScore int) {
+	best, bestScore = -1, -1
+	for start := 0; start+window <= len(matched); start++ {
+		score := 0
+		seen := make(map[string]bool)
+		overlaps := false
+		for i := start; i < start+window; i++ {
+			if used[i] {
+				overlaps = true
+				break
+			}
+			if token := matched[i]; token != "" {
+				score++
+				if !seen[token] {
+					seen[token] = true
+					score += 10
+				}
+			}
+		}
+		if !overlaps && score > bestScore {
+			best, bestScore = start, score
+		}
+	}
+	return
+}
+
+// centerWindow moves the window so its matches are in the middle,
+// unless the moved window would overlap used tokens.
+func centerWindow(matched []string, used []bool, start int, window int) int {
+	first, last := -1, -1
+	for i := start; i < start+window; i++ {
+		if matched[i] != "" {
+			if first == -1 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first == -1 {
+		return start
+	}
+	centered := (first+last)/2 - window/2
+	centered = max(0, min(centered, len(matched)-window))
+	for i := centered; i < centered+window; i++ {
+		if used[i] {
+			return start
+		}
+	}
+	return centered
+}
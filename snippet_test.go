@@ -0,0 +1,120 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// renderSnippet writes the snippet as text with matches in brackets.
+func renderSnippet(snippet Snippet) string {
+	var b strings.Builder
+	if snippet.Leading {
+		b.WriteString("... ")
+	}
+	for i, frag := range snippet.Fragments {
+		if i > 0 {
+			b.WriteString(" ... ")
+		}
+		for _, part := range frag.Parts() {
+			if part.Match {
+				b.WriteString("[" + part.Text + "]")
+			} else {
+				b.WriteString(part.Text)
+			}
+		}
+	}
+	if snippet.Trailing {
+		b.WriteString(" ...")
+	}
+	return b.String()
+}
+
+func TestMakeSnippet(t *testing.T) {
+	filler := strings.Repeat("x ", 40)
+	pairs := []struct {
+		text    string
+		terms   []string
+		snippet string
+	}{
+		{"Cohen's kappa is a statistic.", []string{"kappa"}, "Cohen's [kappa] is a statistic."},
+		{"Cohen's Kappa, kappa!", []string{"kappa", "cohen"}, "[Cohen]'s [Kappa], [kappa]!"},
+		{"", []string{"kappa"}, ""},
+		{filler + "a kappa statistic", nil, strings.TrimSpace(strings.Repeat("x ", 30)) + " ..."},
+		{filler + "a kappa statistic", []string{"kappa"}, "... " + strings.TrimSpace(strings.Repeat("x ", 27)) + " a [kappa] statistic"},
+		{"kappa " + filler + filler + "kappa statistic", []string{"kappa", "statistic"},
+			"[kappa] " + strings.TrimSpace(strings.Repeat("x ", 29)) + " ... " + strings.Repeat("x ", 28) + "[kappa] [statistic]"},
+	}
+	for _, pair := range pairs {
+		if got := renderSnippet(MakeSnippet(pair.text, pair.terms, SimpleAnalyzer)); got != pair.snippet {
+			t.Errorf("Wrong snippet: Got %q, Wanted %q.", got, pair.snippet)
+		}
+	}
+}
+
+func TestMakeSnippet_Stemmed(t *testing.T) {
+	snippet := MakeSnippet("Cohen's kappa statistics", analyzeTerms(DefaultAnalyzer, "statistical cohen"), DefaultAnalyzer)
+	if got, want := renderSnippet(snippet), "[Cohen]'s kappa [statistics]"; got != want {
+		t.Errorf("Wrong snippet: Got %q, Wanted %q.", got, want)
+	}
+}
+
+func TestMakeSnippet_CJK(t *testing.T) {
+	pairs := []struct {
+		text    string
+		query   string
+		snippet string
+		matches []Match
+	}{
+		{"東京都に住む", "東京都", "[東京都]に住む", []Match{{0, 9}}},
+		{"東京都に住む", "京都", "東[京都]に住む", []Match{{3, 9}}},
+		{"東京と京都", "東京 京都", "[東京]と[京都]", []Match{{0, 6}, {9, 15}}},
+	}
+	for _, pair := range pairs {
+		snippet := MakeSnippet(pair.text, analyzeTerms(DefaultAnalyzer, pair.query), DefaultAnalyzer)
+		if got := renderSnippet(snippet); got != pair.snippet {
+			t.Errorf("Wrong snippet of %q: Got %q, Wanted %q.", pair.query, got, pair.snippet)
+		}
+		if len(snippet.Fragments) != 1 || !reflect.DeepEqual(snippet.Fragments[0].Matches, pair.matches) {
+			t.Errorf("Wrong matches of %q: Got %+v, Wanted %v.", pair.query, snippet.Fragments, pair.matches)
+		}
+	}
+}
+
+func TestMakeSnippet_Offsets(t *testing.T) {
+	text := strings.Repeat("x ", 40) + "Latent semantic analysis " + strings.Repeat("y ", 40)
+	snippet := MakeSnippet(text, []string{"semantic"}, SimpleAnalyzer)
+	if len(snippet.Fragments) != 1 {
+		t.Fatalf("Wrong number of fragments: Got %d, Wanted 1.", len(snippet.Fragments))
+	}
+	frag := snippet.Fragments[0]
+	if text[frag.Start:frag.End] != frag.Text {
+		t.Errorf("Fragment offsets do not match its text.")
+	}
+	m := frag.Matches[0]
+	if frag.Text[m.Start:m.End] != "semantic" || text[frag.Start+m.Start:frag.Start+m.End] != "semantic" {
+		t.Errorf("Wrong match offsets: %v.", m)
+	}
+}
+
+func TestSearcher_HighlightTerms(t *testing.T) {
+	pairs := []struct {
+		query    string
+		funcName string
+		terms    []string
+	}{
+		{"Latent semantic", "BM25", []string{"latent", "semant"}},
+		{`title:kappa body:"inter rater"`, "Terms", []string{"inter", "rater"}},
+		{"cohdn", "Fuzzy", []string{"cohen"}},
+		{"statis*", "Wildcard", []string{"statist"}},
+	}
+	s := SetUpSearcher()
+	for _, pair := range pairs {
+		terms := s.highlightTerms(pair.query, pair.funcName, s.analyzer)
+		sort.Strings(terms)
+		if !reflect.DeepEqual(terms, pair.terms) {
+			t.Errorf("Wrong terms for %q: Got %v, Wanted %v.", pair.query, terms, pair.terms)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import "strings"
+
+// Porter2Stemmer is the English (Porter2) stemmer of the Snowball project.
+// Words are expected to be lowercase.
+// (Reference) https://snowballstem.org/algorithms/english/stemmer.html
+type Porter2Stemmer struct{}
+
+// porter2Exceptions are words that are not stemmed by the rules.
+var porter2Exceptions = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli",
+	"singly": "singl", "sky": "sky", "news": "news", "howe": "howe",
+	"atlas": "atlas", "cosmos": "cosmos", "bias": "bias", "andes": "andes",
+}
+
+// EnglishLemmas is the table of words that the DefaultAnalyzer replaces with
+// their lemma before stemming, because Porter2 does not conflate them with the
+// other forms of the lemma, e.g. it stems "analysis" to "analysi", "analysing"
+// to "analys" but "analyzing" to "analyz".
+//
+// Its scope is the nouns in -ysis of the verbs in -yze whose British spelling
+// is in -yse: the noun, its plural in -yses, and the British verb forms in
+// -yse, -ysed, -ysing, -yser and -ysers are mapped to the verb in -yze, which
+// is stemmed like its other American forms. Other nouns in -sis, such as
+// "hypothesis" and "hypothesize", and derived words, such as "analyst" and
+// "analytic", are out of scope and stemmed by Porter2 alone.
+var EnglishLemmas = map[string]string{
+	"analysis": "analyze", "analyses": "analyze", "analyse": "analyze", "analysed": "analyze",
+	"analysing": "analyze", "analyser": "analyze", "analysers": "analyze",
+	"catalysis": "catalyze", "catalyses": "catalyze", "catalyse": "catalyze", "catalysed": "catalyze",
+	"catalysing": "catalyze", "catalyser": "catalyze", "catalysers": "catalyze",
+	"dialysis": "dialyze", "dialyses": "dialyze", "dialyse": "dialyze", "dialysed": "dialyze",
+	"dialysing": "dialyze", "dialyser": "dialyze", "dialysers": "dialyze",
+	"electrolysis": "electrolyze", "electrolyses": "electrolyze", "electrolyse": "electrolyze", "electrolysed": "electrolyze",
+	"electrolysing": "electrolyze", "electrolyser": "electrolyze", "electrolysers": "electrolyze",
+	"hydrolysis": "hydrolyze", "hydrolyses": "hydrolyze", "hydrolyse": "hydrolyze", "hydrolysed": "hydrolyze",
+	"hydrolysing": "hydrolyze", "hydrolyser": "hydrolyze", "hydrolysers": "hydrolyze",
+	"paralysis": "paralyze", "paralyses": "paralyze", "paralyse": "paralyze", "paralysed": "paralyze",
+	"paralysing": "paralyze", "paralyser": "paralyze", "paralysers": "paralyze",
+}
+
+// porter2Invariants are words that are not changed after step 1a.
+var porter2Invariants = map[string]bool{
+	"inning": true, "outing": true, "canning": true, "herring": true,
+	"earring": true, "proceed": true, "exceed": true, "succeed": true,
+}
+
+func (Porter2Stemmer) Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	if stem, ok := porter2Exceptions[word]; ok {
+		return stem
+	}
+	w := &porter2Word{b: []byte(strings.TrimPrefix(word, "'"))}
+	w.markConsonantY()
+	w.findRegions()
+
+	w.step0()
+	w.step1a()
+	if porter2Invariants[string(w.b)] {
+		return string(w.b)
+	}
+	w.step1b()
+	w.step1c()
+	w.step2()
+	w.step3()
+	w.step4()
+	w.step5()
+	return strings.Replace(string(w.b), "Y", "y", -1)
+}
+
+// porter2Word is a word being stemmed, with the start of its regions R1 and R2.
+type porter2Word struct {
+	b  []byte
+	r1 int
+	r2 int
+}
+
+func isPorter2Vowel(c byte) bool {
+	switch c {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	}
+	return false
+}
+
+// markConsonantY replaces an initial y and a y after a vowel with Y,
+// which is treated as a consonant.
+func (w *porter2Word) markConsonantY() {
+	for i, c := range w.b {
+		if c == 'y' && (i == 0 || isPorter2Vowel(w.b[i-1])) {
+			w.b[i] = 'Y'
+		}
+	}
+}
+
+// findRegions sets R1 to the region after the first non-vowel following a
+// vowel, and R2 to the same region within R1.
+func (w *porter2Word) findRegions() {
+	w.r1 = len(w.b)
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(string(w.b), prefix) {
+			w.r1 = len(prefix)
+		}
+	}
+	if w.r1 == len(w.b) {
+		w.r1 = w.regionAfter(0)
+	}
+	w.r2 = w.regionAfter(w.r1)
+}
+
+func (w *porter2Word) regionAfter(start int) int {
+	for i := start + 1; i < len(w.b); i++ {
+		if !isPorter2Vowel(w.b[i]) && isPorter2Vowel(w.b[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w.b)
+}
+
+func (w *porter2Word) hasSuffix(suffix string) bool {
+	return strings.HasSuffix(string(w.b), suffix)
+}
+
+// longestSuffix returns the longest of the suffixes that the word ends with.
+func (w *porter2Word) longestSuffix(suffixes ...string) string {
+	longest := ""
+	for _, suffix := range suffixes {
+		if len(suffix) > len(longest) && w.hasSuffix(suffix) {
+			longest = suffix
+		}
+	}
+	return longest
+}
+
+// replace replaces the suffix of the word.
+func (w *porter2Word) replace(suffix string, replacement string) {
+	w.b = append(w.b[:len(w.b)-len(suffix)], replacement...)
+}
+
+// inR1 and inR2 check if the suffix is in the region.
+func (w *porter2Word) inR1(suffix string) bool { return len(w.b)-len(suffix) >= w.r1 }
+func (w *porter2Word) inR2(suffix string) bool { return len(w.b)-len(suffix) >= w.r2 }
+
+// containsVowel checks if the word contains a vowel before the suffix.
+func (w *porter2Word) containsVowel(end int) bool {
+	for _, c := range w.b[:end] {
+		if isPorter2Vowel(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// endsShortSyllable checks if the word ends with a short syllable, which is a
+// vowel followed by a non-vowel other than w, x or Y and preceded by a non-vowel,
+// or a vowel at the start of the word followed by a non-vowel.
+func (w *porter2Word) endsShortSyllable() bool {
+	n := len(w.b)
+	if n == 2 {
+		return isPorter2Vowel(w.b[0]) && !isPorter2Vowel(w.b[1])
+	}
+	if n < 3 {
+		return false
+	}
+	c := w.b[n-1]
+	return !isPorter2Vowel(w.b[n-3]) && isPorter2Vowel(w.b[n-2]) &&
+		!isPorter2Vowel(c) && c != 'w' && c != 'x' && c != 'Y'
+}
+
+// isShort checks if the word ends with a short syllable and R1 is empty.
+func (w *porter2Word) isShort() bool {
+	return w.r1 >= len(w.b) && w.endsShortSyllable()
+}
+
+func (w *porter2Word) step0() {
+	if suffix := w.longestSuffix("'", "'s", "'s'"); suffix != "" {
+		w.replace(suffix, "")
+	}
+}
+
+func (w *porter2Word) step1a() {
+	switch suffix := w.longestSuffix("sses", "ied", "ies", "s", "us", "ss"); suffix {
+	case "sses":
+		w.replace(suffix, "ss")
+	case "ied", "ies":
+		if len(w.b) > 4 {
+			w.replace(suffix, "i")
+		} else {
+			w.replace(suffix, "ie")
+		}
+	case "s":
+		// Delete if the word contains a vowel that is not immediately before the s.
+		if len(w.b) > 2 && w.containsVowel(len(w.b)-2) {
+			w.replace(suffix, "")
+		}
+	}
+}
+
+func (w *porter2Word) step1b() {
+	switch suffix := w.longestSuffix("eed", "eedly", "ed", "edly", "ing", "ingly"); suffix {
+	case "eed", "eedly":
+		if w.inR1(suffix) {
+			w.replace(suffix, "ee")
+		}
+	case "ed", "edly", "ing", "ingly":
+		if !w.containsVowel(len(w.b) - len(suffix)) {
+			return
+		}
+		w.replace(suffix, "")
+		switch {
+		case w.hasSuffix("at") || w.hasSuffix("bl") || w.hasSuffix("iz"):
+			w.b = append(w.b, 'e')
+		case w.longestSuffix("bb", "dd", "ff", "gg", "mm", "nn", "pp", "rr", "tt") != "":
+			w.b = w.b[:len(w.b)-1]
+		case w.isShort():
+			w.b = append(w.b, 'e')
+		}
+	}
+}
+
+func (w *porter2Word) step1c() {
+	n := len(w.b)
+	if n > 2 && (w.b[n-1] == 'y' || w.b[n-1] == 'Y') && !isPorter2Vowel(w.b[n-2]) {
+		w.b[n-1] = 'i'
+	}
+}
+
+var porter2Step2 = map[string]string{
+	"tional": "tion", "enci": "ence", "anci": "ance", "abli": "able", "entli": "ent",
+	"izer": "ize", "ization": "ize", "ational": "ate", "ation": "ate", "ator": "ate",
+	"alism": "al", "aliti": "al", "alli": "al", "fulness": "ful", "ousli": "ous",
+	"ousness": "ous", "iveness": "ive", "iviti": "ive", "biliti": "ble", "bli": "ble",
+	"ogi": "og", "fulli": "ful", "lessli": "less", "li": "",
+}
+
+func (w *porter2Word) step2() {
+	suffix := w.longestSuffix(mapKeys(porter2Step2)...)
+	if suffix == "" || !w.inR1(suffix) {
+		return
+	}
+	switch suffix {
+	case "ogi":
+		if !w.hasSuffix("logi") {
+			return
+		}
+	case "li":
+		if len(w.b) < 3 || !strings.ContainsRune("cdeghkmnrt", rune(w.b[len(w.b)-3])) {
+			return
+		}
+	}
+	w.replace(suffix, porter2Step2[suffix])
+}
+
+var porter2Step3 = map[string]string{
+	"tional": "tion", "ational": "ate", "alize": "al", "icate": "ic", "iciti": "ic",
+	"ical": "ic", "ful": "", "ness": "", "ative": "",
+}
+
+func (w *porter2Word) step3() {
+	suffix := w.longestSuffix(mapKeys(porter2Step3)...)
+	if suffix == "" || !w.inR1(suffix) || (suffix == "ative" && !w.inR2(suffix)) {
+		return
+	}
+	w.replace(suffix, porter2Step3[suffix])
+}
+
+var porter2Step4 = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+	"ent", "ism", "ate", "iti", "ous", "ive", "ize", "ion",
+}
+
+func (w *porter2Word) step4() {
+	suffix := w.longestSuffix(porter2Step4...)
+	if suffix == "" || !w.inR2(suffix) {
+		return
+	}
+	if suffix == "ion" {
+		if n := len(w.b) - len(suffix); n == 0 || (w.b[n-1] != 's' && w.b[n-1] != 't') {
+			return
+		}
+	}
+	w.replace(suffix, "")
+}
+
+func (w *porter2Word) step5() {
+	switch {
+	case w.hasSuffix("e"):
+		if w.inR2("e") {
+			w.replace("e", "")
+		} else if w.inR1("e") {
+			w.b = w.b[:len(w.b)-1]
+			if w.endsShortSyllable() {
+				w.b = append(w.b, 'e')
+			}
+		}
+	case w.hasSuffix("ll"):
+		if w.inR2("l") {
+			w.replace("l", "")
+		}
+	}
+}
+
+// mapKeys returns the keys of the map.
+func mapKeys(m map[string]string) (keys []string) {
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return
+}
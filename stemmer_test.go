@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPorter2Stemmer_Stem(t *testing.T) {
+	pairs := []struct {
+		word string
+		stem string
+	}{
+		{"a", "a"},
+		{"abandoned", "abandon"},
+		{"abate", "abat"},
+		{"abilities", "abil"},
+		{"able", "abl"},
+		{"abstraction", "abstract"},
+		{"abundance", "abund"},
+		{"academy", "academi"},
+		{"acceptable", "accept"},
+		{"accidentally", "accident"},
+		{"accompanied", "accompani"},
+		{"accordingly", "accord"},
+		{"activity", "activ"},
+		{"admirable", "admir"},
+		{"caresses", "caress"},
+		{"ponies", "poni"},
+		{"ties", "tie"},
+		{"cries", "cri"},
+		{"gaps", "gap"},
+		{"gas", "gas"},
+		{"hopping", "hop"},
+		{"hoping", "hope"},
+		{"luxuriating", "luxuri"},
+		{"generously", "generous"},
+		{"communism", "communism"},
+		{"consignment", "consign"},
+		{"knackeries", "knackeri"},
+		{"skies", "sky"},
+		{"dying", "die"},
+		{"succeeding", "succeed"},
+		{"inning", "inning"},
+		{"cohen's", "cohen"},
+		{"sayings", "say"},
+		{"analysis", "analysi"},
+		{"analyzing", "analyz"},
+		{"relationships", "relationship"},
+		{"statistical", "statist"},
+		{"statistics", "statist"},
+	}
+	for _, pair := range pairs {
+		if stem := (Porter2Stemmer{}).Stem(pair.word); stem != pair.stem {
+			t.Errorf("Wrong stem for %q: Got %q, Wanted %q.", pair.word, stem, pair.stem)
+		}
+	}
+}
+
+func TestEnglishLemmas(t *testing.T) {
+	stemmer := Porter2Stemmer{}
+	for word, lemma := range EnglishLemmas {
+		if !strings.HasSuffix(lemma, "yze") {
+			t.Errorf("Wrong lemma for %q: Got %q, Wanted a verb in -yze.", word, lemma)
+			continue
+		}
+		// The lemma must be stemmed like the American forms of the verb.
+		stem := stemmer.Stem(lemma)
+		for _, form := range []string{lemma + "s", lemma + "d", strings.TrimSuffix(lemma, "e") + "ing", lemma + "r"} {
+			if formStem := stemmer.Stem(form); formStem != stem {
+				t.Errorf("Wrong stem for %q: Got %q, Wanted %q of %q.", form, formStem, stem, lemma)
+			}
+		}
+		if stemmer.Stem(word) == stem {
+			t.Errorf("Unneeded lemma for %q, which is already stemmed to %q.", word, stem)
+		}
+	}
+}
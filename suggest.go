@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	// spellingEditPenalty is subtracted from the score of a correction
+	// for each edit, so a correction one edit away must be about twenty
+	// times as frequent as the term that was typed to replace it.
+	spellingEditPenalty = 3.0
+	// spellingCooccurrenceWeight weighs the number of documents where a
+	// correction appears with the other query terms, which favors corrections
+	// that make sense in the context of the query.
+	spellingCooccurrenceWeight = 2.0
+)
+
+// Suggest returns the query with misspelled words replaced by terms of the
+// k-gram index that are within the edit distance of getFuzziness.
+// Corrections are chosen by their edit distance, document frequency and
+// the number of documents where they appear together with the other query terms.
+// Returns false if no word of the query was corrected.
+func (s *Searcher) Suggest(query string) (suggestion string, ok bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	tokens := s.analyzer.Analyze(query)
+	// terms are the index terms of the tokens after correction,
+	// or empty for tokens that are not in the index.
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		if s.ii.DocumentFrequency(token.Text) > 0 {
+			terms[i] = token.Text
+		}
+	}
+
+	var b strings.Builder
+	prev := 0
+	for i, token := range tokens {
+		surface := s.analyzer.Normalize(query[token.Start:token.End])
+		if strings.HasPrefix(query[token.End:], ":") && isDocumentField(surface) {
+			// Field names are not corrected.
+			continue
+		}
+		if token.Start < prev {
+			// Overlapping tokens, like CJK bigrams, cannot be replaced separately.
+			continue
+		}
+		var others []string
+		for j, term := range terms {
+			if j != i && term != "" {
+				others = append(others, term)
+			}
+		}
+		best, bestTerm, bestScore := surface, token.Text, math.Inf(-1)
+		if terms[i] != "" {
+			bestScore = s.spellingScore(terms[i], 0, others)
+		}
+		for _, candidate := range s.ki.GetCloseTerms(surface, getFuzziness(surface)) {
+			candidateTerms := s.terms(candidate)
+			if len(candidateTerms) != 1 {
+				continue
+			}
+			score := s.spellingScore(candidateTerms[0], editDistance(surface, candidate), others)
+			if score > bestScore || (score == bestScore && candidate < best) {
+				best, bestTerm, bestScore = candidate, candidateTerms[0], score
+			}
+		}
+		// Surface forms of the same term would not change the results.
+		if bestTerm != token.Text {
+			b.WriteString(query[prev:token.Start])
+			b.WriteString(best)
+			prev = token.End
+			terms[i] = bestTerm
+			ok = true
+		}
+	}
+	if !ok {
+		return "", false
+	}
+	b.WriteString(query[prev:])
+	return b.String(), true
+}
+
+// spellingScore returns the score of a term as the correction of a word
+// that is the given edit distance away, where others are the other query terms.
+func (s *Searcher) spellingScore(term string, distance int, others []string) float64 {
+	docFreq := s.ii.DocumentFrequency(term)
+	cooccurrences := docFreq
+	if len(others) > 0 {
+		cooccurrences = len(s.ii.Intersect(append(others, term)))
+	}
+	return math.Log(float64(docFreq+1)) + spellingCooccurrenceWeight*math.Log(float64(cooccurrences+1)) - spellingEditPenalty*float64(distance)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSearcher_Suggest(t *testing.T) {
+	pairs := []struct {
+		query      string
+		suggestion string
+	}{
+		{"statistc", "statistic"},
+		{"Latent semantc analysis", "Latent semantic analysis"},
+		{"title:kapa coeficient", "title:kappa coefficient"},
+		{`"cohen's kapa"`, `"cohen's kappa"`},
+		{"kappa", ""},
+		{"statistics", ""},
+		{"xyzzy", ""},
+	}
+	s := SetUpSearcher()
+	for _, pair := range pairs {
+		suggestion, ok := s.Suggest(pair.query)
+		if suggestion != pair.suggestion || ok != (pair.suggestion != "") {
+			t.Errorf("Wrong suggestion for %q: Got %q (%t), Wanted %q.", pair.query, suggestion, ok, pair.suggestion)
+		}
+	}
+}
+
+func TestSearcher_SuggestCooccurrence(t *testing.T) {
+	store := &memoryStorage{}
+	s := NewSearcher(3, store)
+	for i, body := range []string{"cat food", "car engine", "car engine", "car wheels"} {
+		doc := Document{id: i + 1, Body: body}
+		store.put(doc)
+		if err := s.AddDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pairs := []struct {
+		query      string
+		suggestion string
+	}{
+		// The more frequent term is chosen without other terms.
+		{"cax", "car"},
+		// The term that appears with the other terms is chosen.
+		{"cax food", "cat food"},
+		{"cax engine", "car engine"},
+		{"engien cax", "engine car"},
+	}
+	for _, pair := range pairs {
+		if suggestion, _ := s.Suggest(pair.query); suggestion != pair.suggestion {
+			t.Errorf("Wrong suggestion for %q: Got %q, Wanted %q.", pair.query, suggestion, pair.suggestion)
+		}
+	}
+}
+
+func TestSearcher_QueryHandlerSuggestion(t *testing.T) {
+	pairs := []struct {
+		rawQuery string
+		link     string
+	}{
+		{"q=statistc", `Did you mean: <a href="/?q=statistic">`},
+		{"q=statistc&alg=Terms", `Did you mean: <a href="/?alg=Terms&amp;q=statistic">`},
+		{"q=statistc&alg=Phrase", ""},
+		{"q=kappa", ""},
+	}
+	s := SetUpSearcher()
+	for _, pair := range pairs {
+		w := httptest.NewRecorder()
+		s.queryHandler(w, httptest.NewRequest(http.MethodGet, "/?"+pair.rawQuery, nil))
+		body := w.Body.String()
+		if pair.link == "" && strings.Contains(body, "Did you mean") {
+			t.Errorf("Wrong suggestion for %q: Got a suggestion, Wanted none.", pair.rawQuery)
+		} else if !strings.Contains(body, pair.link) {
+			t.Errorf("Wrong suggestion for %q: Got no link, Wanted %s.", pair.rawQuery, pair.link)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// BM25TopK returns the k documents with the highest BM25 scores, sorted like
+// the results of BM25Query, which they are identical to.
+// Documents are scored one at a time with Block-Max WAND, which skips the
+// documents whose upper bound of the score cannot reach the top k.
+// (Reference) Ding, S., & Suel, T. (2011). Faster top-k document retrieval using block-max indexes.
+func (s *Searcher) BM25TopK(query string, k int) (resList *ScoringList) {
+	resList, _ = s.bm25TopK(query, k)
+	return
+}
+
+// wandSlack is the relative error that is allowed in upper bounds, so that
+// rounding in the sums of scores never prunes a document of the top k.
+const wandSlack = 1e-9
+
+// bm25TopK returns the top k documents and the number of documents that were scored.
+func (s *Searcher) bm25TopK(query string, k int) (resList *ScoringList, scored int) {
+	resList = &ScoringList{}
+	if k < 1 {
+		return
+	}
+	var cursors []*bm25Cursor
+	for _, clause := range parseClauses(query, s.analyzer) {
+		if c := s.newBM25Cursor(clause); c.it.Next() {
+			cursors = append(cursors, c)
+		}
+	}
+	// Clause scores are summed in query order like in bm25Scores,
+	// so that the scores are equal to the last bit.
+	byClause := append([]*bm25Cursor{}, cursors...)
+
+	top := &scoreHeap{}
+	// A document must score above the threshold to enter the full heap.
+	threshold := math.Inf(-1)
+	for {
+		sort.Slice(cursors, func(i, j int) bool { return cursors[i].it.DocID() < cursors[j].it.DocID() })
+		for len(cursors) > 0 && cursors[len(cursors)-1].it.DocID() == noMoreDocs {
+			cursors = cursors[:len(cursors)-1]
+		}
+		// The pivot is the first document whose upper bound can reach the threshold.
+		pivot, bound := -1, 0.0
+		for i, c := range cursors {
+			bound += c.maxScore
+			if canReach(bound, threshold) {
+				pivot = i
+				break
+			}
+		}
+		if pivot == -1 {
+			break
+		}
+		pivotDoc := cursors[pivot].it.DocID()
+		if cursors[0].it.DocID() != pivotDoc {
+			// Skip the documents before the pivot in the cursor with the largest upper bound
+			// of those that are before it. Cursors on the pivot may precede it on ties.
+			best := cursors[0]
+			for _, c := range cursors[1:pivot] {
+				if c.it.DocID() != pivotDoc && c.maxScore > best.maxScore {
+					best = c
+				}
+			}
+			best.it.Advance(pivotDoc)
+			continue
+		}
+
+		// All cursors up to the pivot are on the pivot document.
+		matched := pivot + 1
+		for matched < len(cursors) && cursors[matched].it.DocID() == pivotDoc {
+			matched++
+		}
+		blockBound, next := 0.0, noMoreDocs
+		for _, c := range cursors[:matched] {
+			score, lastDocID := c.blockMax(pivotDoc)
+			blockBound += score
+			next = min(next, lastDocID)
+		}
+		if !canReach(blockBound, threshold) {
+			// No document can reach the threshold until a block ends
+			// or another cursor joins.
+			if next != noMoreDocs {
+				next++
+			}
+			if matched < len(cursors) {
+				next = min(next, cursors[matched].it.DocID())
+			}
+			for _, c := range cursors[:matched] {
+				c.it.Advance(next)
+			}
+			continue
+		}
+
+		scored++
+		score := 0.0
+		for _, c := range byClause {
+			if c.it.DocID() == pivotDoc {
+				score += c.score(s, pivotDoc)
+			}
+		}
+		if top.Len() < k {
+			heap.Push(top, scoredDoc{pivotDoc, score})
+		} else if score > (*top)[0].score {
+			(*top)[0] = scoredDoc{pivotDoc, score}
+			heap.Fix(top, 0)
+		}
+		if top.Len() == k {
+			threshold = (*top)[0].score
+		}
+		for _, c := range cursors[:matched] {
+			c.it.Next()
+		}
+	}
+
+	for top.Len() > 0 {
+		doc := heap.Pop(top).(scoredDoc)
+		resList.ids = append(resList.ids, doc.docID)
+		resList.scores = append(resList.scores, doc.score)
+	}
+	for i, j := 0, len(resList.ids)-1; i < j; i, j = i+1, j-1 {
+		resList.Swap(i, j)
+	}
+	return
+}
+
+// canReach checks if an upper bound of a score can be above the threshold.
+func canReach(bound float64, threshold float64) bool {
+	return bound+wandSlack*math.Abs(bound) > threshold
+}
+
+// bm25Cursor iterates over the documents that match a clause of a BM25 query.
+type bm25Cursor struct {
+	it  DocIterator
+	idf float64
+	// lengths are the lengths of the field of the clause.
+	lengths *DocumentLengths
+	// freq returns the frequency of the clause in the current document.
+	freq func() int
+	// maxScore is an upper bound of the score of the clause in any document.
+	maxScore float64
+	// postings is the iterator of a single term, which has block maximums.
+	postings *PostingsIterator
+}
+
+// newBM25Cursor returns the cursor of the clause. Phrases are matched in advance
+// to find their document frequency, single terms are iterated lazily.
+func (s *Searcher) newBM25Cursor(clause queryClause) *bm25Cursor {
+	index, lengths := s.index(clause.field), s.lengths(clause.field)
+	if len(clause.tokens) == 1 {
+		it := index.Iterator(clause.tokens[0])
+		idf := s.inverseDocumentFrequency(it.p.Len())
+		return &bm25Cursor{it: it, idf: idf, lengths: lengths, freq: it.Freq, maxScore: bm25Bound(it.p.maxFreq(), idf), postings: it}
+	}
+	docIDs, freqs := index.PhrasePostings(clause.tokens)
+	idf := s.inverseDocumentFrequency(len(docIDs))
+	it := newSliceIterator(docIDs)
+	maxFreq := 0
+	for _, freq := range freqs {
+		maxFreq = max(maxFreq, freq)
+	}
+	return &bm25Cursor{it: it, idf: idf, lengths: lengths, freq: func() int { return freqs[it.idx] }, maxScore: bm25Bound(maxFreq, idf)}
+}
+
+// score returns the BM25 score of the clause in the current document.
+func (c *bm25Cursor) score(s *Searcher, docID int) float64 {
+	return s.bm25(float64(c.freq()), c.idf, c.lengths, docID)
+}
+
+// blockMax returns an upper bound of the score of the clause in the block
+// that contains the document, and the last document of that block.
+func (c *bm25Cursor) blockMax(docID int) (float64, int) {
+	if c.postings == nil {
+		return c.maxScore, noMoreDocs
+	}
+	maxFreq, lastDocID := c.postings.blockMax(docID)
+	return bm25Bound(maxFreq, c.idf), lastDocID
+}
+
+// bm25Bound returns an upper bound of the BM25 score of a term with at most the
+// given frequency. The score increases with the frequency and decreases with
+// the length of the document, so the bound is the score in a document of length 0.
+// It does not depend on the average length, so it is still valid after documents are added.
+func bm25Bound(maxFreq int, idf float64) float64 {
+	tf := float64(maxFreq)
+	bound := idf * (bm25K1 + 1) * tf / (bm25K1*(1-bm25B) + tf)
+	// A negative idf scores the highest in long documents, which approach 0.
+	return math.Max(bound, 0)
+}
+
+// scoredDoc is a document and its score in scoreHeap.
+type scoredDoc struct {
+	docID int
+	score float64
+}
+
+// scoreHeap is a min-heap of documents where the lowest ranked document is on top.
+// Implements heap.Interface.
+type scoreHeap []scoredDoc
+
+func (h scoreHeap) Len() int      { return len(h) }
+func (h scoreHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h scoreHeap) Less(i, j int) bool {
+	// The later document ranks lower on ties, like in ScoringList.
+	return h[i].score < h[j].score || (h[i].score == h[j].score && h[i].docID > h[j].docID)
+}
+func (h *scoreHeap) Push(x interface{}) { *h = append(*h, x.(scoredDoc)) }
+func (h *scoreHeap) Pop() interface{} {
+	old := *h
+	doc := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return doc
+}
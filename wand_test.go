@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// exhaustiveTopK returns the top k documents of the exhaustive BM25 scorer.
+func exhaustiveTopK(s *Searcher, query string, k int) *ScoringList {
+	resList := s.bm25Scores(query)
+	sort.Sort(resList)
+	k = min(k, resList.Len())
+	return &ScoringList{ids: resList.ids[:k], scores: resList.scores[:k]}
+}
+
+func TestSearcher_BM25TopK(t *testing.T) {
+	pairs := []struct {
+		s       *Searcher
+		queries []string
+	}{
+		{SetUpSearcher(), []string{"kappa", "statistic language", "is a statistic", `"multiple access" kappa`, "title:kappa is", "nothing"}},
+		{SetUpCorpusSearcher(benchmarkCorpus()[:5000]), []string{
+			"t0 t400", "t3 t20 t150", `"t1 t2" t30`, "title:t5 t60", "t7 t7 t90", "t0 t1 t2 t3", "t100000", "",
+		}},
+	}
+	for _, pair := range pairs {
+		for _, query := range pair.queries {
+			for _, k := range []int{1, 5, 10, 100} {
+				want := exhaustiveTopK(pair.s, query, k)
+				if res := pair.s.BM25TopK(query, k); !reflect.DeepEqual(res.ids, want.ids) || !reflect.DeepEqual(res.scores, want.scores) {
+					t.Errorf("Wrong top %d of %q: Got %v %v, Wanted %v %v.", k, query, res.ids, res.scores, want.ids, want.scores)
+				}
+			}
+		}
+	}
+}
+
+func TestSearcher_BM25TopKSkips(t *testing.T) {
+	s := SetUpCorpusSearcher(benchmarkCorpus()[:20000])
+	// A common and a rare term, where documents with only the common term
+	// cannot reach the top once it is filled with the rare term.
+	query := "t30 t300"
+	_, scored := s.bm25TopK(query, 10)
+	if matches := s.bm25Scores(query).Len(); scored*5 > matches {
+		t.Errorf("Too many scored documents: Got %d, Wanted at most a fifth of %d.", scored, matches)
+	}
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// TermStats are the statistics of a query term (or phrase) in a document
+// that a TermWeighter weighs the term with.
+type TermStats struct {
+	// Freq is the frequency of the term in the document.
+	Freq float64
+	// DocLength is the length of the document, and AvgDocLength
+	// is the average length of all documents.
+	DocLength    float64
+	AvgDocLength float64
+	// DocFreq is the number of documents that contain the term, and IDF
+	// is the inverse document frequency log10(DocCount / DocFreq).
+	DocFreq int
+	IDF     float64
+	// CollectionFreq is the number of times the term appears in all documents.
+	CollectionFreq int
+	// DocCount is the number of documents.
+	DocCount int
+}
+
+// TermWeighter weighs a term in a document. The score of a document is the
+// sum of the weights of the query terms that it contains.
+type TermWeighter interface {
+	Weight(stats TermStats) float64
+	// WithParams returns a copy of the weighter with some of its parameters
+	// replaced. Returns an error if a parameter is unknown or out of range.
+	WithParams(params map[string]float64) (TermWeighter, error)
+}
+
+// BM25Weighter is the Okapi BM25 weight.
+type BM25Weighter struct {
+	K1 float64
+	B  float64
+}
+
+// DefaultBM25Weighter is the weight of BM25Query.
+var DefaultBM25Weighter = BM25Weighter{K1: bm25K1, B: bm25B}
+
+func (w BM25Weighter) Weight(stats TermStats) float64 {
+	k1, b, tf := w.K1, w.B, stats.Freq
+	return stats.IDF * (k1 + 1) * tf / (k1*((1-b)+b*(stats.DocLength/stats.AvgDocLength)) + tf)
+}
+
+func (w BM25Weighter) WithParams(params map[string]float64) (TermWeighter, error) {
+	err := setParams(params, map[string]weightParam{"k1": {&w.K1, 0, math.Inf(1)}, "b": {&w.B, 0, 1}})
+	return w, err
+}
+
+func (w BM25Weighter) explainWeight(stats TermStats) (string, []Explanation) {
+	return "idf * (k1 + 1) * tf / (k1 * norm + tf)", []Explanation{
+		explainIDF(stats), explainValue(stats.Freq, "tf, frequency of the term in the document"),
+		explainValue(w.K1, "k1"), explainLengthNorm(w.B, stats),
+	}
+}
+
+// BM25PlusWeighter is BM25 with a lower bound Delta on the weight of a term
+// that is in the document, so that very long documents are not penalized
+// below the documents that do not contain the term.
+// (Reference) Lv, Y., & Zhai, C. (2011). Lower-bounding term frequency normalization.
+type BM25PlusWeighter struct {
+	K1    float64
+	B     float64
+	Delta float64
+}
+
+func (w BM25PlusWeighter) Weight(stats TermStats) float64 {
+	k1, b, tf := w.K1, w.B, stats.Freq
+	return stats.IDF * ((k1+1)*tf/(k1*((1-b)+b*(stats.DocLength/stats.AvgDocLength))+tf) + w.Delta)
+}
+
+func (w BM25PlusWeighter) WithParams(params map[string]float64) (TermWeighter, error) {
+	err := setParams(params, map[string]weightParam{
+		"k1": {&w.K1, 0, math.Inf(1)}, "b": {&w.B, 0, 1}, "delta": {&w.Delta, 0, math.Inf(1)},
+	})
+	return w, err
+}
+
+func (w BM25PlusWeighter) explainWeight(stats TermStats) (string, []Explanation) {
+	return "idf * ((k1 + 1) * tf / (k1 * norm + tf) + delta)", []Explanation{
+		explainIDF(stats), explainValue(stats.Freq, "tf, frequency of the term in the document"),
+		explainValue(w.K1, "k1"), explainLengthNorm(w.B, stats), explainValue(w.Delta, "delta"),
+	}
+}
+
+// BM25LWeighter is BM25 where Delta is added to the length normalized
+// term frequency before saturation, which favors long documents less
+// than BM25 penalizes them.
+// (Reference) Lv, Y., & Zhai, C. (2011). When documents are very long, BM25 fails!
+type BM25LWeighter struct {
+	K1    float64
+	B     float64
+	Delta float64
+}
+
+func (w BM25LWeighter) Weight(stats TermStats) float64 {
+	c := stats.Freq / ((1 - w.B) + w.B*(stats.DocLength/stats.AvgDocLength))
+	return stats.IDF * (w.K1 + 1) * (c + w.Delta) / (w.K1 + c + w.Delta)
+}
+
+func (w BM25LWeighter) WithParams(params map[string]float64) (TermWeighter, error) {
+	err := setParams(params, map[string]weightParam{
+		"k1": {&w.K1, 0, math.Inf(1)}, "b": {&w.B, 0, 1}, "delta": {&w.Delta, 0, math.Inf(1)},
+	})
+	return w, err
+}
+
+func (w BM25LWeighter) explainWeight(stats TermStats) (string, []Explanation) {
+	return "idf * (k1 + 1) * (tf / norm + delta) / (k1 + tf / norm + delta)", []Explanation{
+		explainIDF(stats), explainValue(stats.Freq, "tf, frequency of the term in the document"),
+		explainValue(w.K1, "k1"), explainLengthNorm(w.B, stats), explainValue(w.Delta, "delta"),
+	}
+}
+
+// PL2Weighter is the divergence from randomness model with a Poisson model
+// of randomness, the Laplace after effect and normalization 2 of the term frequency.
+// (Reference) Amati, G., & Van Rijsbergen, C. J. (2002). Probabilistic models of information retrieval based on measuring the divergence from randomness.
+type PL2Weighter struct {
+	// C is the strength of normalization 2, where larger values normalize less.
+	C float64
+}
+
+func (w PL2Weighter) Weight(stats TermStats) float64 {
+	tfn := normalizedFreq(stats, w.C)
+	// lambda is the mean frequency of the term in a document.
+	lambda := float64(stats.CollectionFreq) / float64(stats.DocCount)
+	return (tfn*math.Log2(tfn/lambda) + (lambda-tfn)*math.Log2E + 0.5*math.Log2(2*math.Pi*tfn)) / (tfn + 1)
+}
+
+func (w PL2Weighter) WithParams(params map[string]float64) (TermWeighter, error) {
+	err := setParams(params, map[string]weightParam{"c": {&w.C, math.SmallestNonzeroFloat64, math.Inf(1)}})
+	return w, err
+}
+
+func (w PL2Weighter) explainWeight(stats TermStats) (string, []Explanation) {
+	lambda := Explanation{
+		Value:       float64(stats.CollectionFreq) / float64(stats.DocCount),
+		Description: "lambda, cf / N, mean frequency of the term in a document",
+		Details: []Explanation{
+			explainValue(float64(stats.CollectionFreq), "cf, frequency of the term in all documents"),
+			explainValue(float64(stats.DocCount), "N, number of documents"),
+		},
+	}
+	return "(tfn * log2(tfn / lambda) + (lambda - tfn) * log2(e) + 0.5 * log2(2 * pi * tfn)) / (tfn + 1)",
+		[]Explanation{explainNormalizedFreq(stats, w.C), lambda}
+}
+
+// InL2Weighter is the divergence from randomness model with the inverse
+// document frequency as the model of randomness, the Laplace after effect
+// and normalization 2 of the term frequency.
+type InL2Weighter struct {
+	// C is the strength of normalization 2, where larger values normalize less.
+	C float64
+}
+
+func (w InL2Weighter) Weight(stats TermStats) float64 {
+	tfn := normalizedFreq(stats, w.C)
+	return tfn / (tfn + 1) * math.Log2(float64(stats.DocCount+1)/(float64(stats.DocFreq)+0.5))
+}
+
+func (w InL2Weighter) WithParams(params map[string]float64) (TermWeighter, error) {
+	err := setParams(params, map[string]weightParam{"c": {&w.C, math.SmallestNonzeroFloat64, math.Inf(1)}})
+	return w, err
+}
+
+func (w InL2Weighter) explainWeight(stats TermStats) (string, []Explanation) {
+	return "tfn / (tfn + 1) * log2((N + 1) / (df + 0.5))", []Explanation{
+		explainNormalizedFreq(stats, w.C),
+		explainValue(float64(stats.DocCount), "N, number of documents"),
+		explainValue(float64(stats.DocFreq), "df, number of documents with the term"),
+	}
+}
+
+// normalizedFreq returns the term frequency normalized to the average
+// document length with normalization 2 of the divergence from randomness models.
+func normalizedFreq(stats TermStats, c float64) float64 {
+	return stats.Freq * math.Log2(1+c*stats.AvgDocLength/math.Max(stats.DocLength, 1))
+}
+
+func explainNormalizedFreq(stats TermStats, c float64) Explanation {
+	return Explanation{
+		Value:       normalizedFreq(stats, c),
+		Description: "tfn, tf * log2(1 + c * avgdl / dl), normalization 2 of the term frequency",
+		Details: []Explanation{
+			explainValue(stats.Freq, "tf, frequency of the term in the document"), explainValue(c, "c"),
+			explainValue(stats.DocLength, "dl, length of the document"),
+			explainValue(stats.AvgDocLength, "avgdl, average length of documents"),
+		},
+	}
+}
+
+// DPHWeighter is the parameter free divergence from randomness model
+// with a hypergeometric model of randomness and Popper's normalization.
+// (Reference) Amati, G. (2006). Frequentist and Bayesian approach to information retrieval.
+type DPHWeighter struct{}
+
+func (DPHWeighter) Weight(stats TermStats) float64 {
+	tf, docLength := stats.Freq, math.Max(stats.DocLength, 1)
+	f := tf / docLength
+	if f >= 1 {
+		// A document of only the term is not informative.
+		return 0
+	}
+	norm := (1 - f) * (1 - f) / (tf + 1)
+	return norm * (tf*math.Log2(tf*stats.AvgDocLength/docLength*float64(stats.DocCount)/float64(stats.CollectionFreq)) +
+		0.5*math.Log2(2*math.Pi*tf*(1-f)))
+}
+
+func (w DPHWeighter) WithParams(params map[string]float64) (TermWeighter, error) {
+	return w, setParams(params, nil)
+}
+
+func (DPHWeighter) explainWeight(stats TermStats) (string, []Explanation) {
+	tf, docLength := stats.Freq, math.Max(stats.DocLength, 1)
+	f := Explanation{
+		Value:       tf / docLength,
+		Description: "f, tf / dl, relative frequency of the term in the document",
+		Details: []Explanation{
+			explainValue(tf, "tf, frequency of the term in the document"),
+			explainValue(docLength, "dl, length of the document"),
+		},
+	}
+	norm := Explanation{
+		Value:       (1 - f.Value) * (1 - f.Value) / (tf + 1),
+		Description: "norm, (1 - f)^2 / (tf + 1), Popper's normalization",
+		Details:     []Explanation{f},
+	}
+	return "norm * (tf * log2(tf * avgdl / dl * N / cf) + 0.5 * log2(2 * pi * tf * (1 - f)))", []Explanation{
+		norm, explainValue(stats.AvgDocLength, "avgdl, average length of documents"),
+		explainValue(float64(stats.DocCount), "N, number of documents"),
+		explainValue(float64(stats.CollectionFreq), "cf, frequency of the term in all documents"),
+	}
+}
+
+// weightExplainer is implemented by the TermWeighters that explain
+// their weights with their formula and the values it is computed from.
+type weightExplainer interface {
+	explainWeight(stats TermStats) (formula string, details []Explanation)
+}
+
+// explainWeight explains the weight of a term in a document. Weighters
+// that do not explain their weights are explained by the TermStats.
+func explainWeight(w TermWeighter, stats TermStats, description string) Explanation {
+	e := Explanation{Value: w.Weight(stats), Description: description}
+	if explainer, ok := w.(weightExplainer); ok {
+		formula, details := explainer.explainWeight(stats)
+		e.Description += ", computed as " + formula + " from:"
+		e.Details = details
+		return e
+	}
+	e.Description += ", computed from:"
+	e.Details = []Explanation{
+		explainValue(stats.Freq, "tf, frequency of the term in the document"),
+		explainValue(stats.DocLength, "dl, length of the document"),
+		explainValue(stats.AvgDocLength, "avgdl, average length of documents"),
+		explainValue(float64(stats.DocFreq), "df, number of documents with the term"),
+		explainValue(stats.IDF, "idf, inverse document frequency"),
+		explainValue(float64(stats.CollectionFreq), "cf, frequency of the term in all documents"),
+		explainValue(float64(stats.DocCount), "N, number of documents"),
+	}
+	return e
+}
+
+func explainIDF(stats TermStats) Explanation {
+	return Explanation{
+		Value:       stats.IDF,
+		Description: "idf, log10(N / df), inverse document frequency",
+		Details: []Explanation{
+			explainValue(float64(stats.DocCount), "N, number of documents"),
+			explainValue(float64(stats.DocFreq), "df, number of documents with the term"),
+		},
+	}
+}
+
+// explainLengthNorm explains the length normalization of BM25 and its variants.
+func explainLengthNorm(b float64, stats TermStats) Explanation {
+	return Explanation{
+		Value:       (1 - b) + b*(stats.DocLength/stats.AvgDocLength),
+		Description: "norm, (1 - b) + b * dl / avgdl, length normalization",
+		Details: []Explanation{
+			explainValue(b, "b"), explainValue(stats.DocLength, "dl, length of the document"),
+			explainValue(stats.AvgDocLength, "avgdl, average length of documents"),
+		},
+	}
+}
+
+// weightParam is a parameter of a TermWeighter and its range of values.
+type weightParam struct {
+	value    *float64
+	min, max float64
+}
+
+// setParams sets the parameters of a TermWeighter to the given values.
+func setParams(values map[string]float64, params map[string]weightParam) error {
+	for name, value := range values {
+		param, ok := params[name]
+		if !ok {
+			return fmt.Errorf("unknown parameter %q", name)
+		}
+		if !(value >= param.min && value <= param.max) {
+			return fmt.Errorf("parameter %s=%v is out of range", name, value)
+		}
+		*param.value = value
+	}
+	return nil
+}
+
+// weightParamNames are the request parameters that override the parameters
+// of the TermWeighter of a search algorithm.
+var weightParamNames = []string{"k1", "b", "delta", "c"}
+
+// parseWeightParams returns the parameters of weightParamNames in the request.
+func parseWeightParams(values url.Values) (map[string]float64, error) {
+	params := make(map[string]float64)
+	for _, name := range weightParamNames {
+		if value := values.Get(name); value != "" {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parameter %s=%q is not a number", name, value)
+			}
+			params[name] = v
+		}
+	}
+	return params, nil
+}
+
+// termWeighters maps the names of search algorithms that sum the weights of
+// the query terms to their TermWeighter with the default parameters.
+var termWeighters = map[string]TermWeighter{
+	"BM25":     DefaultBM25Weighter,
+	"BM25+":    BM25PlusWeighter{K1: bm25K1, B: bm25B, Delta: 1},
+	"BM25L":    BM25LWeighter{K1: bm25K1, B: bm25B, Delta: 0.5},
+	"DFR PL2":  PL2Weighter{C: 1},
+	"DFR InL2": InL2Weighter{C: 1},
+	"DPH":      DPHWeighter{},
+}
+
+// termWeighter returns the TermWeighter of the search algorithm with the given
+// parameters replaced. Returns an error if the algorithm does not weigh terms.
+func termWeighter(funcName string, params map[string]float64) (TermWeighter, error) {
+	w, ok := termWeighters[funcName]
+	if !ok {
+		return nil, fmt.Errorf("algorithm %q has no parameters", funcName)
+	}
+	return w.WithParams(params)
+}
+
+// WeightedQuery returns a ranked list of results scored by the sum of the
+// weights of the query terms in each document. Quoted phrases and words
+// restricted to a field, e.g. "title:kappa", are supported.
+func (s *Searcher) WeightedQuery(query string, w TermWeighter) (results []int) {
+	resList := s.weightedScores(query, w)
+	sort.Sort(resList)
+	results = resList.ids
+	return
+}
+
+// weightedQuery returns the queryFunc of WeightedQuery with the TermWeighter.
+func (s *Searcher) weightedQuery(w TermWeighter) queryFunc {
+	return func(query string) []int { return s.WeightedQuery(query, w) }
+}
+
+// weightedScoringFunc returns the scoringFunc of WeightedQuery with the TermWeighter.
+func (s *Searcher) weightedScoringFunc(w TermWeighter) scoringFunc {
+	return func(query string) *ScoringList { return s.weightedScores(query, w) }
+}
+
+// weightedExplainFunc returns the explainFunc of WeightedQuery with the TermWeighter.
+func (s *Searcher) weightedExplainFunc(w TermWeighter) explainFunc {
+	return func(query string, docID int) *Explanation { return s.explainWeighted(query, docID, w) }
+}
+
+// weightedScores returns the unsorted scores of WeightedQuery.
+// Quoted phrases are weighed as a single term and words restricted to
+// a field are weighed with the index of that field.
+func (s *Searcher) weightedScores(query string, w TermWeighter) (resList *ScoringList) {
+	resList = &ScoringList{}
+	for _, clause := range parseClauses(query, s.analyzer) {
+		docIDs, freqs := s.index(clause.field).PhrasePostings(clause.tokens)
+		stats, lengths := s.termStats(clause.field, docIDs, freqs), s.lengths(clause.field)
+		for i, docID := range docIDs {
+			stats.Freq, stats.DocLength = float64(freqs[i]), float64(lengths.docLength(docID))
+			resList.add(docID, w.Weight(stats))
+		}
+	}
+	return
+}
+
+// explainWeighted explains the score of a document in WeightedQuery
+// as the sum of the weights of the clauses that it matches.
+func (s *Searcher) explainWeighted(query string, docID int, w TermWeighter) *Explanation {
+	var details []Explanation
+	for _, clause := range parseClauses(query, s.analyzer) {
+		docIDs, freqs := s.index(clause.field).PhrasePostings(clause.tokens)
+		i := sort.SearchInts(docIDs, docID)
+		if i == len(docIDs) || docIDs[i] != docID {
+			continue
+		}
+		stats := s.termStats(clause.field, docIDs, freqs)
+		stats.Freq, stats.DocLength = float64(freqs[i]), float64(s.lengths(clause.field).docLength(docID))
+		details = append(details, explainWeight(w, stats, "weight of "+clauseString(clause)))
+	}
+	return explainSum(details)
+}
+
+// termStats returns the statistics of a term with the given postings in
+// the index of the field that are the same in all documents. Lengths are
+// those of the field, or of the Body if field is empty.
+func (s *Searcher) termStats(field string, docIDs []int, freqs []int) TermStats {
+	stats := TermStats{
+		AvgDocLength: s.lengths(field).averageDocumentLength(),
+		DocFreq:      len(docIDs),
+		IDF:          s.inverseDocumentFrequency(len(docIDs)),
+		DocCount:     s.docLen.documentCount(),
+	}
+	for _, freq := range freqs {
+		stats.CollectionFreq += freq
+	}
+	return stats
+}
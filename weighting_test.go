@@ -0,0 +1,134 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// weightingStats are the statistics of a term that appears three times
+// in a document of half the average length.
+var weightingStats = TermStats{Freq: 3, DocLength: 50, AvgDocLength: 100, DocFreq: 4, IDF: 0.7, CollectionFreq: 10, DocCount: 20}
+
+func TestTermWeighter_Weight(t *testing.T) {
+	pairs := []struct {
+		name   string
+		w      TermWeighter
+		weight float64
+	}{
+		{"PL2", PL2Weighter{C: 1}, 2.043920634179466},
+		{"InL2", InL2Weighter{C: 1}, 1.8362176402480306},
+		{"DPH", DPHWeighter{}, 2.833811927439956},
+	}
+	for _, pair := range pairs {
+		if weight := pair.w.Weight(weightingStats); math.Abs(weight-pair.weight) > 1e-9 {
+			t.Errorf("Wrong %s weight: Got %v, Wanted %v.", pair.name, weight, pair.weight)
+		}
+	}
+
+	// BM25+ adds the lower bound to BM25, and BM25L without a shift is BM25.
+	bm25 := BM25Weighter{K1: 1.2, B: 0.75}.Weight(weightingStats)
+	if weight := (BM25PlusWeighter{K1: 1.2, B: 0.75, Delta: 1}).Weight(weightingStats); math.Abs(weight-(bm25+0.7)) > 1e-9 {
+		t.Errorf("Wrong BM25+ weight: Got %v, Wanted %v.", weight, bm25+0.7)
+	}
+	if weight := (BM25LWeighter{K1: 1.2, B: 0.75}).Weight(weightingStats); math.Abs(weight-bm25) > 1e-9 {
+		t.Errorf("Wrong BM25L weight: Got %v, Wanted %v.", weight, bm25)
+	}
+
+	// Longer documents weigh a term less in every model.
+	long := weightingStats
+	long.DocLength = 200
+	for name, w := range termWeighters {
+		if w.Weight(long) >= w.Weight(weightingStats) {
+			t.Errorf("Wrong %s weight in a longer document: Got %v, Wanted less than %v.", name, w.Weight(long), w.Weight(weightingStats))
+		}
+	}
+}
+
+func TestTermWeighter_WithParams(t *testing.T) {
+	w, err := DefaultBM25Weighter.WithParams(map[string]float64{"k1": 1.2})
+	if want := (BM25Weighter{K1: 1.2, B: bm25B}); err != nil || w != want {
+		t.Errorf("Wrong weighter: Got %v (%v), Wanted %v.", w, err, want)
+	}
+	if DefaultBM25Weighter.K1 != bm25K1 {
+		t.Errorf("Default weighter was changed: Got %v.", DefaultBM25Weighter)
+	}
+	invalid := []struct {
+		w      TermWeighter
+		params map[string]float64
+	}{
+		{DefaultBM25Weighter, map[string]float64{"b": 2}},
+		{DefaultBM25Weighter, map[string]float64{"c": 1}},
+		{PL2Weighter{C: 1}, map[string]float64{"c": 0}},
+		{DPHWeighter{}, map[string]float64{"k1": 1}},
+		{BM25LWeighter{}, map[string]float64{"delta": math.NaN()}},
+	}
+	for _, pair := range invalid {
+		if _, err := pair.w.WithParams(pair.params); err == nil {
+			t.Errorf("Missing error for %v of %T.", pair.params, pair.w)
+		}
+	}
+}
+
+func TestSearcher_WeightedQuery(t *testing.T) {
+	s := SetUpSearcher()
+	query := "matrix communication channel"
+	if res, want := s.WeightedQuery(query, DefaultBM25Weighter), s.BM25Query(query); !reflect.DeepEqual(res, want) {
+		t.Errorf("Wrong BM25 results: Got %v, Wanted %v.", res, want)
+	}
+	for name, w := range termWeighters {
+		if res := s.WeightedQuery(query, w); !reflect.DeepEqual(res, []int{3, 2}) {
+			t.Errorf("Wrong %s results: Got %v, Wanted %v.", name, res, []int{3, 2})
+		}
+	}
+}
+
+func TestSearcher_WeightedFieldLengths(t *testing.T) {
+	s := NewSearcher(3, &memoryStorage{docs: []Document{
+		{id: 1, Title: "The kappa coefficient", Body: "A statistic that measures the agreement of raters who classify items."},
+		{id: 2, Title: "Kappa statistic of agreement between many raters", Body: "Kappa."},
+		{id: 3, Title: "Percent agreement", Body: "The share of items that raters agree on."},
+	}})
+	s.BuildIndices()
+	// The shorter title ranks first, although its body is longer.
+	query := "title:kappa"
+	for name, w := range termWeighters {
+		if res := s.WeightedQuery(query, w); !reflect.DeepEqual(res, []int{1, 2}) {
+			t.Errorf("Wrong %s results: Got %v, Wanted %v.", name, res, []int{1, 2})
+		}
+	}
+	if res := s.BM25TopK(query, 1); !reflect.DeepEqual(res.ids, []int{1}) {
+		t.Errorf("Wrong top k results: Got %v, Wanted [1].", res.ids)
+	}
+	resList := s.weightedScores(query, DefaultBM25Weighter)
+	stats := s.termStats("title", []int{1, 2}, []int{1, 1})
+	stats.Freq, stats.DocLength = 1, 3
+	if stats.AvgDocLength != 4 {
+		t.Errorf("Wrong average length: Got %v, Wanted 4.", stats.AvgDocLength)
+	}
+	if score, want := resList.scores[resList.index[1]], DefaultBM25Weighter.Weight(stats); math.Abs(score-want) > 1e-12 {
+		t.Errorf("Wrong score: Got %v, Wanted %v.", score, want)
+	}
+}
+
+func TestSearcher_InverseDocumentFrequency(t *testing.T) {
+	s := SetUpSearcher()
+	pairs := []struct {
+		docFreq int
+		idf     float64
+	}{
+		{0, 0},
+		{1, math.Log10(3)},
+		{3, 0},
+	}
+	for _, pair := range pairs {
+		if idf := s.inverseDocumentFrequency(pair.docFreq); math.Abs(idf-pair.idf) > 1e-12 {
+			t.Errorf("Wrong idf of %d documents: Got %v, Wanted %v.", pair.docFreq, idf, pair.idf)
+		}
+	}
+	// The idf does not depend on the number of terms in the index.
+	stats := s.termStats("", []int{1}, []int{2})
+	if stats.DocCount != 3 || math.Abs(stats.IDF-math.Log10(3)) > 1e-12 {
+		t.Errorf("Wrong idf: Got %v of %d documents, Wanted %v of 3.", stats.IDF, stats.DocCount, math.Log10(3))
+	}
+}